@@ -46,12 +46,14 @@ func newCmdLonghornctlLocal() *cobra.Command {
 			Message: "Install And Uninstall Commands:",
 			Commands: []*cobra.Command{
 				localsubcmd.NewCmdInstall(globalOpts),
+				localsubcmd.NewCmdUninstall(globalOpts),
 			},
 		},
 		{
 			Message: "Operation Commands:",
 			Commands: []*cobra.Command{
 				localsubcmd.NewCmdTrim(globalOpts),
+				localsubcmd.NewCmdExport(globalOpts),
 			},
 		},
 		{
@@ -1,6 +1,7 @@
 package subcmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/pkg/errors"
@@ -36,6 +37,7 @@ func newCmdCheckPreflight(globalOpts *types.GlobalCmdOptions) *cobra.Command {
 
 		PreRun: func(cmd *cobra.Command, args []string) {
 			localChecker.LogLevel = globalOpts.LogLevel
+			localChecker.Namespace = os.Getenv(consts.EnvLonghornNamespace)
 
 			if err := localChecker.Init(); err != nil {
 				utils.CheckErr(errors.Wrap(err, "Failed to initialize preflight checker"))
@@ -64,7 +66,9 @@ func newCmdCheckPreflight(globalOpts *types.GlobalCmdOptions) *cobra.Command {
 	cmd.Flags().StringVarP(&localChecker.OutputFilePath, consts.CmdOptOutputFile, "o", os.Getenv(consts.EnvOutputFilePath), "Output the result to a file, default to stdout.")
 	cmd.Flags().BoolVar(&localChecker.EnableSpdk, consts.CmdOptEnableSpdk, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvEnableSpdk), false), "Enable checking of SPDK required packages, modules, and setup.")
 	cmd.Flags().IntVar(&localChecker.HugePageSize, consts.CmdOptHugePageSize, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvHugePageSize), 2048), "Specify the huge page size in MiB for SPDK.")
+	cmd.Flags().StringVar(&localChecker.AllowPci, consts.CmdOptAllowPci, os.Getenv(consts.EnvPciAllowed), fmt.Sprintf("Specify a comma-separated (%s) list of allowed PCI devices, by PCI address, %s<serial>, %s<pattern>, %s<name> or %s, to check the HugePages of their NUMA nodes.", consts.CmdOptSeperator, consts.PciSelectorSerialPrefix, consts.PciSelectorModelPrefix, consts.PciSelectorDiskByIDPrefix, consts.PciSelectorAllUnusedNvme))
 	cmd.Flags().StringVar(&localChecker.UserspaceDriver, consts.CmdOptUserspaceDriver, os.Getenv(consts.EnvUserspaceDriver), "Userspace I/O driver for SPDK.")
+	cmd.Flags().StringVar(&localChecker.DependencyConfig, consts.CmdOptDependencyConfig, os.Getenv(consts.EnvDependencyConfig), "Override the embedded package, module and service dependencies with the entries of this YAML file.")
 
 	return cmd
 }
@@ -0,0 +1,70 @@
+package subcmd
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/pkg/errors"
+
+	"github.com/longhorn/cli/pkg/consts"
+	local "github.com/longhorn/cli/pkg/local/preflight"
+	"github.com/longhorn/cli/pkg/types"
+	"github.com/longhorn/cli/pkg/utils"
+)
+
+func NewCmdExport(globalOpts *types.GlobalCmdOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   consts.SubCmdExport,
+		Short: "Export Longhorn resources",
+	}
+
+	utils.SetGlobalOptionsLocal(cmd, globalOpts)
+
+	cmd.AddCommand(newCmdExportPreflight(globalOpts))
+
+	return cmd
+}
+
+func newCmdExportPreflight(globalOpts *types.GlobalCmdOptions) *cobra.Command {
+	var localBundleExporter = local.BundleExporter{}
+
+	cmd := &cobra.Command{
+		Use:   consts.SubCmdPreflight,
+		Short: "Download the preflight packages to a local repository",
+		Long:  `This command downloads the packages required by the preflight installer, and their dependencies, to a directory used as a local package repository.`,
+
+		PreRun: func(cmd *cobra.Command, args []string) {
+			localBundleExporter.LogLevel = globalOpts.LogLevel
+
+			if err := localBundleExporter.Init(); err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to initialize preflight bundle exporter"))
+			}
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := localBundleExporter.Run(); err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to run preflight bundle exporter"))
+			}
+
+			logrus.Info("Successfully completed preflight bundle export")
+		},
+
+		PostRun: func(cmd *cobra.Command, args []string) {
+			if err := localBundleExporter.Output(); err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to output preflight bundle exporter collection"))
+			}
+
+			logrus.Info("Successfully output preflight bundle exporter collection")
+		},
+	}
+
+	utils.SetGlobalOptionsLocal(cmd, globalOpts)
+
+	cmd.Flags().StringVarP(&localBundleExporter.OutputFilePath, consts.CmdOptOutputFile, "o", os.Getenv(consts.EnvOutputFilePath), "Output the result to a file, default to stdout.")
+	cmd.Flags().StringVar(&localBundleExporter.HostTargetDirectory, consts.CmdOptTargetDirectory, os.Getenv(consts.EnvTargetDirectory), "Target directory on the host where the packages will be downloaded.")
+	cmd.Flags().BoolVar(&localBundleExporter.EnableSpdk, consts.CmdOptEnableSpdk, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvEnableSpdk), false), "Include the SPDK required packages.")
+
+	return cmd
+}
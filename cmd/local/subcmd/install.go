@@ -65,14 +65,22 @@ These dependencies ensure your Kubernetes cluster meets the requirements for suc
 	utils.SetGlobalOptionsLocal(cmd, globalOpts)
 
 	cmd.Flags().StringVarP(&localInstaller.OutputFilePath, consts.CmdOptOutputFile, "o", os.Getenv(consts.EnvOutputFilePath), "Output the result to a file, default to stdout.")
+	cmd.Flags().BoolVar(&localInstaller.DryRun, consts.CmdOptDryRun, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvDryRun), false), "Report the packages, modules, services, reboot and kubelet restart that would be changed on the node, without changing anything.")
 	cmd.Flags().BoolVar(&localInstaller.UpdatePackages, consts.CmdOptUpdatePackages, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvUpdatePackageList), true), "Update packages before installing required dependencies.")
 	cmd.Flags().BoolVar(&localInstaller.EnableSpdk, consts.CmdOptEnableSpdk, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvEnableSpdk), false), "Enable installation of SPDK required packages, modules, and setup.")
 	cmd.Flags().StringVar(&localInstaller.SpdkOptions, consts.CmdOptSpdkOptions, os.Getenv(consts.EnvSpdkOptions), "Specify a comma-separated list of KEY=VALUE environment variables passed to SPDK's scripts/setup.sh (e.g. HUGEMEM=2048,HUGENODE=0,PERSIST_HUGE=yes).")
 	cmd.Flags().IntVar(&localInstaller.HugePageSize, consts.CmdOptHugePageSize, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvHugePageSize), 2048), "Specify the huge page size in MiB for SPDK.")
-	cmd.Flags().StringVar(&localInstaller.AllowPci, consts.CmdOptAllowPci, os.Getenv(consts.EnvPciAllowed), fmt.Sprintf("Specify a comma-separated (%s) list of allowed PCI devices. By default, all PCI devices are blocked by a non-valid address.", consts.CmdOptSeperator))
+	cmd.Flags().BoolVar(&localInstaller.HugePageNuma, consts.CmdOptHugePageNuma, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvHugePageNuma), false), "Split the HugePages across the NUMA nodes of the allowed PCI devices, and persist them with the hugepages kernel parameter.")
+	cmd.Flags().StringVar(&localInstaller.AllowPci, consts.CmdOptAllowPci, os.Getenv(consts.EnvPciAllowed), fmt.Sprintf("Specify a comma-separated (%s) list of allowed PCI devices, by PCI address, %s<serial>, %s<pattern>, %s<name> or %s. The devices in use, with a filesystem, LVM, LUKS, RAID or swap signature, a mounted filesystem or a device-mapper or RAID device on them, are refused. By default, all PCI devices are blocked by a non-valid address.", consts.CmdOptSeperator, consts.PciSelectorSerialPrefix, consts.PciSelectorModelPrefix, consts.PciSelectorDiskByIDPrefix, consts.PciSelectorAllUnusedNvme))
 	cmd.Flags().StringVar(&localInstaller.DriverOverride, consts.CmdOptDriverOverride, os.Getenv(consts.EnvDriverOverride), "Userspace driver for device bindings. Override default driver for PCI devices.")
 	cmd.Flags().BoolVar(&localInstaller.RestartKubelet, consts.CmdOptRestartKubelet, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvRestartKubelet), false), "Enable automatic kubelet service restart to apply changes to huge page size")
 	cmd.Flags().StringVar(&localInstaller.RestartKubeletWindow, consts.CmdOptRestartKubeletWindow, os.Getenv(consts.EnvRestartKubeletWindow), "Time window for randomized restart (e.g., 30s, 2m). Kubelet will restart at a random time within this window.")
+	cmd.Flags().StringVar(&localInstaller.DependencyConfig, consts.CmdOptDependencyConfig, os.Getenv(consts.EnvDependencyConfig), "Override the embedded package, module and service dependencies with the entries of this YAML file.")
+	cmd.Flags().StringVar(&localInstaller.PreHook, consts.CmdOptPreHook, os.Getenv(consts.EnvPreHook), "Run this script on the host before updating and installing the packages.")
+	cmd.Flags().StringVar(&localInstaller.PostHook, consts.CmdOptPostHook, os.Getenv(consts.EnvPostHook), "Run this script on the host after starting the services.")
+	cmd.Flags().StringVar(&localInstaller.BundleDir, consts.CmdOptBundleDir, os.Getenv(consts.EnvPreflightBundleDir), "Install the packages from the preflight bundle in this host directory, instead of the distro repositories.")
+	cmd.Flags().BoolVar(&localInstaller.ConfigureMultipath, consts.CmdOptConfigureMultipath, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvConfigureMultipath), false), "Blacklist the Longhorn devices in multipathd with a configuration drop-in, reload multipathd, and verify no Longhorn device is claimed.")
+	cmd.Flags().BoolVar(&localInstaller.ConfigureSysctl, consts.CmdOptConfigureSysctl, utils.ConvertStringToTypeOrDefault(os.Getenv(consts.EnvConfigureSysctl), false), fmt.Sprintf("Persist the recommended kernel parameters, including the SPDK ones when SPDK is enabled, to %s and apply them. The values already higher than recommended are kept.", consts.SysctlConfigFile))
 
 	return cmd
 }
@@ -0,0 +1,68 @@
+package subcmd
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/pkg/errors"
+
+	"github.com/longhorn/cli/pkg/consts"
+	local "github.com/longhorn/cli/pkg/local/preflight"
+	"github.com/longhorn/cli/pkg/types"
+	"github.com/longhorn/cli/pkg/utils"
+)
+
+func NewCmdUninstall(globalOpts *types.GlobalCmdOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   consts.SubCmdUninstall,
+		Short: "Longhorn uninstallation operations",
+	}
+
+	utils.SetGlobalOptionsLocal(cmd, globalOpts)
+
+	cmd.AddCommand(newCmdUninstallPreflight(globalOpts))
+
+	return cmd
+}
+
+func newCmdUninstallPreflight(globalOpts *types.GlobalCmdOptions) *cobra.Command {
+	var localUninstaller = local.Uninstaller{}
+
+	cmd := &cobra.Command{
+		Use:   consts.SubCmdPreflight,
+		Short: "Revert the changes made by the preflight installer",
+		Long:  `This command reverts the packages, kernel modules, services and files recorded by the preflight installer on the node.`,
+
+		PreRun: func(cmd *cobra.Command, args []string) {
+			localUninstaller.LogLevel = globalOpts.LogLevel
+
+			if err := localUninstaller.Init(); err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to initialize preflight uninstaller"))
+			}
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := localUninstaller.Run(); err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to run preflight uninstaller"))
+			}
+
+			logrus.Info("Successfully completed preflight uninstallation")
+		},
+
+		PostRun: func(cmd *cobra.Command, args []string) {
+			if err := localUninstaller.Output(); err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to output preflight uninstaller collection"))
+			}
+
+			logrus.Info("Successfully output preflight uninstaller collection")
+		},
+	}
+
+	utils.SetGlobalOptionsLocal(cmd, globalOpts)
+
+	cmd.Flags().StringVarP(&localUninstaller.OutputFilePath, consts.CmdOptOutputFile, "o", os.Getenv(consts.EnvOutputFilePath), "Output the result to a file, default to stdout.")
+
+	return cmd
+}
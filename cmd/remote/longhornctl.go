@@ -46,12 +46,15 @@ func newCmdLonghornctl() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&globalOpts.NodeSelector, consts.CmdOptNodeSelector, "", "Comma-separated list of key=value pairs to match against node labels, selecting the nodes the DaemonSet will run on (e.g. env=prod,zone=us-west).")
 	cmd.PersistentFlags().StringVar(&globalOpts.Tolerations, consts.CmdOptTolerations, "", "Semicolon-separated list of tolerations for DaemonSet pods (e.g. key=value:NoSchedule;:NoExecute).")
 	cmd.PersistentFlags().StringVar(&globalOpts.Namespace, consts.CmdOptNamespace, consts.NamespaceLonghorn, "The namespace to run DaemonSet pods.")
+	cmd.PersistentFlags().BoolVar(&globalOpts.LabelNamespacePrivileged, consts.CmdOptLabelNamespacePrivileged, false, "Label the namespace with the privileged Pod Security Standard when it enforces the baseline or restricted standard, or when it has no enforce label and is subject to the cluster default, which may reject the DaemonSet pods.")
+	utils.SetTimeoutOptions(cmd, globalOpts)
 
 	groups := templates.CommandGroups{
 		{
 			Message: "Install And Uninstall Commands:",
 			Commands: []*cobra.Command{
 				subcmd.NewCmdInstall(globalOpts),
+				subcmd.NewCmdUninstall(globalOpts),
 			},
 		},
 		{
@@ -1,6 +1,8 @@
 package subcmd
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -64,6 +66,9 @@ INFO[2024-07-16T17:17:42+08:00] Completed preflight checker`,
 			preflightChecker.KubeConfigPath = globalOpts.KubeConfigPath
 			preflightChecker.NodeSelector = globalOpts.NodeSelector
 			preflightChecker.Tolerations = globalOpts.Tolerations
+			preflightChecker.LabelNamespacePrivileged = globalOpts.LabelNamespacePrivileged
+			preflightChecker.Timeout = globalOpts.Timeout
+			preflightChecker.PhaseTimeouts = globalOpts.PhaseTimeouts
 			preflightChecker.Namespace = globalOpts.Namespace
 
 			logrus.Info("Initializing preflight checker")
@@ -101,7 +106,10 @@ INFO[2024-07-16T17:17:42+08:00] Completed preflight checker`,
 
 	cmd.Flags().BoolVar(&preflightChecker.EnableSpdk, consts.CmdOptEnableSpdk, false, "Enable checking of SPDK required packages, modules, and setup.")
 	cmd.Flags().IntVar(&preflightChecker.HugePageSize, consts.CmdOptHugePageSize, 2048, "Specify the huge page size in MiB for SPDK.")
+	cmd.Flags().StringVar(&preflightChecker.AllowPci, consts.CmdOptAllowPci, "", fmt.Sprintf("Specify a comma-separated (%s) list of allowed PCI devices, by PCI address, %s<serial>, %s<pattern>, %s<name> or %s, to check the HugePages of their NUMA nodes.", consts.CmdOptSeperator, consts.PciSelectorSerialPrefix, consts.PciSelectorModelPrefix, consts.PciSelectorDiskByIDPrefix, consts.PciSelectorAllUnusedNvme))
 	cmd.Flags().StringVar(&preflightChecker.UserspaceDriver, consts.CmdOptUserspaceDriver, "", "Userspace I/O driver for SPDK.")
+	cmd.Flags().BoolVar(&preflightChecker.Follow, consts.CmdOptFollow, false, "Stream the log of each node prefixed with the node name, and a summary of the nodes pending, running, done and failed, while the check is running.")
+	cmd.Flags().StringVar(&preflightChecker.DependencyConfig, consts.CmdOptDependencyConfig, "", "Override the embedded package, module and service dependencies with the entries of this YAML file, keyed by packageManager and osRelease. Packages support an optional version constraint.")
 
 	return cmd
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/longhorn/cli/pkg/consts"
+	"github.com/longhorn/cli/pkg/remote/preflight"
 	"github.com/longhorn/cli/pkg/remote/replica"
 	"github.com/longhorn/cli/pkg/types"
 	"github.com/longhorn/cli/pkg/utils"
@@ -22,6 +23,7 @@ func NewCmdExport(globalOpts *types.GlobalCmdOptions) *cobra.Command {
 	utils.SetGlobalOptionsRemote(cmd, globalOpts)
 
 	cmd.AddCommand(newCmdExportReplica(globalOpts))
+	cmd.AddCommand(newCmdExportPreflight(globalOpts))
 
 	return cmd
 }
@@ -70,6 +72,9 @@ lost+found`,
 			replicaExporter.KubeConfigPath = globalOpts.KubeConfigPath
 			replicaExporter.NodeSelector = globalOpts.NodeSelector
 			replicaExporter.Tolerations = globalOpts.Tolerations
+			replicaExporter.LabelNamespacePrivileged = globalOpts.LabelNamespacePrivileged
+			replicaExporter.Timeout = globalOpts.Timeout
+			replicaExporter.PhaseTimeouts = globalOpts.PhaseTimeouts
 			replicaExporter.Namespace = globalOpts.Namespace
 
 			utils.CheckErr(replicaExporter.Validate())
@@ -156,3 +161,86 @@ INFO[2024-07-16T17:29:14+08:00] Successfully stopped exporting replica`,
 
 	return cmd
 }
+
+func newCmdExportPreflight(globalOpts *types.GlobalCmdOptions) *cobra.Command {
+	var bundleExporter = preflight.BundleExporter{}
+
+	cmd := &cobra.Command{
+		Use:   consts.SubCmdPreflight,
+		Short: "Export Longhorn preflight bundle",
+		Long: `This command downloads the packages required by ` + "`longhornctl install preflight`" + `, and their dependencies, to a directory on the host machine.
+The directory can then be used as a local package repository to install the dependencies on air-gapped nodes.
+
+The bundle is built on the cluster nodes, from the distro repositories configured on them. The distro and version of the
+bundle are the ones of the selected nodes, they cannot be chosen, and the bundle cannot be built on a machine outside the
+cluster. Run this command on nodes with access to the distro repositories, and with the same distro and version as the
+air-gapped nodes. Use the --node-selector option to select these nodes. To build a bundle for another distro or version,
+join a connected node running it to the cluster first. On SUSE based distros, the dependencies already installed on the
+node are not downloaded, so select nodes with a minimal installation.
+
+To install from the bundle, either copy the directory to the air-gapped nodes and use:
+  $ longhornctl install preflight --bundle-dir=<directory>
+
+Or build an image with the directory content in ` + consts.PreflightBundleImageDirectory + `, and use:
+  $ longhornctl install preflight --bundle-image=<image>`,
+		Example: `$ longhornctl export preflight --target-dir=/tmp/bundle --node-selector=kubernetes.io/hostname=ip-192-168-208-117
+INFO[2024-07-16T17:46:55+08:00] Initializing preflight bundle exporter
+INFO[2024-07-16T17:46:55+08:00] Cleaning up preflight bundle exporter
+INFO[2024-07-16T17:46:55+08:00] Running preflight bundle exporter
+INFO[2024-07-16T17:47:48+08:00] Retrieved preflight bundle exporter result:
+ip-192-168-208-117:
+  info:
+  - Successfully downloaded packages nfs-client, open-iscsi, cryptsetup to /tmp/bundle
+INFO[2024-07-16T17:47:48+08:00] Cleaning up preflight bundle exporter
+INFO[2024-07-16T17:47:48+08:00] Completed preflight bundle exporter`,
+
+		PreRun: func(cmd *cobra.Command, args []string) {
+			bundleExporter.Image = globalOpts.Image
+			bundleExporter.ImageRegistry = globalOpts.ImageRegistry
+			bundleExporter.ImagePullSecret = globalOpts.ImagePullSecret
+			bundleExporter.KubeConfigPath = globalOpts.KubeConfigPath
+			bundleExporter.NodeSelector = globalOpts.NodeSelector
+			bundleExporter.Tolerations = globalOpts.Tolerations
+			bundleExporter.LabelNamespacePrivileged = globalOpts.LabelNamespacePrivileged
+			bundleExporter.Timeout = globalOpts.Timeout
+			bundleExporter.PhaseTimeouts = globalOpts.PhaseTimeouts
+			bundleExporter.Namespace = globalOpts.Namespace
+
+			logrus.Info("Initializing preflight bundle exporter")
+			if err := bundleExporter.Init(); err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to initialize preflight bundle exporter"))
+			}
+
+			logrus.Info("Cleaning up preflight bundle exporter")
+			if err := bundleExporter.Cleanup(); err != nil {
+				utils.CheckErr(errors.Wrapf(err, "Failed to cleanup preflight bundle exporter"))
+			}
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			logrus.Info("Running preflight bundle exporter")
+			output, err := bundleExporter.Run()
+			if err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to run preflight bundle exporter"))
+			}
+
+			logrus.Infof("Retrieved preflight bundle exporter result:\n%v", output)
+		},
+
+		PostRun: func(cmd *cobra.Command, args []string) {
+			logrus.Info("Cleaning up preflight bundle exporter")
+			if err := bundleExporter.Cleanup(); err != nil {
+				utils.CheckErr(errors.Wrapf(err, "Failed to cleanup preflight bundle exporter"))
+			}
+
+			logrus.Info("Completed preflight bundle exporter")
+		},
+	}
+
+	utils.SetGlobalOptionsRemote(cmd, globalOpts)
+
+	cmd.Flags().StringVar(&bundleExporter.HostTargetDirectory, consts.CmdOptTargetDirectory, "", "Target directory on the host machine where the packages will be downloaded.")
+	cmd.Flags().BoolVar(&bundleExporter.EnableSpdk, consts.CmdOptEnableSpdk, false, "Include the SPDK required packages.")
+
+	return cmd
+}
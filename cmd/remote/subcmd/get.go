@@ -68,6 +68,9 @@ INFO[2024-07-16T17:23:51+08:00] Completed replica getter`,
 			replicaGetter.KubeConfigPath = globalOpts.KubeConfigPath
 			replicaGetter.NodeSelector = globalOpts.NodeSelector
 			replicaGetter.Tolerations = globalOpts.Tolerations
+			replicaGetter.LabelNamespacePrivileged = globalOpts.LabelNamespacePrivileged
+			replicaGetter.Timeout = globalOpts.Timeout
+			replicaGetter.PhaseTimeouts = globalOpts.PhaseTimeouts
 			replicaGetter.Namespace = globalOpts.Namespace
 
 			logrus.Info("Initializing replica getter")
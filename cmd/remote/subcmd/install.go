@@ -36,7 +36,13 @@ func newCmdInstallPreflight(globalOpts *types.GlobalCmdOptions) *cobra.Command {
 These dependencies ensure your Kubernetes cluster meets the requirements for successful Longhorn operation.
 
 On some OS, like for example SLE Micro, after having installed the needed packages, ` + "`longhornctl`" + ` asks to the user to reboot the machine and
-to execute the install command again. During the first execution ` + "`longhornctl`" + ` install needed packages, during the second one it probes modules, start services and configure tools.`,
+to execute the install command again. During the first execution ` + "`longhornctl`" + ` install needed packages, during the second one it probes modules, start services and configure tools.
+Use ` + "`--reboot`" + ` to let ` + "`longhornctl`" + ` cordon, drain and reboot these nodes one at a time, and execute the install command again on them.
+
+Use ` + "`--dry-run`" + ` to report the changes that would be made on each node without running any mutating command.
+
+Use ` + "`--max-unavailable`" + ` to roll out the installation in batches of nodes, and ` + "`--drain`" + ` to cordon and drain the nodes of each batch first.
+The rollout stops on the first failed batch, and the nodes of the failed batch are left cordoned.`,
 
 		Example: `$ longhornctl install preflight
 INFO[2024-07-16T17:06:55+08:00] Initializing preflight installer
@@ -70,6 +76,9 @@ If a reboot is required, the following message will be displayed:
 			preflightInstaller.KubeConfigPath = globalOpts.KubeConfigPath
 			preflightInstaller.NodeSelector = globalOpts.NodeSelector
 			preflightInstaller.Tolerations = globalOpts.Tolerations
+			preflightInstaller.LabelNamespacePrivileged = globalOpts.LabelNamespacePrivileged
+			preflightInstaller.Timeout = globalOpts.Timeout
+			preflightInstaller.PhaseTimeouts = globalOpts.PhaseTimeouts
 			preflightInstaller.Namespace = globalOpts.Namespace
 
 			logrus.Info("Initializing preflight installer")
@@ -95,8 +104,12 @@ If a reboot is required, the following message will be displayed:
 		},
 
 		PostRun: func(cmd *cobra.Command, args []string) {
-			logrus.Info("Cleaning up preflight installer")
-			if preflightInstaller.OperatingSystem != string(consts.OperatingSystemContainerOptimizedOS) {
+			// The node agent keeps running iscsid on Container Optimized OS.
+			if preflightInstaller.OperatingSystem == string(consts.OperatingSystemContainerOptimizedOS) {
+				logrus.Infof("Keeping the node agent running iscsid. Use '%s %s %s %s --%s=%s' to remove it",
+					consts.CmdLonghornctlRemote, consts.SubCmdInstall, consts.SubCmdPreflight, consts.SubCmdStop, consts.CmdOptOperatingSystem, consts.OperatingSystemContainerOptimizedOS)
+			} else {
+				logrus.Info("Cleaning up preflight installer")
 				if err := preflightInstaller.Cleanup(); err != nil {
 					utils.CheckErr(errors.Wrapf(err, "Failed to cleanup preflight installer"))
 				}
@@ -111,14 +124,27 @@ If a reboot is required, the following message will be displayed:
 	utils.SetGlobalOptionsRemote(cmd, globalOpts)
 
 	cmd.Flags().StringVar(&preflightInstaller.OperatingSystem, consts.CmdOptOperatingSystem, "", "Specify the operating system (\"\", cos). Leave this empty to use the package manager for installation.")
+	cmd.Flags().BoolVar(&preflightInstaller.DryRun, consts.CmdOptDryRun, false, "Report the packages, modules, services, reboot and kubelet restart that would be changed on each node, without changing anything.")
+	cmd.Flags().IntVar(&preflightInstaller.MaxUnavailable, consts.CmdOptMaxUnavailable, 0, "Install on at most this many nodes at a time, stopping the rollout on the first failed batch. Install on all nodes at once when 0.")
+	cmd.Flags().BoolVar(&preflightInstaller.Drain, consts.CmdOptDrain, false, fmt.Sprintf("Cordon and drain the nodes of each batch before installing, and uncordon them once the batch succeeds. Requires %q.", consts.CmdOptMaxUnavailable))
+	cmd.Flags().BoolVar(&preflightInstaller.Reboot, consts.CmdOptReboot, false, "Reboot the nodes requiring a reboot after the package installation (e.g. transactional-update) one at a time, with cordon and drain, and execute the installer again on them.")
+	cmd.Flags().StringVar(&preflightInstaller.BundleDir, consts.CmdOptBundleDir, "", fmt.Sprintf("Install the packages from the preflight bundle in this host directory, instead of the distro repositories. The bundle can be created with '%s %s %s'.", consts.CmdLonghornctlRemote, consts.SubCmdExport, consts.SubCmdPreflight))
+	cmd.Flags().StringVar(&preflightInstaller.BundleImage, consts.CmdOptBundleImage, "", fmt.Sprintf("Install the packages from the preflight bundle in the %s directory of this image, instead of the distro repositories. The image requires sh and cp.", consts.PreflightBundleImageDirectory))
+	cmd.Flags().StringVar(&preflightInstaller.DependencyConfig, consts.CmdOptDependencyConfig, "", "Override the embedded package, module and service dependencies with the entries of this YAML file, keyed by packageManager and osRelease. Packages support an optional version constraint.")
+	cmd.Flags().StringVar(&preflightInstaller.PreHook, consts.CmdOptPreHook, "", fmt.Sprintf("Run this script on the host of each node before updating and installing the packages, e.g. to configure a proxy or a repository for the package manager. Specify a local file, or '%s<name>/<key>' to read the script from a ConfigMap in the namespace. The output is included in the node result.", consts.HookConfigMapReferencePrefix))
+	cmd.Flags().StringVar(&preflightInstaller.PostHook, consts.CmdOptPostHook, "", fmt.Sprintf("Run this script on the host of each node after starting the services, e.g. to restart an agent depending on iscsid. Specify a local file, or '%s<name>/<key>' to read the script from a ConfigMap in the namespace. The output is included in the node result.", consts.HookConfigMapReferencePrefix))
+	cmd.Flags().BoolVar(&preflightInstaller.Follow, consts.CmdOptFollow, false, "Stream the log of each node prefixed with the node name, and a summary of the nodes pending, running, done and failed, while the installation is running.")
 	cmd.Flags().BoolVar(&preflightInstaller.UpdatePackages, consts.CmdOptUpdatePackages, true, "Update packages before installing required dependencies.")
 	cmd.Flags().BoolVar(&preflightInstaller.EnableSpdk, consts.CmdOptEnableSpdk, false, "Enable installation of SPDK required packages, modules, and setup.")
 	cmd.Flags().StringVar(&preflightInstaller.SpdkOptions, consts.CmdOptSpdkOptions, "", "Specify a comma-separated list of KEY=VALUE environment variables passed to SPDK's scripts/setup.sh (e.g. HUGEMEM=2048,HUGENODE=0,PERSIST_HUGE=yes).")
 	cmd.Flags().IntVar(&preflightInstaller.HugePageSize, consts.CmdOptHugePageSize, 2048, "Specify the huge page size in MiB for SPDK.")
-	cmd.Flags().StringVar(&preflightInstaller.AllowPci, consts.CmdOptAllowPci, "none", fmt.Sprintf("Specify a comma-separated (%s) list of allowed PCI devices. By default, all PCI devices are blocked by a non-valid address.", consts.CmdOptSeperator))
+	cmd.Flags().BoolVar(&preflightInstaller.HugePageNuma, consts.CmdOptHugePageNuma, false, "Split the HugePages across the NUMA nodes of the allowed PCI devices, and persist them with the hugepages kernel parameter.")
+	cmd.Flags().StringVar(&preflightInstaller.AllowPci, consts.CmdOptAllowPci, "none", fmt.Sprintf("Specify a comma-separated (%s) list of allowed PCI devices, by PCI address, %s<serial>, %s<pattern>, %s<name> or %s. The devices in use, with a filesystem, LVM, LUKS, RAID or swap signature, a mounted filesystem or a device-mapper or RAID device on them, are refused. By default, all PCI devices are blocked by a non-valid address.", consts.CmdOptSeperator, consts.PciSelectorSerialPrefix, consts.PciSelectorModelPrefix, consts.PciSelectorDiskByIDPrefix, consts.PciSelectorAllUnusedNvme))
 	cmd.Flags().StringVar(&preflightInstaller.DriverOverride, consts.CmdOptDriverOverride, "", "Userspace driver for device bindings. Override default driver for PCI devices.")
 	cmd.Flags().BoolVar(&preflightInstaller.RestartKubelet, consts.CmdOptRestartKubelet, false, "Enable automatic kubelet service restart to apply changes to huge page size")
 	cmd.Flags().StringVar(&preflightInstaller.RestartKubeletWindow, consts.CmdOptRestartKubeletWindow, "1m", "Time window for randomized restart (e.g., 10s, 2m). Kubelet will restart at a random time within this window.")
+	cmd.Flags().BoolVar(&preflightInstaller.ConfigureMultipath, consts.CmdOptConfigureMultipath, false, "Blacklist the Longhorn devices in multipathd with a configuration drop-in, reload multipathd, and verify no Longhorn device is claimed.")
+	cmd.Flags().BoolVar(&preflightInstaller.ConfigureSysctl, consts.CmdOptConfigureSysctl, false, fmt.Sprintf("Persist the recommended kernel parameters, including the SPDK ones when SPDK is enabled, to %s and apply them. The values already higher than recommended are kept.", consts.SysctlConfigFile))
 
 	return cmd
 }
@@ -129,7 +155,9 @@ func newCmdInstallPreflightStop(globalOpts *types.GlobalCmdOptions) *cobra.Comma
 	cmd := &cobra.Command{
 		Use:   consts.SubCmdStop,
 		Short: "Stop Longhorn preflight installer",
-		Long:  `This command terminates the preflight installer.`,
+		Long: `This command terminates the preflight installer.
+
+On Container Optimized OS, use ` + "`--operating-system=cos`" + ` to remove the node agent running iscsid, and its ConfigMap.`,
 		Example: `$ longhornctl install preflight stop
 INFO[2024-07-16T17:21:32+08:00] Stopping preflight installer
 INFO[2024-07-16T17:21:32+08:00] Successfully stopped preflight installer`,
@@ -137,6 +165,8 @@ INFO[2024-07-16T17:21:32+08:00] Successfully stopped preflight installer`,
 		PreRun: func(cmd *cobra.Command, args []string) {
 			preflightInstaller.KubeConfigPath = globalOpts.KubeConfigPath
 			preflightInstaller.Namespace = globalOpts.Namespace
+			preflightInstaller.Timeout = globalOpts.Timeout
+			preflightInstaller.PhaseTimeouts = globalOpts.PhaseTimeouts
 
 			if err := preflightInstaller.Init(); err != nil {
 				utils.CheckErr(errors.Wrap(err, "Failed to initialize preflight installer"))
@@ -165,8 +195,21 @@ INFO[2024-07-16T17:21:32+08:00] Successfully stopped preflight installer`,
 	utils.SetFlagHidden(cmd, consts.CmdOptEnableSpdk)
 	utils.SetFlagHidden(cmd, consts.CmdOptSpdkOptions)
 	utils.SetFlagHidden(cmd, consts.CmdOptHugePageSize)
+	utils.SetFlagHidden(cmd, consts.CmdOptHugePageNuma)
 	utils.SetFlagHidden(cmd, consts.CmdOptAllowPci)
 	utils.SetFlagHidden(cmd, consts.CmdOptDriverOverride)
+	utils.SetFlagHidden(cmd, consts.CmdOptConfigureSysctl)
+	utils.SetFlagHidden(cmd, consts.CmdOptConfigureMultipath)
+	utils.SetFlagHidden(cmd, consts.CmdOptDryRun)
+	utils.SetFlagHidden(cmd, consts.CmdOptMaxUnavailable)
+	utils.SetFlagHidden(cmd, consts.CmdOptDrain)
+	utils.SetFlagHidden(cmd, consts.CmdOptReboot)
+	utils.SetFlagHidden(cmd, consts.CmdOptBundleDir)
+	utils.SetFlagHidden(cmd, consts.CmdOptBundleImage)
+	utils.SetFlagHidden(cmd, consts.CmdOptDependencyConfig)
+	utils.SetFlagHidden(cmd, consts.CmdOptFollow)
+	utils.SetFlagHidden(cmd, consts.CmdOptPreHook)
+	utils.SetFlagHidden(cmd, consts.CmdOptPostHook)
 
 	return cmd
 }
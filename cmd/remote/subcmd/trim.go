@@ -51,6 +51,9 @@ INFO[2024-07-16T17:32:01+08:00] Completed volume trimmer                      vo
 			volumeTrimmer.KubeConfigPath = globalOpts.KubeConfigPath
 			volumeTrimmer.NodeSelector = globalOpts.NodeSelector
 			volumeTrimmer.Tolerations = globalOpts.Tolerations
+			volumeTrimmer.LabelNamespacePrivileged = globalOpts.LabelNamespacePrivileged
+			volumeTrimmer.Timeout = globalOpts.Timeout
+			volumeTrimmer.PhaseTimeouts = globalOpts.PhaseTimeouts
 			volumeTrimmer.Namespace = globalOpts.Namespace
 
 			utils.CheckErr(volumeTrimmer.Validate())
@@ -0,0 +1,98 @@
+package subcmd
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/longhorn/cli/pkg/consts"
+	"github.com/longhorn/cli/pkg/remote/preflight"
+	"github.com/longhorn/cli/pkg/types"
+	"github.com/longhorn/cli/pkg/utils"
+)
+
+func NewCmdUninstall(globalOpts *types.GlobalCmdOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   consts.SubCmdUninstall,
+		Short: "Longhorn uninstallation operations",
+	}
+
+	utils.SetGlobalOptionsRemote(cmd, globalOpts)
+
+	cmd.AddCommand(newCmdUninstallPreflight(globalOpts))
+
+	return cmd
+}
+
+func newCmdUninstallPreflight(globalOpts *types.GlobalCmdOptions) *cobra.Command {
+	var preflightUninstaller = preflight.Uninstaller{}
+
+	cmd := &cobra.Command{
+		Use:   consts.SubCmdPreflight,
+		Short: "Uninstall Longhorn preflight",
+		Long: `This command reverts the changes made by ` + "`longhornctl install preflight`" + ` on each node.
+
+The preflight installer records the packages it installed, the kernel modules it loaded, the services it enabled and the files it created
+in ` + consts.PreflightInstallerStateFile + ` on each node. Only those changes are reverted, so the packages, modules and services already present
+before the installation are left untouched. The changes failed to be reverted are kept in the record, so the command can be executed again.`,
+
+		Example: `$ longhornctl uninstall preflight
+INFO[2024-07-16T17:36:55+08:00] Initializing preflight uninstaller
+INFO[2024-07-16T17:36:55+08:00] Cleaning up preflight uninstaller
+INFO[2024-07-16T17:36:55+08:00] Running preflight uninstaller
+INFO[2024-07-16T17:37:48+08:00] Retrieved preflight uninstaller result:
+ip-192-168-208-117:
+  info:
+  - Successfully disabled service iscsid
+  - Successfully unloaded module iscsi_tcp
+  - Successfully uninstalled package open-iscsi
+INFO[2024-07-16T17:37:48+08:00] Cleaning up preflight uninstaller
+INFO[2024-07-16T17:37:48+08:00] Completed preflight uninstaller`,
+
+		PreRun: func(cmd *cobra.Command, args []string) {
+			preflightUninstaller.Image = globalOpts.Image
+			preflightUninstaller.ImageRegistry = globalOpts.ImageRegistry
+			preflightUninstaller.ImagePullSecret = globalOpts.ImagePullSecret
+			preflightUninstaller.KubeConfigPath = globalOpts.KubeConfigPath
+			preflightUninstaller.NodeSelector = globalOpts.NodeSelector
+			preflightUninstaller.Tolerations = globalOpts.Tolerations
+			preflightUninstaller.LabelNamespacePrivileged = globalOpts.LabelNamespacePrivileged
+			preflightUninstaller.Timeout = globalOpts.Timeout
+			preflightUninstaller.PhaseTimeouts = globalOpts.PhaseTimeouts
+			preflightUninstaller.Namespace = globalOpts.Namespace
+
+			logrus.Info("Initializing preflight uninstaller")
+			if err := preflightUninstaller.Init(); err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to initialize preflight uninstaller"))
+			}
+
+			logrus.Info("Cleaning up preflight uninstaller")
+			if err := preflightUninstaller.Cleanup(); err != nil {
+				utils.CheckErr(errors.Wrapf(err, "Failed to cleanup preflight uninstaller"))
+			}
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			logrus.Info("Running preflight uninstaller")
+			output, err := preflightUninstaller.Run()
+			if err != nil {
+				utils.CheckErr(errors.Wrap(err, "Failed to run preflight uninstaller"))
+			}
+
+			logrus.Infof("Retrieved preflight uninstaller result:\n%v", output)
+		},
+
+		PostRun: func(cmd *cobra.Command, args []string) {
+			logrus.Info("Cleaning up preflight uninstaller")
+			if err := preflightUninstaller.Cleanup(); err != nil {
+				utils.CheckErr(errors.Wrapf(err, "Failed to cleanup preflight uninstaller"))
+			}
+
+			logrus.Info("Completed preflight uninstaller")
+		},
+	}
+
+	utils.SetGlobalOptionsRemote(cmd, globalOpts)
+
+	return cmd
+}
@@ -8,11 +8,12 @@ const (
 
 const (
 	// The first layer of subcommands (verb)
-	SubCmdCheck   = "check"
-	SubCmdExport  = "export"
-	SubCmdGet     = "get"
-	SubCmdInstall = "install"
-	SubCmdTrim    = "trim"
+	SubCmdCheck     = "check"
+	SubCmdExport    = "export"
+	SubCmdGet       = "get"
+	SubCmdInstall   = "install"
+	SubCmdTrim      = "trim"
+	SubCmdUninstall = "uninstall"
 
 	// The second layer of subcommands (noun)
 	SubCmdPreflight = "preflight"
@@ -34,6 +35,11 @@ const (
 	CmdOptImageRegistry   = "image-registry"
 	CmdOptImagePullSecret = "image-pull-secret"
 
+	CmdOptLabelNamespacePrivileged = "label-namespace-privileged"
+
+	CmdOptTimeout      = "timeout"
+	CmdOptPhaseTimeout = "phase-timeout"
+
 	// General options
 	CmdOptName            = "name"
 	CmdOptNamespace       = "namespace"
@@ -45,12 +51,28 @@ const (
 	CmdOptNodeSelector    = "node-selector"
 	CmdOptTolerations     = "tolerations"
 	CmdOptAll             = "all"
+	CmdOptDryRun          = "dry-run"
+	CmdOptMaxUnavailable  = "max-unavailable"
+	CmdOptDrain           = "drain"
+	CmdOptReboot          = "reboot"
+	CmdOptBundleDir       = "bundle-dir"
+	CmdOptBundleImage     = "bundle-image"
+
+	CmdOptDependencyConfig = "dependency-config"
+	CmdOptFollow           = "follow"
+	CmdOptPreHook          = "pre-hook"
+	CmdOptPostHook         = "post-hook"
+
+	// Host options
+	CmdOptConfigureSysctl    = "configure-sysctl"
+	CmdOptConfigureMultipath = "configure-multipath"
 
 	// SPDK options
 	CmdOptAllowPci             = "allow-pci"
 	CmdOptDriverOverride       = "driver-override"
 	CmdOptEnableSpdk           = "enable-spdk"
 	CmdOptHugePageSize         = "huge-page-size"
+	CmdOptHugePageNuma         = "huge-page-numa"
 	CmdOptSpdkOptions          = "spdk-options"
 	CmdOptUserspaceDriver      = "userspace-driver"
 	CmdOptRestartKubelet       = "restart-kubelet"
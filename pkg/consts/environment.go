@@ -4,6 +4,8 @@ type OperatingSystem string
 
 const (
 	OperatingSystemContainerOptimizedOS OperatingSystem = "cos"
+	OperatingSystemTalos                OperatingSystem = "talos"
+	OperatingSystemFlatcar              OperatingSystem = "flatcar"
 )
 
 const (
@@ -11,11 +13,21 @@ const (
 	EnvKubeConfigPath = "KUBECONFIG"
 	EnvLogLevel       = "LOG_LEVEL"
 	EnvOutputFilePath = "OUTPUT_FILE_PATH"
+	EnvDryRun         = "DRY_RUN"
 
 	EnvLonghornDataDirectory = "LONGHORN_DATA_DIRECTORY"
 	EnvLonghornNamespace     = "LONGHORN_NAMESPACE"
 	EnvLonghornReplicaName   = "REPLICA_NAME"
 	EnvLonghornVolumeName    = "VOLUME_NAME"
+
+	EnvConfigureSysctl    = "CONFIGURE_SYSCTL"
+	EnvConfigureMultipath = "CONFIGURE_MULTIPATH"
+
+	EnvPreflightBundleDir = "PREFLIGHT_BUNDLE_DIR"
+	EnvTargetDirectory    = "TARGET_DIRECTORY"
+	EnvDependencyConfig   = "DEPENDENCY_CONFIG"
+	EnvPreHook            = "PRE_HOOK"
+	EnvPostHook           = "POST_HOOK"
 )
 
 // SPDK related environment variables
@@ -23,6 +35,7 @@ const (
 	EnvDriverOverride       = "DRIVER_OVERRIDE"
 	EnvEnableSpdk           = "ENABLE_SPDK"
 	EnvHugePageSize         = "HUGEMEM"
+	EnvHugePageNuma         = "HUGE_PAGE_NUMA"
 	EnvPciAllowed           = "PCI_ALLOWED"
 	EnvUserspaceDriver      = "USERSPACE_DRIVER"
 	EnvUpdatePackageList    = "UPDATE_PACKAGE_LIST"
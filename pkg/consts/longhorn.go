@@ -3,3 +3,22 @@ package consts
 const LonghornDiskConfigFile = "longhorn-disk.cfg"
 
 const LonghornServiceAccountName = "longhorn-service-account"
+
+// Minimum recommended hardware per node.
+// https://longhorn.io/docs/latest/best-practices/#minimum-recommended-hardware
+const (
+	LonghornMinimumNodeCPU    = "4"
+	LonghornMinimumNodeMemory = "4Gi"
+)
+
+// iSCSI target identity of the devices exposed by the Longhorn engine.
+const (
+	LonghornIscsiTargetPrefix = "iqn.2019-10.io.longhorn"
+	LonghornIscsiVendor       = "IET"
+	LonghornIscsiProduct      = "VIRTUAL-DISK"
+)
+
+const (
+	LonghornServiceNameBackend  = "longhorn-backend"
+	LonghornServiceNameFrontend = "longhorn-frontend"
+)
@@ -2,6 +2,7 @@ package consts
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/longhorn/cli/meta"
 )
@@ -19,15 +20,33 @@ var (
 const (
 	ContainerName       = "longhornctl"
 	ContainerNameEngine = "engine"
+	ContainerNameBundle = "bundle"
 	ContainerNameInit   = "init-longhornctl"
 	ContainerNameOutput = "output-longhornctl"
 	ContainerNamePause  = "pause"
 )
 
 const (
-	ContainerConditionMaxTolerationLong   = 60 * 10 // 10 minutes: for container responsible for long running tasks. For example: package installation.
-	ContainerConditionMaxTolerationMedium = 60 * 5  // 5 minutes: for container responsible for medium running tasks. For example: export replica.
-	ContainerConditionMaxTolerationShort  = 60      // 1 minute: for container responsible for short running tasks. For example: print file contents.
+	ContainerConditionTimeoutLong   = 10 * time.Minute // For container responsible for long running tasks. For example: package installation.
+	ContainerConditionTimeoutMedium = 5 * time.Minute  // For container responsible for medium running tasks. For example: export replica.
+	ContainerConditionTimeoutShort  = time.Minute      // For container responsible for short running tasks. For example: print file contents.
+)
+
+// TimeoutPhase is a phase waiting for the DaemonSet pod containers, with a configurable timeout.
+type TimeoutPhase string
+
+const (
+	TimeoutPhaseRun    TimeoutPhase = "run"    // Waiting for the init container performing the operation to exit.
+	TimeoutPhaseReady  TimeoutPhase = "ready"  // Waiting for the long running container to be ready.
+	TimeoutPhaseOutput TimeoutPhase = "output" // Waiting for the output container printing the result to exit.
+)
+
+var TimeoutPhases = []TimeoutPhase{TimeoutPhaseRun, TimeoutPhaseReady, TimeoutPhaseOutput}
+
+const (
+	NodeDrainTimeout        = 10 * time.Minute // Maximum time to wait for the pods of a node to be evicted, or deleted.
+	NodeRebootTimeout       = 15 * time.Minute // Maximum time to wait for a node to be Ready after a reboot.
+	NodeRebootLeaseDuration = time.Hour        // Maximum time to hold the cluster-wide reboot Lease for a node.
 )
 
 const (
@@ -45,14 +64,40 @@ const (
 
 	VolumeMountVolumeName      = "volume"
 	VolumeMountVolumeDirectory = "/volume"
+
+	VolumeMountDependencyConfigName      = "dependency-config"
+	VolumeMountDependencyConfigDirectory = "/dependency-config"
+
+	VolumeMountHookName      = "hook"
+	VolumeMountHookDirectory = "/hook"
 )
 
 const (
 	FileNamePreStopScript = "pre-stop.sh"
 	FileNameOutputJSON    = "output.json"
+
+	FileNameDependencyConfig = "dependencies.yaml"
+	FileNamePreHook          = "pre-hook.sh"
+	FileNamePostHook         = "post-hook.sh"
 )
 
+// HookConfigMapReferencePrefix prefixes the hook option referencing a key of a ConfigMap in the namespace,
+// as configmap:<name>/<key>, instead of a local script file.
+const HookConfigMapReferencePrefix = "configmap:"
+
 const (
 	LogPrefixError = "ERROR: "
 	LogPrefixWarn  = "WARN: "
 )
+
+// Pod Security admission namespace labels and levels.
+// https://kubernetes.io/docs/concepts/security/pod-security-admission/
+const (
+	PodSecurityLabelEnforce = "pod-security.kubernetes.io/enforce"
+	PodSecurityLabelAudit   = "pod-security.kubernetes.io/audit"
+	PodSecurityLabelWarn    = "pod-security.kubernetes.io/warn"
+
+	PodSecurityLevelPrivileged = "privileged"
+	PodSecurityLevelBaseline   = "baseline"
+	PodSecurityLevelRestricted = "restricted"
+)
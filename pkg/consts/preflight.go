@@ -1,9 +1,16 @@
 package consts
 
+import "time"
+
 const (
 	AppNamePreflightChecker              = "longhorn-preflight-checker"
 	AppNamePreflightContainerOptimizedOS = "longhorn-gke-cos-node-agent"
 	AppNamePreflightInstaller            = "longhorn-preflight-installer"
+	AppNamePreflightUninstaller          = "longhorn-preflight-uninstaller"
+	AppNamePreflightReboot               = "longhorn-preflight-reboot"
+	AppNamePreflightBundleExporter       = "longhorn-preflight-bundle-exporter"
+
+	LeaseNamePreflightReboot = "longhorn-preflight-reboot"
 )
 
 const (
@@ -17,6 +24,10 @@ const (
 	PreflightCheckTopicKubeDNS              = "KubeDNS"
 	PreflightCheckTopicNFS                  = "NFSv4"
 	PreflightCheckTopicSPDK                 = "SPDK"
+	PreflightCheckTopicCapacity             = "Capacity"
+	PreflightCheckTopicConflictingStorage   = "ConflictingStorage"
+	PreflightCheckTopicHostSettings         = "HostSettings"
+	PreflightCheckTopicImmutableOS          = "ImmutableOS"
 	PreflightCheckTopicInternalError        = "InternalError"
 )
 
@@ -25,6 +36,43 @@ const (
 	KubeAppValueDNS = "kube-dns"
 )
 
+const (
+	// KubeDNSServiceKubernetes is the API server service name resolved to verify the cluster DNS.
+	KubeDNSServiceKubernetes = "kubernetes.default.svc"
+
+	KubeDNSResolveTimeout          = 5 * time.Second
+	KubeDNSResolveLatencyThreshold = 1 * time.Second
+)
+
+// SysctlConfigFile is the sysctl configuration file on the host persisting the kernel parameters configured by the installer.
+const SysctlConfigFile = "/etc/sysctl.d/60-longhorn.conf"
+
+// HugePagesSysctlConfigFile is the sysctl configuration file on the host persisting the HugePages allocated for SPDK.
+const HugePagesSysctlConfigFile = "/etc/sysctl.d/61-longhorn-hugepages.conf"
+
+// ModulesLoadConfigFile is the modules-load.d configuration file on the host persisting the kernel modules loaded by the installer.
+const ModulesLoadConfigFile = "/etc/modules-load.d/longhorn.conf"
+
+// TalosExtensionLabelPrefix is the prefix of the node labels Talos sets for the installed system extensions.
+const TalosExtensionLabelPrefix = "extensions.talos.dev/"
+
+// Preflight bundle directories. The bundle in the image is copied to the host directory,
+// since the package manager runs in the host mount namespace.
+const (
+	PreflightBundleHostDirectory  = "/var/lib/longhornctl/preflight-bundle"
+	PreflightBundleImageDirectory = "/bundle"
+)
+
+// Multipath configuration on the host.
+const (
+	MultipathConfigFile             = "/etc/multipath.conf"
+	MultipathDefaultConfigDir       = "/etc/multipath/conf.d"
+	MultipathLonghornConfigFileName = "longhorn.conf"
+)
+
+// PreflightInstallerStateFile is the file on the host recording the changes made by the preflight installer.
+const PreflightInstallerStateFile = "/var/lib/longhornctl/preflight-installer.json"
+
 type DependencyModuleType int
 
 const (
@@ -1,3 +1,15 @@
 package consts
 
 const SpdkPath = "/tmp/longhorn-spdk"
+
+// PCI device selectors of the allowed PCI devices option, resolved to the PCI addresses on each node.
+const (
+	PciSelectorSerialPrefix   = "serial="          // NVMe controllers of the serial number.
+	PciSelectorModelPrefix    = "model="           // NVMe controllers of the model, matching a shell pattern.
+	PciSelectorDiskByIDPrefix = "/dev/disk/by-id/" // PCI device of the disk.
+	PciSelectorAllUnusedNvme  = "all-unused-nvme"  // NVMe controllers not in use, without any filesystem or member signature.
+	PciSelectorNone           = "none"             // No PCI device, since it is not a valid address.
+)
+
+// PciClassNvme is the PCI class code of the NVM Express mass storage controllers.
+const PciClassNvme = "0x010802"
@@ -0,0 +1,75 @@
+package preflight
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	remote "github.com/longhorn/cli/pkg/remote/preflight"
+)
+
+// BundleExporter provide functions for the preflight bundle exporter.
+type BundleExporter struct {
+	remote.BundleExporterCmdOptions
+
+	OutputFilePath string
+
+	installer *Installer
+}
+
+// Init initializes the BundleExporter with the packages required by the preflight installer.
+func (local *BundleExporter) Init() error {
+	local.installer = &Installer{
+		OutputFilePath: local.OutputFilePath,
+	}
+	local.installer.EnableSpdk = local.EnableSpdk
+
+	return local.installer.Init()
+}
+
+// Run downloads the packages required by the preflight installer, and their dependencies,
+// to the target directory, so they can be installed on the nodes without access to the
+// distro repositories. The optional packages failed to be downloaded are reported as warnings.
+func (local *BundleExporter) Run() error {
+	packages := local.installer.packages
+	if local.EnableSpdk {
+		packages = append(packages, local.installer.spdkDepPackages...)
+	}
+
+	var required, optional []string
+	for _, pkg := range packages {
+		if pkg.Required {
+			required = append(required, pkg.Name)
+		} else {
+			optional = append(optional, pkg.Name)
+		}
+	}
+
+	if len(required) > 0 {
+		logrus.Infof("Downloading packages %v to %v", strings.Join(required, ", "), local.HostTargetDirectory)
+		if _, err := local.installer.packageManager.DownloadPackages(local.HostTargetDirectory, required...); err != nil {
+			return errors.Wrapf(err, "failed to download packages %v", strings.Join(required, ", "))
+		}
+		local.installer.logInfo("Successfully downloaded packages %v to %v", strings.Join(required, ", "), local.HostTargetDirectory)
+	}
+
+	for _, name := range optional {
+		logrus.Infof("Downloading package %v to %v", name, local.HostTargetDirectory)
+		if _, err := local.installer.packageManager.DownloadPackages(local.HostTargetDirectory, name); err != nil {
+			msg := fmt.Sprintf("Failed to download package %s: %v", name, err)
+			logrus.Warn(msg)
+			local.installer.collection.Log.Warn = append(local.installer.collection.Log.Warn, msg)
+			continue
+		}
+		local.installer.logInfo("Successfully downloaded package %v to %v", name, local.HostTargetDirectory)
+	}
+
+	return nil
+}
+
+// Output converts the collection to JSON and output to stdout or the output file.
+func (local *BundleExporter) Output() error {
+	return local.installer.Output()
+}
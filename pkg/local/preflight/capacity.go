@@ -0,0 +1,259 @@
+package preflight
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	resourcehelper "k8s.io/component-helpers/resource"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	lhtypes "github.com/longhorn/longhorn-manager/types"
+
+	"github.com/longhorn/cli/pkg/consts"
+
+	kubeutils "github.com/longhorn/cli/pkg/utils/kubernetes"
+)
+
+// checkCapacity checks if the node has enough allocatable CPU and memory for Longhorn.
+//
+// It compares the Node CR allocatable resources with Longhorn's minimum recommended
+// hardware, and estimates the CPU reserved by the instance manager pods based on the
+// guaranteed-instance-manager-cpu setting. When the V2 Data Engine is enabled, it also
+// verifies that the cores dedicated to spdk_tgt by the data-engine-cpu-mask setting fit
+// into the node. The remaining headroom after the current pod requests is reported.
+func (local *Checker) checkCapacity() error {
+	logrus.Info("Checking node CPU and memory capacity")
+	topic := formatTopic(consts.PreflightCheckTopicCapacity)
+
+	node, err := kubeutils.GetCurrentNode(local.kubeClient)
+	if err != nil {
+		return wrapInternalError(topic, errors.Wrap(err, "failed to get node"))
+	}
+
+	allocatableCPU := node.Status.Allocatable.Cpu()
+	allocatableMemory := node.Status.Allocatable.Memory()
+
+	for _, minimum := range []struct {
+		name        string
+		allocatable *resource.Quantity
+		required    resource.Quantity
+	}{
+		{"CPU", allocatableCPU, resource.MustParse(consts.LonghornMinimumNodeCPU)},
+		{"memory", allocatableMemory, resource.MustParse(consts.LonghornMinimumNodeMemory)},
+	} {
+		if minimum.allocatable.Cmp(minimum.required) < 0 {
+			local.collection.Log.Warn = append(local.collection.Log.Warn,
+				wrapMsgWithTopic(topic, fmt.Sprintf("Allocatable %s (%v) is below the minimum recommended %v for Longhorn", minimum.name, minimum.allocatable, &minimum.required)))
+		}
+	}
+
+	pods, err := kubeutils.ListNodeActivePods(local.kubeClient, node.Name)
+	if err != nil {
+		return wrapInternalError(topic, errors.Wrapf(err, "failed to list pods on node %v", node.Name))
+	}
+
+	requestedCPU := resource.NewMilliQuantity(0, resource.DecimalSI)
+	requestedMemory := resource.NewQuantity(0, resource.BinarySI)
+	runningDataEngines := map[string]bool{}
+	for _, pod := range pods.Items {
+		requests := resourcehelper.PodRequests(&pod, resourcehelper.PodResourcesOptions{})
+		requestedCPU.Add(*requests.Cpu())
+		requestedMemory.Add(*requests.Memory())
+
+		if pod.Labels[lhtypes.GetLonghornLabelComponentKey()] == lhtypes.LonghornLabelInstanceManager {
+			runningDataEngines[pod.Labels[lhtypes.GetLonghornLabelKey(lhtypes.LonghornLabelDataEngine)]] = true
+		}
+	}
+
+	dataEngines := []longhorn.DataEngineType{longhorn.DataEngineTypeV1}
+	v2Enabled, err := local.isV2DataEngineEnabled()
+	if err != nil {
+		return wrapInternalError(topic, err)
+	}
+	if v2Enabled {
+		dataEngines = append(dataEngines, longhorn.DataEngineTypeV2)
+	}
+
+	// The instance manager CPU is reserved per data engine. The pods already running on the node are
+	// included in the requested CPU, so only the missing instance managers are added to the reservation.
+	reservedCPU := resource.NewMilliQuantity(0, resource.DecimalSI)
+	for _, dataEngine := range dataEngines {
+		percentage, err := local.getGuaranteedInstanceManagerCPU(dataEngine)
+		if err != nil {
+			return wrapInternalError(topic, err)
+		}
+		guaranteedCPU := resource.NewMilliQuantity(int64(float64(allocatableCPU.MilliValue())*percentage/100), resource.DecimalSI)
+
+		if dataEngine == longhorn.DataEngineTypeV2 {
+			dedicatedCores, err := local.getDataEngineDedicatedCores()
+			if err != nil {
+				return wrapInternalError(topic, err)
+			}
+
+			dedicatedCPU := resource.NewQuantity(int64(dedicatedCores), resource.DecimalSI)
+			if dedicatedCPU.Cmp(*allocatableCPU) >= 0 {
+				local.collection.Log.Error = append(local.collection.Log.Error,
+					wrapMsgWithTopic(topic, fmt.Sprintf("V2 Data Engine dedicates %d CPU cores to spdk_tgt, but the node only has %v allocatable CPU", dedicatedCores, allocatableCPU)))
+			} else if guaranteedCPU.Cmp(*dedicatedCPU) < 0 {
+				local.collection.Log.Warn = append(local.collection.Log.Warn,
+					wrapMsgWithTopic(topic, fmt.Sprintf("V2 Data Engine guaranteed instance manager CPU (%v, %v%%) does not cover the %d CPU cores dedicated to spdk_tgt by %s",
+						guaranteedCPU, percentage, dedicatedCores, lhtypes.SettingNameDataEngineCPUMask)))
+			}
+		}
+
+		if runningDataEngines[string(dataEngine)] {
+			continue
+		}
+		reservedCPU.Add(*guaranteedCPU)
+	}
+
+	headroomCPU := allocatableCPU.DeepCopy()
+	headroomCPU.Sub(*requestedCPU)
+	headroomCPU.Sub(*reservedCPU)
+
+	headroomMemory := allocatableMemory.DeepCopy()
+	headroomMemory.Sub(*requestedMemory)
+
+	if headroomCPU.Sign() < 0 {
+		local.collection.Log.Error = append(local.collection.Log.Error,
+			wrapMsgWithTopic(topic, fmt.Sprintf("Insufficient CPU for Longhorn instance managers. Allocatable: %v, Requested: %v, Instance manager reservation: %v",
+				allocatableCPU, requestedCPU, reservedCPU)))
+	} else {
+		local.collection.Log.Info = append(local.collection.Log.Info,
+			wrapMsgWithTopic(topic, fmt.Sprintf("CPU headroom is %v. Allocatable: %v, Requested: %v, Instance manager reservation: %v",
+				&headroomCPU, allocatableCPU, requestedCPU, reservedCPU)))
+	}
+
+	if headroomMemory.Sign() < 0 {
+		local.collection.Log.Error = append(local.collection.Log.Error,
+			wrapMsgWithTopic(topic, fmt.Sprintf("Memory requests exceed allocatable memory. Allocatable: %v, Requested: %v", allocatableMemory, requestedMemory)))
+	} else {
+		local.collection.Log.Info = append(local.collection.Log.Info,
+			wrapMsgWithTopic(topic, fmt.Sprintf("Memory headroom is %v. Allocatable: %v, Requested: %v", &headroomMemory, allocatableMemory, requestedMemory)))
+	}
+
+	return nil
+}
+
+// getSettingValue returns the value of the Longhorn setting, or its default value
+// when Longhorn is not installed yet.
+func (local *Checker) getSettingValue(name lhtypes.SettingName) (string, error) {
+	setting, err := local.longhornClient.GetSetting(string(name))
+	if err == nil {
+		return setting.Value, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", errors.Wrapf(err, "failed to get setting %v", name)
+	}
+
+	definition, ok := lhtypes.GetSettingDefinition(name)
+	if !ok {
+		return "", errors.Errorf("setting %v is not defined", name)
+	}
+	logrus.Debugf("Setting %v is not found, using default value %v", name, definition.Default)
+	return definition.Default, nil
+}
+
+// getDataEngineSettingValue returns the value of the Longhorn setting for the given data engine.
+// Data engine specific settings can be either a JSON-formatted value, or a single value applied to all data engines.
+func (local *Checker) getDataEngineSettingValue(name lhtypes.SettingName, dataEngine longhorn.DataEngineType) (any, error) {
+	value, err := local.getSettingValue(name)
+	if err != nil {
+		return nil, err
+	}
+
+	definition, _ := lhtypes.GetSettingDefinition(name)
+
+	var values map[longhorn.DataEngineType]any
+	if lhtypes.IsJSONFormat(value) {
+		values, err = lhtypes.ParseDataEngineSpecificSetting(definition, value)
+	} else {
+		values, err = lhtypes.ParseSettingSingleValue(definition, value)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse setting %v value %v", name, value)
+	}
+
+	dataEngineValue, ok := values[dataEngine]
+	if !ok {
+		return nil, errors.Errorf("setting %v has no value for data engine %v", name, dataEngine)
+	}
+	return dataEngineValue, nil
+}
+
+// isV2DataEngineEnabled returns true if the V2 Data Engine is enabled by the
+// v2-data-engine setting or the --enable-spdk option.
+func (local *Checker) isV2DataEngineEnabled() (bool, error) {
+	if local.EnableSpdk {
+		return true, nil
+	}
+
+	value, err := local.getSettingValue(lhtypes.SettingNameV2DataEngine)
+	if err != nil {
+		return false, err
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse setting %v value %v", lhtypes.SettingNameV2DataEngine, value)
+	}
+	return enabled, nil
+}
+
+// getGuaranteedInstanceManagerCPU returns the percentage of the allocatable CPU
+// reserved for each instance manager pod of the data engine.
+func (local *Checker) getGuaranteedInstanceManagerCPU(dataEngine longhorn.DataEngineType) (float64, error) {
+	value, err := local.getDataEngineSettingValue(lhtypes.SettingNameGuaranteedInstanceManagerCPU, dataEngine)
+	if err != nil {
+		return 0, err
+	}
+
+	percentage, ok := value.(float64)
+	if !ok {
+		return 0, errors.Errorf("unexpected setting %v value %v", lhtypes.SettingNameGuaranteedInstanceManagerCPU, value)
+	}
+	return percentage, nil
+}
+
+// getDataEngineDedicatedCores returns the number of CPU cores dedicated to spdk_tgt.
+func (local *Checker) getDataEngineDedicatedCores() (int, error) {
+	value, err := local.getDataEngineSettingValue(lhtypes.SettingNameDataEngineCPUMask, longhorn.DataEngineTypeV2)
+	if err != nil {
+		return 0, err
+	}
+
+	cpuMask, ok := value.(string)
+	if !ok {
+		return 0, errors.Errorf("unexpected setting %v value %v", lhtypes.SettingNameDataEngineCPUMask, value)
+	}
+
+	return countCPUMaskCores(cpuMask)
+}
+
+// countCPUMaskCores returns the number of CPU cores in a hexadecimal CPU mask or CPU list.
+func countCPUMaskCores(cpuMask string) (int, error) {
+	hexMask, err := lhtypes.NormalizeCPUMask(cpuMask)
+	if err != nil {
+		return 0, err
+	}
+
+	mask, ok := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(hexMask), "0x"), 16)
+	if !ok {
+		return 0, errors.Errorf("invalid CPU mask %v", cpuMask)
+	}
+
+	cores := 0
+	for _, word := range mask.Bits() {
+		cores += bits.OnesCount(uint(word))
+	}
+	return cores, nil
+}
@@ -31,6 +31,7 @@ import (
 	pkgmgr "github.com/longhorn/cli/pkg/local/preflight/packagemanager"
 	remote "github.com/longhorn/cli/pkg/remote/preflight"
 	kubeutils "github.com/longhorn/cli/pkg/utils/kubernetes"
+	longhornutils "github.com/longhorn/cli/pkg/utils/longhorn"
 )
 
 // Checker provide functions for the preflight checker.
@@ -41,15 +42,16 @@ type Checker struct {
 
 	OutputFilePath string
 
-	kubeClient *kubeclient.Clientset
+	kubeClient     *kubeclient.Clientset
+	longhornClient *longhornutils.LonghornClient
 
 	osRelease      string
+	kernelRelease  string
 	packageManager pkgmgr.PackageManager
 
-	packages        []string
+	packages        []Package
 	modules         []string
-	services        []string
-	spdkDepPackages []string
+	spdkDepPackages []Package
 	spdkDepModules  []string
 
 	collection types.NodeCollection
@@ -69,6 +71,11 @@ func (local *Checker) Init() error {
 		return errors.Wrap(err, "failed to get Kubernetes clientset")
 	}
 
+	local.longhornClient, err = longhornutils.NewLonghornClientForConfig(config, local.Namespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to get Longhorn clientset")
+	}
+
 	osRelease, err := utils.GetOSRelease()
 	if err != nil {
 		return errors.Wrap(err, "failed to get OS release")
@@ -76,7 +83,7 @@ func (local *Checker) Init() error {
 	local.osRelease = osRelease
 	local.logger = logrus.WithField("os", local.osRelease)
 
-	if local.osRelease == fmt.Sprint(consts.OperatingSystemContainerOptimizedOS) {
+	if !hasPackageManager(local.osRelease) {
 		return nil
 	}
 
@@ -96,88 +103,28 @@ func (local *Checker) Init() error {
 		return err
 	}
 
-	packageManager, err := pkgmgr.New(packageManagerType, executor)
+	kernelRelease, err := executor.Execute([]string{}, "uname", []string{"-r"}, commontypes.ExecuteNoTimeout)
 	if err != nil {
 		return err
 	}
+	kernelRelease = strings.TrimRight(kernelRelease, "\n")
 
-	switch packageManagerType {
-	case pkgmgr.PackageManagerApt:
-		local.packageManager = packageManager
-		local.packages = []string{
-			"nfs-common", "open-iscsi", "cryptsetup", "dmsetup",
-		}
-		local.modules = []string{
-			"nfs", "dm_crypt",
-		}
-		local.services = []string{
-			"multipathd.service",
-		}
-		local.spdkDepPackages = []string{}
-		local.spdkDepModules = []string{
-			"nvme_tcp",
-			"uio_pci_generic",
-			"vfio_pci",
-		}
-
-	case pkgmgr.PackageManagerYum:
-		local.packageManager = packageManager
-		local.packages = []string{
-			"nfs-utils", "iscsi-initiator-utils", "cryptsetup", "device-mapper",
-		}
-		local.modules = []string{
-			"nfs", "iscsi_tcp", "dm_crypt",
-		}
-		local.services = []string{
-			"multipathd.service",
-		}
-		local.spdkDepPackages = []string{}
-		local.spdkDepModules = []string{
-			"nvme_tcp",
-			"uio_pci_generic",
-			"vfio_pci",
-		}
-
-	case pkgmgr.PackageManagerZypper, pkgmgr.PackageManagerTransactionalUpdate:
-		local.packageManager = packageManager
-		local.packages = []string{
-			"nfs-client", "open-iscsi", "cryptsetup", "device-mapper",
-		}
-		local.modules = []string{
-			"nfs", "iscsi_tcp", "dm_crypt",
-		}
-		local.services = []string{
-			"multipathd.service",
-		}
-		local.spdkDepPackages = []string{}
-		local.spdkDepModules = []string{
-			"nvme_tcp",
-			"uio_pci_generic",
-			"vfio_pci",
-		}
-
-	case pkgmgr.PackageManagerPacman:
-		local.packageManager = packageManager
-		local.packages = []string{
-			"nfs-utils", "open-iscsi", "cryptsetup", "device-mapper",
-		}
-		local.modules = []string{
-			"nfs", "iscsi_tcp", "dm_crypt",
-		}
-		local.services = []string{
-			"multipathd.service",
-		}
-		local.spdkDepPackages = []string{}
-		local.spdkDepModules = []string{
-			"nvme_tcp",
-			"uio_pci_generic",
-			"vfio_pci",
-		}
+	packageManager, err := pkgmgr.New(packageManagerType, executor)
+	if err != nil {
+		return err
+	}
 
-	default:
-		return errors.Errorf("operating system (%v) package manager (%s) is not supported", osRelease, packageManagerType)
+	deps, err := loadDependencies(packageManagerType, osRelease, kernelRelease, local.DependencyConfig)
+	if err != nil {
+		return err
 	}
 
+	local.packageManager = packageManager
+	local.kernelRelease = kernelRelease
+	local.packages = deps.packages
+	local.modules = packageNames(deps.modules)
+	local.spdkDepPackages = deps.spdkDepPackages
+	local.spdkDepModules = packageNames(deps.spdkDepModules)
 	return nil
 }
 
@@ -185,6 +132,8 @@ func (local *Checker) Init() error {
 func (local *Checker) Run() error {
 	checkTasks := []func() error{
 		local.checkKubeDNS,
+		local.checkKubeDNSResolution,
+		local.checkCapacity,
 	}
 
 	switch local.osRelease {
@@ -193,11 +142,18 @@ func (local *Checker) Run() error {
 		checkTasks = append(checkTasks,
 			local.checkContainerOptimizedOS,
 		)
+	case fmt.Sprint(consts.OperatingSystemTalos), fmt.Sprint(consts.OperatingSystemFlatcar):
+		logrus.Infof("Checking preflight for immutable OS %v", local.osRelease)
+		checkTasks = append(checkTasks,
+			local.checkImmutableOS,
+		)
 	default:
 		checkTasks = append(checkTasks,
 			local.checkIscsidService,
 			local.checkMultipathService,
 			local.checkNFSv4Support,
+			local.checkConflictingStorageStacks,
+			local.checkHostSettings,
 			func() error { return local.checkPackagesInstalled(false) },
 			func() error { return local.checkModulesLoaded(false) },
 		)
@@ -319,7 +275,7 @@ func (local *Checker) checkIscsidService() error {
 	case err == nil:
 		local.collection.Log.Info = append(local.collection.Log.Info,
 			wrapMsgWithTopic(topic, "Service iscsid is running"))
-		return nil
+		return local.checkServiceEnabled(topic, "iscsid.service", "iscsid.socket")
 	case isExitCode(err, 3):
 		// systemctl
 		// Exit code 3: Inactive
@@ -337,7 +293,7 @@ func (local *Checker) checkIscsidService() error {
 	case err == nil:
 		local.collection.Log.Info = append(local.collection.Log.Info,
 			wrapMsgWithTopic(topic, "Service iscsid is inactive, but it can still be activated by iscsid.socket"))
-		return nil
+		return local.checkServiceEnabled(topic, "iscsid.service", "iscsid.socket")
 	case isExitCode(err, 3):
 		// systemctl
 		// Exit code 3: Inactive
@@ -357,6 +313,28 @@ func (local *Checker) checkIscsidService() error {
 	return nil
 }
 
+// checkServiceEnabled checks if any of the units is enabled to start on boot.
+func (local *Checker) checkServiceEnabled(topic string, units ...string) error {
+	for _, unit := range units {
+		_, err := local.packageManager.CheckServiceEnabled(unit)
+		if err == nil {
+			local.collection.Log.Info = append(local.collection.Log.Info,
+				wrapMsgWithTopic(topic, fmt.Sprintf("%s is enabled", unit)))
+			return nil
+		}
+
+		// systemctl is-enabled exits with non-zero code when the unit is disabled or not found
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return wrapInternalError(topic, fmt.Errorf("failed to check if %s is enabled: %w", unit, err))
+		}
+	}
+
+	local.collection.Log.Warn = append(local.collection.Log.Warn,
+		wrapMsgWithTopic(topic, fmt.Sprintf("Neither %s is enabled, it will not be running after a reboot", strings.Join(units, " nor "))))
+	return nil
+}
+
 // checkHugePages checks if HugePages is enabled.
 func (local *Checker) checkHugePages() error {
 	logrus.Info("Checking if HugePages is enabled")
@@ -388,6 +366,14 @@ func (local *Checker) checkHugePages() error {
 
 	local.collection.Log.Info = append(local.collection.Log.Info, wrapMsgWithTopic(topic, "HugePages is enabled"))
 
+	if err := local.checkPersistentHugePages(topic, pages); err != nil {
+		return wrapInternalError(topic, errors.Wrap(err, "failed to check persistent HugePages"))
+	}
+
+	if err := local.checkNumaHugePages(topic); err != nil {
+		return wrapInternalError(topic, errors.Wrap(err, "failed to check HugePages per NUMA node"))
+	}
+
 	if err := local.checkHugePagesCapacity(); err != nil {
 		return wrapInternalError(topic, errors.Wrap(err, "failed to check hugepages-2Mi capacity"))
 	}
@@ -493,17 +479,45 @@ func (local *Checker) checkPackagesInstalled(spdkDependent bool) error {
 	var internalError = map[string]any{}
 
 	for _, pkg := range packages {
-		_, err := local.packageManager.CheckPackageInstalled(pkg)
+		_, err := local.packageManager.CheckPackageInstalled(pkg.Name)
 		if err != nil {
 			if isExitCode(err, 1) || errors.Is(err, pkgmgr.ErrPackageNotInstalled) {
-				local.collection.Log.Error = append(local.collection.Log.Error,
-					wrapMsgWithTopic(topic, fmt.Sprintf("%s is not installed (exit code: 1)", pkg)))
+				msg := wrapMsgWithTopic(topic, fmt.Sprintf("%s is not installed (exit code: 1)", pkg.Name))
+				if pkg.Required {
+					local.collection.Log.Error = append(local.collection.Log.Error, msg)
+				} else {
+					local.collection.Log.Warn = append(local.collection.Log.Warn, msg)
+				}
 			} else {
-				internalError[pkg] = err
+				internalError[pkg.Name] = err
 			}
-		} else {
+			continue
+		}
+
+		if pkg.Version == "" {
 			local.collection.Log.Info = append(local.collection.Log.Info,
-				wrapMsgWithTopic(topic, fmt.Sprintf("%s is installed", pkg)))
+				wrapMsgWithTopic(topic, fmt.Sprintf("%s is installed", pkg.Name)))
+			continue
+		}
+
+		version, err := local.packageManager.GetPackageVersion(pkg.Name)
+		if err != nil {
+			internalError[pkg.Name] = err
+			continue
+		}
+
+		satisfied, err := isVersionSatisfied(version, pkg.Version)
+		if err != nil {
+			internalError[pkg.Name] = err
+			continue
+		}
+
+		if satisfied {
+			local.collection.Log.Info = append(local.collection.Log.Info,
+				wrapMsgWithTopic(topic, fmt.Sprintf("%s %s is installed", pkg.Name, version)))
+		} else {
+			local.collection.Log.Error = append(local.collection.Log.Error,
+				wrapMsgWithTopic(topic, fmt.Sprintf("%s %s is installed, but does not satisfy version constraint %s", pkg.Name, version, pkg.Version)))
 		}
 	}
 
@@ -547,20 +561,43 @@ func (local *Checker) checkModulesLoaded(spdkDependent bool) error {
 
 	var internalError = map[string]any{}
 
+	persistedModules, err := getPersistedModules()
+	if err != nil {
+		internalError["modules-load.d"] = err
+	}
+
 	for _, mod := range modules {
 		logrus.Infof("Checking if module %s is loaded", mod)
 
 		err := local.packageManager.CheckModLoaded(mod)
 		if err != nil {
 			if isExitCode(err, 1) || errors.Is(err, pkgmgr.ErrPackageNotInstalled) {
-				local.collection.Log.Error = append(local.collection.Log.Error,
-					wrapMsgWithTopic(topic, fmt.Sprintf("%s is not loaded. (exit code: 1)", mod)))
+				availability, releases, err := getModuleAvailability(local.packageManager, local.kernelRelease, mod)
+				if err != nil {
+					internalError[mod] = err
+					continue
+				}
+
+				msg := fmt.Sprintf("%s is not loaded. (exit code: 1)", mod)
+				switch availability {
+				case moduleNotBooted:
+					msg = formatModuleNotBootedMsg(mod, local.kernelRelease, releases)
+				case moduleMissing:
+					msg = formatModuleMissingMsg(mod, local.kernelRelease)
+				}
+				local.collection.Log.Error = append(local.collection.Log.Error, wrapMsgWithTopic(topic, msg))
 			} else {
 				internalError[mod] = err
 			}
 		} else {
 			local.collection.Log.Info = append(local.collection.Log.Info,
 				wrapMsgWithTopic(topic, fmt.Sprintf("%s is loaded", mod)))
+
+			if persistedModules != nil && !persistedModules[normalizeModuleName(mod)] {
+				local.collection.Log.Warn = append(local.collection.Log.Warn,
+					wrapMsgWithTopic(topic, fmt.Sprintf("%s is not configured to be loaded on boot in modules-load.d and will be missing after a reboot. Use %s %s %s to persist it",
+						mod, consts.CmdLonghornctlRemote, consts.SubCmdInstall, consts.SubCmdPreflight)))
+			}
 		}
 	}
 
@@ -731,6 +768,12 @@ func isExitCode(err error, code int) bool {
 	return false
 }
 
+// isCommandFound checks if the command is installed on the host.
+func isCommandFound(packageManager pkgmgr.PackageManager, command string) bool {
+	_, err := packageManager.Execute([]string{}, "sh", []string{"-c", "command -v " + command}, commontypes.ExecuteNoTimeout)
+	return err == nil
+}
+
 func formatTopic(topics ...string) string {
 	s := ""
 	for _, topic := range topics {
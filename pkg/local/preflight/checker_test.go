@@ -1,11 +1,16 @@
 package preflight
 
 import (
+	"encoding/json"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/suite"
+
+	pkgmgr "github.com/longhorn/cli/pkg/local/preflight/packagemanager"
 )
 
 type UtilTestSuite struct {
@@ -75,6 +80,389 @@ func (s *UtilTestSuite) TestIsExitCode() {
 	s.False(isExitCode(nonExitErr, 1))
 }
 
+func (s *UtilTestSuite) TestCountCPUMaskCores() {
+	for mask, expected := range map[string]int{
+		"0x1":     1,
+		"0x3":     2,
+		"0xff":    8,
+		"0-1,2,5": 4,
+	} {
+		cores, err := countCPUMaskCores(mask)
+		s.NoError(err)
+		s.Equal(expected, cores, mask)
+	}
+
+	_, err := countCPUMaskCores("")
+	s.Error(err)
+}
+
+func (s *UtilTestSuite) TestParseDeviceMapperNames() {
+	output := "ceph--0a1b2c3d--osd--block--4e5f\t(253:0)\nlvmvg-pvc--6a7b\t(253:1)\n"
+	s.Equal([]string{"ceph--0a1b2c3d--osd--block--4e5f", "lvmvg-pvc--6a7b"}, parseDeviceMapperNames(output))
+
+	s.Empty(parseDeviceMapperNames("No devices found\n"))
+}
+
+func (s *UtilTestSuite) TestConflictingDeviceMapperPatterns() {
+	findStack := func(name string) string {
+		for _, stack := range conflictingStorageStacks {
+			if matchesAny(name, stack.deviceMapperPatterns) {
+				return stack.name
+			}
+		}
+		return ""
+	}
+
+	s.Equal("OpenEBS", findStack("lvmvg-pvc--0a1b2c3d--4e5f--6a7b--8c9d--0e1f2a3b4c5d"))
+	s.Equal("Rook/Ceph", findStack("ceph--0a1b2c3d--4e5f--6a7b--8c9d--0e1f2a3b4c5d-osd--block--6a7b8c9d--0e1f--2a3b--4c5d--6e7f8a9b0c1d"))
+	s.Empty(findStack("lvmvg-pvc--6a7b"))
+	s.Empty(findStack("vg0-pvc--backup"))
+	s.Empty(findStack("ceph--data"))
+}
+
+func (s *UtilTestSuite) TestParseIscsiSessionTargets() {
+	output := "tcp: [1] 10.42.0.10:3260,1 iqn.2019-10.io.longhorn:pvc-0a1b2c3d (non-flash)\n" +
+		"tcp: [2] 10.0.0.5:3260,1 iqn.2016-09.com.openebs.jiva:pvc-4e5f (non-flash)\n"
+	s.Equal([]string{"iqn.2019-10.io.longhorn:pvc-0a1b2c3d", "iqn.2016-09.com.openebs.jiva:pvc-4e5f"}, parseIscsiSessionTargets(output))
+}
+
+func (s *UtilTestSuite) TestParseMaxOpenFiles() {
+	limits := "Limit                     Soft Limit           Hard Limit           Units\n" +
+		"Max processes             unlimited            unlimited            processes\n" +
+		"Max open files            1024                 524288               files\n"
+	openFiles, err := parseMaxOpenFiles(limits)
+	s.NoError(err)
+	s.Equal(uint64(1024), openFiles)
+
+	openFiles, err = parseMaxOpenFiles("Max open files            unlimited            unlimited            files\n")
+	s.NoError(err)
+	s.Equal(^uint64(0), openFiles)
+
+	_, err = parseMaxOpenFiles("")
+	s.Error(err)
+}
+
+func (s *UtilTestSuite) TestGetRecommendedSysctls() {
+	s.Equal([]string{"fs.inotify.max_user_instances"}, sortedKeys(getRecommendedSysctls(false)))
+	s.Equal([]string{"fs.aio-max-nr", "fs.inotify.max_user_instances"}, sortedKeys(getRecommendedSysctls(true)))
+}
+
+func (s *UtilTestSuite) TestInstallerState() {
+	state := &installerState{}
+	s.True(state.isEmpty())
+
+	state.Packages = appendUnique(state.Packages, "open-iscsi")
+	state.Packages = appendUnique(state.Packages, "open-iscsi")
+	state.Modules = appendUnique(state.Modules, "iscsi_tcp")
+	s.Equal([]string{"open-iscsi"}, state.Packages)
+	s.Equal([]string{"iscsi_tcp"}, state.Modules)
+	s.False(state.isEmpty())
+}
+
+func (s *UtilTestSuite) TestParseSysctlConfig() {
+	value, found, err := parseSysctlConfig("# comment\nvm.nr_hugepages = 512\n-vm/nr_hugepages=1024\n", "vm.nr_hugepages")
+	s.NoError(err)
+	s.True(found)
+	s.Equal(uint64(1024), value)
+
+	_, found, err = parseSysctlConfig("fs.aio-max-nr = 1048576\n", "vm.nr_hugepages")
+	s.NoError(err)
+	s.False(found)
+
+	_, _, err = parseSysctlConfig("vm.nr_hugepages = many\n", "vm.nr_hugepages")
+	s.Error(err)
+}
+
+func (s *UtilTestSuite) TestParseKernelCmdlineHugePages() {
+	for cmdline, expected := range map[string]int{
+		"BOOT_IMAGE=/vmlinuz root=/dev/sda1":                               0,
+		"root=/dev/sda1 hugepages=1024":                                    1024,
+		"hugepagesz=2M hugepages=0:512,1:512":                              1024,
+		"hugepagesz=1G hugepages=2 hugepagesz=2M hugepages=256":            256,
+		"hugepagesz=2m hugepages=0:128 hugepagesz=1G hugepages=0:1,1:1 ro": 128,
+	} {
+		pages, err := parseKernelCmdlineHugePages(cmdline)
+		s.NoError(err, cmdline)
+		s.Equal(expected, pages, cmdline)
+	}
+
+	_, err := parseKernelCmdlineHugePages("hugepages=0:many")
+	s.Error(err)
+}
+
+func (s *UtilTestSuite) TestSplitHugePages() {
+	split := splitHugePages(1025, []int{0, 1})
+	s.Equal(map[int]int{0: 513, 1: 512}, split)
+	s.Equal("hugepagesz=2M hugepages=0:513,1:512", formatHugePagesKernelArgs(split))
+
+	s.Equal("hugepagesz=2M hugepages=1:1024", formatHugePagesKernelArgs(splitHugePages(1024, []int{1})))
+}
+
+func (s *UtilTestSuite) TestParseNumaNodeList() {
+	nodes, err := parseNumaNodeList("0\n")
+	s.NoError(err)
+	s.Equal([]int{0}, nodes)
+
+	nodes, err = parseNumaNodeList("0-2,4")
+	s.NoError(err)
+	s.Equal([]int{0, 1, 2, 4}, nodes)
+
+	_, err = parseNumaNodeList("0-x")
+	s.Error(err)
+}
+
+func (s *UtilTestSuite) TestParsePciSelectors() {
+	s.Nil(parsePciSelectors("none"))
+	s.Nil(parsePciSelectors(""))
+	s.Equal([]string{"0000:01:00.0", "serial=S1", "all-unused-nvme"}, parsePciSelectors("0000:01:00.0, serial=S1,all-unused-nvme"))
+	s.Equal("0000:82:00.0", normalizePciAddress("82:00.0"))
+	s.Equal("0000:82:00.0", normalizePciAddress("0000:82:00.0"))
+}
+
+func (s *UtilTestSuite) TestResolvePciSelector() {
+	devices := parsePciDevices("0000:00:1f.2\t0x010601\tahci\t\t\n" +
+		"0000:01:00.0\t0x010802\tnvme\tS1 \tSamsung SSD 980 PRO 1TB \n" +
+		"0000:02:00.0\t0x010802\tnvme\tS2\tINTEL SSDPE2KX010T8\n" +
+		"0000:03:00.0\t0x010802\tvfio-pci\t\t\n")
+	s.Len(devices, 4)
+
+	for selector, expected := range map[string][]string{
+		"serial=S1":       {"0000:01:00.0"},
+		"serial=S3":       nil,
+		"model=Samsung*":  {"0000:01:00.0"},
+		"model=*":         {"0000:01:00.0", "0000:02:00.0"},
+		"all-unused-nvme": {"0000:01:00.0", "0000:02:00.0"},
+		"03:00.0":         {"0000:03:00.0"},
+		"0000:00:1F.2":    {"0000:00:1f.2"},
+		"0000:04:00.0":    nil,
+	} {
+		var addresses []string
+		for _, device := range matchPciDevices(devices, newPciSelectorMatcher(selector)) {
+			addresses = append(addresses, device.Address)
+		}
+		s.Equal(expected, addresses, selector)
+	}
+}
+
+func (s *UtilTestSuite) TestMatchRecordedPciDevices() {
+	devices := parsePciDevices("0000:01:00.0\t0x010802\tnvme\tS1\tSamsung SSD 980 PRO 1TB\n" +
+		"0000:02:00.0\t0x010802\tvfio-pci\t\t\n" +
+		"0000:03:00.0\t0x010802\tuio_pci_generic\t\t\n" +
+		"0000:04:00.0\t0x010802\tvfio-pci\t\t\n")
+
+	addresses := func(devices []pciDevice) []string {
+		var addresses []string
+		for _, device := range devices {
+			addresses = append(addresses, device.Address)
+		}
+		return addresses
+	}
+
+	matched := matchPciDevices(devices, newPciSelectorMatcher("serial=S1"))
+	recorded := []string{"0000:01:00.0", "0000:02:00.0", "0000:03:00.0"}
+	s.Equal([]string{"0000:02:00.0", "0000:03:00.0"}, addresses(matchRecordedPciDevices(devices, recorded, matched)))
+	s.Equal([]string{"0000:03:00.0"}, addresses(matchRecordedPciDevices(devices, recorded, devices[1:2])))
+	s.Nil(matchRecordedPciDevices(devices, nil, matched))
+}
+
+func (s *UtilTestSuite) TestParsePciAddressFromSysfsPath() {
+	s.Equal("0000:01:00.0", parsePciAddressFromSysfsPath("/sys/devices/pci0000:00/0000:00:1d.0/0000:01:00.0/nvme/nvme0/nvme0n1/nvme0n1p1"))
+	s.Equal("", parsePciAddressFromSysfsPath("/sys/devices/virtual/block/loop0"))
+}
+
+func (s *UtilTestSuite) TestParseNvmeDisks() {
+	// 0000:01:00.0 and 0000:02:00.0 are the controllers of the multipath namespace nvme0n1.
+	disks := parseNvmeDisks("0000:01:00.0\tnvme0n1\tnvme0n2\n0000:02:00.0\tnvme0n1\n0000:03:00.0\n")
+	s.Equal(map[string]bool{"0000:01:00.0": true, "0000:02:00.0": true, "0000:03:00.0": true}, disks.nvmeControllers)
+	s.Equal([]string{"nvme0n1", "nvme0n2"}, disks.byAddress["0000:01:00.0"])
+	s.Empty(disks.byAddress["0000:03:00.0"])
+
+	s.Equal([]string{"0000:01:00.0", "0000:02:00.0"}, disks.addressesOf("nvme0n1"))
+	s.Equal([]string{"0000:01:00.0"}, disks.addressesOf("nvme0n2"))
+	s.Nil(disks.addressesOf("sda"))
+}
+
+func (s *UtilTestSuite) TestBlockDeviceUsage() {
+	usage := &blockDeviceUsage{
+		devices: parseLsblkPairs(`KNAME="nvme0n1" PKNAME="" TYPE="disk" FSTYPE="" MOUNTPOINT=""
+KNAME="nvme0n1p1" PKNAME="nvme0n1" TYPE="part" FSTYPE="vfat" MOUNTPOINT="/boot/efi"
+KNAME="nvme0n1p2" PKNAME="nvme0n1" TYPE="part" FSTYPE="LVM2_member" MOUNTPOINT=""
+KNAME="nvme1n1" PKNAME="" TYPE="disk" FSTYPE="swap" MOUNTPOINT="[SWAP]"
+KNAME="nvme2n1" PKNAME="" TYPE="disk" FSTYPE="" MOUNTPOINT=""
+KNAME="nvme3n1" PKNAME="" TYPE="disk" FSTYPE="" MOUNTPOINT=""
+KNAME="nvme3n1p1" PKNAME="nvme3n1" TYPE="part" FSTYPE="swap" MOUNTPOINT=""
+KNAME="nvme4n1" PKNAME="" TYPE="disk" FSTYPE="" MOUNTPOINT=""
+KNAME="nvme4n1p1" PKNAME="nvme4n1" TYPE="part" FSTYPE="crypto_LUKS" MOUNTPOINT=""
+KNAME="dm-0" PKNAME="nvme4n1p1" TYPE="crypt" FSTYPE="ext4" MOUNTPOINT="/"
+KNAME="nvme5n1" PKNAME="" TYPE="disk" FSTYPE="linux_raid_member" MOUNTPOINT=""
+KNAME="nvme6n1" PKNAME="" TYPE="disk" FSTYPE="linux_raid_member" MOUNTPOINT=""
+KNAME="md0" PKNAME="nvme5n1" TYPE="raid1" FSTYPE="" MOUNTPOINT=""
+KNAME="md0" PKNAME="nvme6n1" TYPE="raid1" FSTYPE="" MOUNTPOINT=""
+KNAME="nvme7n1" PKNAME="" TYPE="disk" FSTYPE="" MOUNTPOINT=""
+KNAME="nvme7n1p1" PKNAME="nvme7n1" TYPE="part" FSTYPE="zfs_member" MOUNTPOINT=""
+`),
+		swaps: parseSwaps("Filename\tType\tSize\tUsed\tPriority\n/dev/nvme3n1p1 partition\t8388604\t0\t-2\n"),
+	}
+
+	s.Equal("/dev/nvme0n1p1 is mounted at /boot/efi, /dev/nvme0n1p2 is an LVM physical volume", usage.inUseReason([]string{"nvme0n1"}))
+	s.Equal("/dev/nvme1n1 is an active swap", usage.inUseReason([]string{"nvme1n1"}))
+	s.Equal("", usage.inUseReason([]string{"nvme2n1"}))
+	s.Equal("/dev/nvme3n1p1 is an active swap", usage.inUseReason([]string{"nvme3n1"}))
+	s.Equal("/dev/nvme4n1p1 has a crypto_LUKS signature, /dev/dm-0 is mounted at /", usage.inUseReason([]string{"nvme4n1"}))
+	s.Equal("/dev/nvme6n1 has a linux_raid_member signature, /dev/md0 is an active raid1 device", usage.inUseReason([]string{"nvme6n1"}))
+	s.Equal("/dev/nvme7n1p1 has a zfs_member signature", usage.inUseReason([]string{"nvme7n1"}))
+	s.Equal("", usage.inUseReason(nil))
+}
+
+func (s *UtilTestSuite) TestParseInstalledKernelReleases() {
+	s.Equal([]string{"5.14.0-427.el9.x86_64", "5.14.0-503.el9.x86_64"},
+		parseInstalledKernelReleases("5.14.0-503.el9.x86_64\n5.14.0-427.el9.x86_64\n5.14.0-362.el9.x86_64\n", "5.14.0-362.el9.x86_64"))
+	s.Nil(parseInstalledKernelReleases("6.8.0-45-generic\n", "6.8.0-45-generic"))
+	s.Equal([]string{"5.14.0-503.el9.x86_64"},
+		parseInstalledKernelReleases("5.14.0-503.el9.x86_64\n5.14.0-427.el9.x86_64\n5.14.0-362.el9.x86_64\n", "5.14.0-427.el9.x86_64"))
+	s.Nil(parseInstalledKernelReleases("6.8.0-40-generic\n6.8.0-45-generic\n", "6.8.0-45-generic"))
+	s.Equal([]string{"6.8.0-47-generic", "6.8.0-100-generic"},
+		parseInstalledKernelReleases("6.8.0-100-generic\n6.8.0-45-generic\n6.8.0-47-generic\n", "6.8.0-45-generic"))
+
+	s.Equal("iscsi_tcp is installed for kernel 6.8.0-47-generic but not for the running kernel 6.8.0-45-generic, reboot required to activate modules",
+		formatModuleNotBootedMsg("iscsi_tcp", "6.8.0-45-generic", []string{"6.8.0-47-generic"}))
+}
+
+func (s *UtilTestSuite) TestParseModulesLoadLine() {
+	s.Equal("iscsi_tcp", parseModulesLoadLine("iscsi_tcp"))
+	s.Equal("dm_crypt", parseModulesLoadLine("  dm-crypt  "))
+	s.Equal("", parseModulesLoadLine("# comment"))
+	s.Equal("", parseModulesLoadLine("; comment"))
+	s.Equal("", parseModulesLoadLine(""))
+}
+
+func (s *UtilTestSuite) TestParseMultipathConfigDir() {
+	s.Equal("/etc/multipath/custom.d", parseMultipathConfigDir("defaults {\n    config_dir \"/etc/multipath/custom.d\"\n}\n"))
+	s.Equal("", parseMultipathConfigDir("defaults {\n    user_friendly_names yes\n}\n"))
+}
+
+func (s *UtilTestSuite) TestFindLonghornMultipathMaps() {
+	output := "mpatha (360000000000000000e00000000010001) dm-0 IET,VIRTUAL-DISK\n" +
+		"size=10G features='0' hwhandler='0' wp=rw\n" +
+		"mpathb (3600508b400105e210000900000490000) dm-1 HP,HSV210\n"
+	s.Equal([]string{"mpatha"}, findLonghornMultipathMaps(output))
+	s.Empty(findLonghornMultipathMaps(""))
+}
+
+func (s *UtilTestSuite) TestCompareVersions() {
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"2.1.9", "2.1.9", 0},
+		{"2.1.10", "2.1.9", 1},
+		{"2.1.9", "2.1.10", -1},
+		{"2.1.9-3ubuntu1", "2.1.9-3", 1},
+		{"1:2.0", "2.1", 1},
+		{"2.1~rc1", "2.1", -1},
+		{"6.2.1.9", "6.2.1.9-16.el9", -1},
+		{"2.1.009", "2.1.9", 0},
+	} {
+		s.Equal(tc.expected, compareVersions(tc.a, tc.b), "%s vs %s", tc.a, tc.b)
+	}
+}
+
+func (s *UtilTestSuite) TestIsVersionSatisfied() {
+	for _, tc := range []struct {
+		installed, constraint string
+		expected              bool
+	}{
+		{"2.1.9-150500.3.2", "2.1.9", true},
+		{"2.1.9-150500.3.2", "=2.1.9-150500.3.2", true},
+		{"2.1.9-150500.3.2", "2.1.9-150500.3.1", false},
+		{"2.1.9-3ubuntu1", ">= 2.1.8", true},
+		{"2.1.9-3ubuntu1", "<2.1.9", false},
+		{"1:2.1.9-3", "2.1.9", true},
+		{"2.1.9", "!=2.1.9", false},
+	} {
+		satisfied, err := isVersionSatisfied(tc.installed, tc.constraint)
+		s.NoError(err)
+		s.Equal(tc.expected, satisfied, "%s %s", tc.installed, tc.constraint)
+	}
+
+	_, err := isVersionSatisfied("2.1.9", ">=")
+	s.Error(err)
+}
+
+func (s *UtilTestSuite) TestLoadDependencies() {
+	deps, err := loadDependencies(pkgmgr.PackageManagerApt, "ubuntu", "6.8.0-45-generic", "")
+	s.NoError(err)
+	s.Equal([]string{"nfs-common", "open-iscsi", "cryptsetup", "dmsetup"}, packageNames(deps.packages))
+	s.Equal([]Package{{Name: "linux-modules-extra-6.8.0-45-generic", Required: false}}, deps.spdkDepPackages)
+
+	deps, err = loadDependencies(pkgmgr.PackageManagerTdnf, "photon", "", "")
+	s.NoError(err)
+	s.Contains(packageNames(deps.packages), "open-iscsi")
+
+	deps, err = loadDependencies(pkgmgr.PackageManagerTdnf, "azurelinux", "", "")
+	s.NoError(err)
+	s.Contains(packageNames(deps.packages), "iscsi-initiator-utils")
+
+	_, err = loadDependencies(pkgmgr.PackageManagerUnknown, "unknown", "", "")
+	s.Error(err)
+
+	configFilePath := filepath.Join(s.T().TempDir(), "dependencies.yaml")
+	s.NoError(os.WriteFile(configFilePath, []byte(`dependencies:
+  - packageManager: zypper
+    osRelease: sles
+    packages:
+      - name: open-iscsi
+        version: ">=2.1.9"
+    modules: [iscsi_tcp]
+`), 0644))
+
+	deps, err = loadDependencies(pkgmgr.PackageManagerZypper, "sles", "", configFilePath)
+	s.NoError(err)
+	s.Equal([]Package{{Name: "open-iscsi", Version: ">=2.1.9", Required: true}}, deps.packages)
+	s.Equal([]string{"iscsi_tcp"}, packageNames(deps.modules))
+
+	deps, err = loadDependencies(pkgmgr.PackageManagerZypper, "suse", "", configFilePath)
+	s.NoError(err)
+	s.Equal([]string{"nfs-client", "open-iscsi", "cryptsetup", "device-mapper"}, packageNames(deps.packages))
+
+	s.NoError(os.WriteFile(configFilePath, []byte(`dependencies:
+  - packageManager: zypper
+    packages:
+      - name: open-iscsi
+        version: ">>2.1.9"
+`), 0644))
+	_, err = loadDependencies(pkgmgr.PackageManagerZypper, "suse", "", configFilePath)
+	s.Error(err)
+}
+
+func (s *UtilTestSuite) TestGenerateTalosConfigSnippet() {
+	snippet, err := generateTalosConfigSnippet(nil, nil)
+	s.NoError(err)
+	s.Empty(snippet)
+
+	snippet, err = generateTalosConfigSnippet([]string{"iscsi-tools"}, []string{"nvme_tcp"})
+	s.NoError(err)
+	s.Contains(snippet, "    officialExtensions:\n      - siderolabs/iscsi-tools\n")
+	s.Contains(snippet, "---\n")
+	s.Contains(snippet, "    modules:\n      - name: nvme_tcp\n")
+}
+
+func (s *UtilTestSuite) TestGenerateFlatcarIgnitionSnippet() {
+	snippet, err := generateFlatcarIgnitionSnippet(nil, nil)
+	s.NoError(err)
+	s.Empty(snippet)
+
+	snippet, err = generateFlatcarIgnitionSnippet([]string{"iscsid.service"}, []string{"iscsi_tcp", "dm_crypt"})
+	s.NoError(err)
+
+	var config ignitionConfig
+	s.NoError(json.Unmarshal([]byte(snippet), &config))
+	s.Equal("3.3.0", config.Ignition.Version)
+	s.Equal([]ignitionUnit{{Name: "iscsid.service", Enabled: true}}, config.Systemd.Units)
+	s.Equal("/etc/modules-load.d/longhorn.conf", config.Storage.Files[0].Path)
+	s.Equal("data:,iscsi_tcp%0Adm_crypt%0A", config.Storage.Files[0].Contents.Source)
+}
+
 func TestUtils(t *testing.T) {
 	suite.Run(t, new(UtilTestSuite))
 }
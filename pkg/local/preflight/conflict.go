@@ -0,0 +1,303 @@
+package preflight
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	commontypes "github.com/longhorn/go-common-libs/types"
+
+	"github.com/longhorn/cli/pkg/consts"
+
+	kubeutils "github.com/longhorn/cli/pkg/utils/kubernetes"
+)
+
+// conflictingStorageStack describes the node components left behind by another storage
+// stack that compete with Longhorn for iscsid, nvme-tcp or device-mapper names.
+type conflictingStorageStack struct {
+	name string
+
+	// daemonSetPatterns are substrings of the node component DaemonSet names.
+	daemonSetPatterns []string
+	// services are the host services started by the storage stack.
+	services []string
+	// deviceMapperPatterns match the whole device-mapper device names.
+	deviceMapperPatterns []*regexp.Regexp
+	// iscsiTargetPrefixes are the IQN prefixes of the iSCSI targets exposed by the storage stack.
+	iscsiTargetPrefixes []string
+}
+
+// deviceMapperUUIDPattern matches a UUID in a device-mapper device name, where the dashes are doubled.
+const deviceMapperUUIDPattern = `[0-9a-f]{8}--[0-9a-f]{4}--[0-9a-f]{4}--[0-9a-f]{4}--[0-9a-f]{12}`
+
+var (
+	// openebsLVMDeviceMapperPattern matches the OpenEBS LVM LocalPV logical volume "pvc-<uuid>" of any volume group.
+	openebsLVMDeviceMapperPattern = regexp.MustCompile(`^.*[^-]-pvc--` + deviceMapperUUIDPattern + `$`)
+	// cephVolumeDeviceMapperPattern matches the ceph-volume logical volume "osd-<type>-<uuid>" of the volume group "ceph-<uuid>".
+	cephVolumeDeviceMapperPattern = regexp.MustCompile(`^ceph--` + deviceMapperUUIDPattern + `-osd--(block|db|wal)--` + deviceMapperUUIDPattern + `$`)
+)
+
+var conflictingStorageStacks = []conflictingStorageStack{
+	{
+		name:                 "OpenEBS",
+		daemonSetPatterns:    []string{"openebs"},
+		deviceMapperPatterns: []*regexp.Regexp{openebsLVMDeviceMapperPattern},
+		iscsiTargetPrefixes:  []string{"iqn.2016-09.com.openebs"},
+	},
+	{
+		name:                 "Rook/Ceph",
+		daemonSetPatterns:    []string{"csi-rbdplugin", "csi-cephfsplugin", "csi-nfsplugin", "rook-discover"},
+		services:             []string{"ceph.target"},
+		deviceMapperPatterns: []*regexp.Regexp{cephVolumeDeviceMapperPattern},
+	},
+	{
+		name:              "Portworx",
+		daemonSetPatterns: []string{"portworx"},
+		services:          []string{"portworx.service"},
+	},
+	{
+		name:                "NetApp Trident",
+		daemonSetPatterns:   []string{"trident-node"},
+		iscsiTargetPrefixes: []string{"iqn.1992-08.com.netapp"},
+	},
+	{
+		name:     "Linux SCSI target framework (tgt)",
+		services: []string{"tgtd.service"},
+	},
+	{
+		name:     "LIO target",
+		services: []string{"target.service"},
+	},
+}
+
+// checkConflictingStorageStacks checks if the node components of other storage stacks are present.
+//
+// Clusters migrating from OpenEBS, Rook/Ceph or other iSCSI/NVMe-oF CSI drivers often still have
+// their node components running. It looks for the known conflicting DaemonSet pods and host services,
+// and the device-mapper devices and iSCSI targets created by other drivers on the node.
+func (local *Checker) checkConflictingStorageStacks() error {
+	logrus.Info("Checking for conflicting storage stacks")
+	topic := formatTopic(consts.PreflightCheckTopicConflictingStorage)
+
+	var conflicts []string
+	var internalError = map[string]any{}
+
+	if found, err := local.findConflictingDaemonSets(); err != nil {
+		internalError["DaemonSets"] = err
+	} else {
+		conflicts = append(conflicts, found...)
+	}
+
+	if found, err := local.findConflictingServices(); err != nil {
+		internalError["services"] = err
+	} else {
+		conflicts = append(conflicts, found...)
+	}
+
+	if found, err := local.findConflictingDeviceMappers(); err != nil {
+		internalError["device-mapper"] = err
+	} else {
+		conflicts = append(conflicts, found...)
+	}
+
+	if found, err := local.findConflictingIscsiTargets(); err != nil {
+		internalError["iSCSI sessions"] = err
+	} else {
+		conflicts = append(conflicts, found...)
+	}
+
+	for _, conflict := range conflicts {
+		local.collection.Log.Warn = append(local.collection.Log.Warn, wrapMsgWithTopic(topic, conflict))
+	}
+
+	if len(internalError) > 0 {
+		return wrapAggregatedInternalError(topic, "Failed to check conflicting storage stacks:", internalError)
+	}
+
+	if len(conflicts) == 0 {
+		local.collection.Log.Info = append(local.collection.Log.Info,
+			wrapMsgWithTopic(topic, "No conflicting storage stack is detected"))
+	}
+
+	return nil
+}
+
+// findConflictingDaemonSets returns the conflicting DaemonSets that have pods running on the node.
+func (local *Checker) findConflictingDaemonSets() ([]string, error) {
+	node, err := kubeutils.GetCurrentNode(local.kubeClient)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get node")
+	}
+
+	pods, err := kubeutils.ListNodeActivePods(local.kubeClient, node.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pods on node %v", node.Name)
+	}
+
+	found := map[string]string{}
+	for _, pod := range pods.Items {
+		for _, owner := range pod.OwnerReferences {
+			if owner.Kind != "DaemonSet" {
+				continue
+			}
+
+			for _, stack := range conflictingStorageStacks {
+				if containsAny(owner.Name, stack.daemonSetPatterns) {
+					found[fmt.Sprintf("%s/%s", pod.Namespace, owner.Name)] = stack.name
+				}
+			}
+		}
+	}
+
+	var conflicts []string
+	for daemonSet, stack := range found {
+		conflicts = append(conflicts, fmt.Sprintf("%s DaemonSet %v is running on the node", stack, daemonSet))
+	}
+	sort.Strings(conflicts)
+	return conflicts, nil
+}
+
+// findConflictingServices returns the conflicting host services that are running.
+func (local *Checker) findConflictingServices() ([]string, error) {
+	var conflicts []string
+	for _, stack := range conflictingStorageStacks {
+		for _, service := range stack.services {
+			_, err := local.packageManager.GetServiceStatus(service)
+			switch {
+			case err == nil:
+				conflicts = append(conflicts, fmt.Sprintf("%s service %v is running", stack.name, service))
+			case isExitCode(err, 3), isExitCode(err, 4):
+				// systemctl
+				// Exit code 3: Inactive
+				// Exit code 4: Not found
+				continue
+			default:
+				return nil, errors.Wrapf(err, "failed to check %v", service)
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// findConflictingDeviceMappers returns the device-mapper devices created by other storage stacks.
+func (local *Checker) findConflictingDeviceMappers() ([]string, error) {
+	if !isCommandFound(local.packageManager, "dmsetup") {
+		// No device-mapper devices can be managed without dmsetup, nothing is conflicting.
+		return nil, nil
+	}
+
+	output, err := local.packageManager.Execute([]string{}, "dmsetup", []string{"ls"}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list device-mapper devices")
+	}
+
+	var conflicts []string
+	for _, name := range parseDeviceMapperNames(output) {
+		for _, stack := range conflictingStorageStacks {
+			if matchesAny(name, stack.deviceMapperPatterns) {
+				conflicts = append(conflicts, fmt.Sprintf("%s device-mapper device %v is present", stack.name, name))
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// findConflictingIscsiTargets returns the iSCSI sessions logged in to targets not exposed by Longhorn.
+func (local *Checker) findConflictingIscsiTargets() ([]string, error) {
+	if !isCommandFound(local.packageManager, "iscsiadm") {
+		// open-iscsi is not installed yet, so there is no iSCSI session.
+		return nil, nil
+	}
+
+	output, err := local.packageManager.Execute([]string{}, "iscsiadm", []string{"-m", "session"}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		// iscsiadm
+		// Exit code 21: No active sessions
+		if isExitCode(err, 21) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to list iSCSI sessions")
+	}
+
+	var conflicts []string
+	for _, target := range parseIscsiSessionTargets(output) {
+		if strings.HasPrefix(target, consts.LonghornIscsiTargetPrefix) {
+			continue
+		}
+
+		stackName := "Unknown storage stack"
+		for _, stack := range conflictingStorageStacks {
+			if hasAnyPrefix(target, stack.iscsiTargetPrefixes) {
+				stackName = stack.name
+				break
+			}
+		}
+		conflicts = append(conflicts, fmt.Sprintf("%s iSCSI session to target %v is active", stackName, target))
+	}
+	return conflicts, nil
+}
+
+// parseDeviceMapperNames returns the device names from the output of "dmsetup ls".
+//
+// Example output:
+//
+//	ceph--0a1b2c3d--osd--block--4e5f	(253:0)
+func parseDeviceMapperNames(output string) []string {
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "(") {
+			continue
+		}
+		names = append(names, fields[0])
+	}
+	return names
+}
+
+// parseIscsiSessionTargets returns the target IQNs from the output of "iscsiadm -m session".
+//
+// Example output:
+//
+//	tcp: [1] 10.42.0.10:3260,1 iqn.2019-10.io.longhorn:pvc-0a1b2c3d (non-flash)
+func parseIscsiSessionTargets(output string) []string {
+	var targets []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		targets = append(targets, fields[3])
+	}
+	return targets
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(s string, patterns []*regexp.Regexp) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
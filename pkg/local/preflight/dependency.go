@@ -0,0 +1,334 @@
+package preflight
+
+import (
+	_ "embed"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	pkgmgr "github.com/longhorn/cli/pkg/local/preflight/packagemanager"
+)
+
+//go:embed dependencies.yaml
+var defaultDependencyConfig []byte
+
+// kernelReleasePlaceholder is replaced with the running kernel release in the package names.
+const kernelReleasePlaceholder = "${KERNEL_RELEASE}"
+
+// dependencyConfig is the package, module and service dependencies of each distro.
+type dependencyConfig struct {
+	Dependencies []dependencyEntry `yaml:"dependencies"`
+}
+
+type dependencyEntry struct {
+	PackageManager pkgmgr.PackageManagerType `yaml:"packageManager"`
+	OSRelease      string                    `yaml:"osRelease,omitempty"`
+
+	Packages     []dependencyPackage `yaml:"packages,omitempty"`
+	Modules      []string            `yaml:"modules,omitempty"`
+	Services     []string            `yaml:"services,omitempty"`
+	SpdkPackages []dependencyPackage `yaml:"spdkPackages,omitempty"`
+	SpdkModules  []string            `yaml:"spdkModules,omitempty"`
+}
+
+type dependencyPackage struct {
+	Name     string `yaml:"name"`
+	Version  string `yaml:"version,omitempty"`
+	Optional bool   `yaml:"optional,omitempty"`
+}
+
+// dependencies holds the resolved dependencies of the node.
+type dependencies struct {
+	packages        []Package
+	modules         []Package
+	services        []Package
+	spdkDepPackages []Package
+	spdkDepModules  []Package
+}
+
+// Package is a dependency of Longhorn on the node.
+type Package struct {
+	Name     string
+	Version  string // Optional version constraint, for packages only.
+	Required bool
+}
+
+func requiredPackages(required bool, names ...string) []Package {
+	packages := make([]Package, 0, len(names))
+	for _, name := range names {
+		packages = append(packages, Package{Name: name, Required: required})
+	}
+
+	return packages
+}
+
+func packageNames(packages []Package) []string {
+	names := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		names = append(names, pkg.Name)
+	}
+
+	return names
+}
+
+// loadDependencies returns the dependencies of the package manager and OS release, from the embedded
+// dependency config overridden by the config file when provided.
+func loadDependencies(packageManagerType pkgmgr.PackageManagerType, osRelease, kernelRelease, configFilePath string) (*dependencies, error) {
+	config, err := parseDependencyConfig(defaultDependencyConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse embedded dependency config")
+	}
+
+	if configFilePath != "" {
+		content, err := os.ReadFile(configFilePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read dependency config %v", configFilePath)
+		}
+
+		override, err := parseDependencyConfig(content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse dependency config %v", configFilePath)
+		}
+
+		config.override(override)
+	}
+
+	entry := config.find(packageManagerType, osRelease)
+	if entry == nil {
+		return nil, errors.Errorf("operating system (%v) package manager (%s) is not supported", osRelease, packageManagerType)
+	}
+
+	toPackages := func(pkgs []dependencyPackage) []Package {
+		packages := make([]Package, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			packages = append(packages, Package{
+				Name:     strings.ReplaceAll(pkg.Name, kernelReleasePlaceholder, kernelRelease),
+				Version:  pkg.Version,
+				Required: !pkg.Optional,
+			})
+		}
+		return packages
+	}
+
+	return &dependencies{
+		packages:        toPackages(entry.Packages),
+		modules:         requiredPackages(true, entry.Modules...),
+		services:        requiredPackages(true, entry.Services...),
+		spdkDepPackages: toPackages(entry.SpdkPackages),
+		spdkDepModules:  requiredPackages(true, entry.SpdkModules...),
+	}, nil
+}
+
+// parseDependencyConfig parses and validates the dependency config.
+func parseDependencyConfig(content []byte) (*dependencyConfig, error) {
+	config := &dependencyConfig{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+
+	for i, entry := range config.Dependencies {
+		if entry.PackageManager == pkgmgr.PackageManagerUnknown {
+			return nil, errors.Errorf("dependency entry %d has no packageManager", i)
+		}
+
+		for _, pkg := range slices.Concat(entry.Packages, entry.SpdkPackages) {
+			if pkg.Name == "" {
+				return nil, errors.Errorf("dependency entry %d (%s) has a package without name", i, entry.PackageManager)
+			}
+			if pkg.Version == "" {
+				continue
+			}
+			if _, _, err := parseVersionConstraint(pkg.Version); err != nil {
+				return nil, errors.Wrapf(err, "invalid version constraint of package %s", pkg.Name)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// override replaces the entries with the same package manager and OS release as the entries of
+// the other config, and adds the others.
+func (config *dependencyConfig) override(other *dependencyConfig) {
+	for _, entry := range other.Dependencies {
+		replaced := false
+		for i := range config.Dependencies {
+			if config.Dependencies[i].PackageManager == entry.PackageManager && config.Dependencies[i].OSRelease == entry.OSRelease {
+				config.Dependencies[i] = entry
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			config.Dependencies = append(config.Dependencies, entry)
+		}
+	}
+}
+
+// find returns the entry of the package manager for the OS release, or the entry of the package
+// manager without OS release.
+func (config *dependencyConfig) find(packageManagerType pkgmgr.PackageManagerType, osRelease string) *dependencyEntry {
+	var fallback *dependencyEntry
+	for i, entry := range config.Dependencies {
+		if entry.PackageManager != packageManagerType {
+			continue
+		}
+		switch entry.OSRelease {
+		case osRelease:
+			return &config.Dependencies[i]
+		case "":
+			fallback = &config.Dependencies[i]
+		}
+	}
+	return fallback
+}
+
+var versionConstraintOperators = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// parseVersionConstraint splits the version constraint into the operator and the version. The
+// operator defaults to "=".
+func parseVersionConstraint(constraint string) (string, string, error) {
+	constraint = strings.TrimSpace(constraint)
+
+	operator := "="
+	for _, op := range versionConstraintOperators {
+		if strings.HasPrefix(constraint, op) {
+			operator = op
+			constraint = strings.TrimSpace(strings.TrimPrefix(constraint, op))
+			break
+		}
+	}
+
+	if constraint == "" || strings.ContainsAny(constraint, " <>=!") {
+		return "", "", errors.Errorf("invalid version constraint %q", constraint)
+	}
+
+	return operator, constraint, nil
+}
+
+// isVersionSatisfied checks if the installed version satisfies the version constraint. When the
+// constraint has no epoch or release, they are ignored from the installed version.
+func isVersionSatisfied(installed, constraint string) (bool, error) {
+	operator, version, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+
+	if !strings.Contains(version, ":") {
+		if i := strings.Index(installed, ":"); i >= 0 {
+			installed = installed[i+1:]
+		}
+	}
+	if !strings.Contains(version, "-") {
+		if i := strings.LastIndex(installed, "-"); i >= 0 {
+			installed = installed[:i]
+		}
+	}
+
+	result := compareVersions(installed, version)
+	switch operator {
+	case "=":
+		return result == 0, nil
+	case "!=":
+		return result != 0, nil
+	case ">":
+		return result > 0, nil
+	case ">=":
+		return result >= 0, nil
+	case "<":
+		return result < 0, nil
+	case "<=":
+		return result <= 0, nil
+	default:
+		return false, errors.Errorf("unknown version constraint operator %q", operator)
+	}
+}
+
+// compareVersions compares the versions in the dpkg manner, and returns -1, 0 or 1 when a is older
+// than, equal to or newer than b. The epoch is compared first. The other parts are compared by
+// alternating non-digit and digit segments, where letters sort before non-letters and "~" sorts
+// before anything, even the end of the version.
+func compareVersions(a, b string) int {
+	epochA, a := splitEpoch(a)
+	epochB, b := splitEpoch(b)
+	if result := compareNumbers(epochA, epochB); result != 0 {
+		return result
+	}
+
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			orderA, orderB := versionCharOrder(a), versionCharOrder(b)
+			if orderA != orderB {
+				return sign(orderA - orderB)
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		var numA, numB string
+		numA, a = splitDigits(a)
+		numB, b = splitDigits(b)
+		if result := compareNumbers(numA, numB); result != 0 {
+			return result
+		}
+	}
+
+	return 0
+}
+
+func splitEpoch(version string) (string, string) {
+	if i := strings.Index(version, ":"); i >= 0 {
+		return version[:i], version[i+1:]
+	}
+	return "0", version
+}
+
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareNumbers compares the numeric strings of arbitrary length.
+func compareNumbers(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+// versionCharOrder returns the sort weight of the first character of the non-digit segment.
+func versionCharOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case unicode.IsLetter(rune(s[0])):
+		return int(s[0])
+	default:
+		return int(s[0]) + 256
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func sign(i int) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	default:
+		return 0
+	}
+}
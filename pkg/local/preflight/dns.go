@@ -0,0 +1,71 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/cli/pkg/consts"
+)
+
+// checkKubeDNSResolution checks if the cluster DNS can resolve the Longhorn service
+// names and kubernetes.default from the node.
+//
+// Nodes whose pods cannot resolve the cluster DNS, because of CNI or NodeLocalDNS
+// problems, break the RWX volume NFS mounts via service names. The Longhorn services
+// are only resolved when they exist, so the check also works before Longhorn is installed.
+func (local *Checker) checkKubeDNSResolution() error {
+	logrus.Info("Checking if cluster DNS names can be resolved")
+	topic := formatTopic(consts.PreflightCheckTopicKubeDNS)
+
+	hosts := []string{consts.KubeDNSServiceKubernetes}
+	for _, serviceName := range []string{consts.LonghornServiceNameBackend, consts.LonghornServiceNameFrontend} {
+		_, err := local.kubeClient.CoreV1().Services(local.Namespace).Get(context.TODO(), serviceName, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				logrus.Debugf("Service %v is not found in namespace %v, skipping DNS resolution", serviceName, local.Namespace)
+				continue
+			}
+			return wrapInternalError(topic, errors.Wrapf(err, "failed to get service %v in namespace %v", serviceName, local.Namespace))
+		}
+		hosts = append(hosts, fmt.Sprintf("%s.%s.svc", serviceName, local.Namespace))
+	}
+
+	for _, host := range hosts {
+		addresses, latency, err := resolveHost(host, consts.KubeDNSResolveTimeout)
+		if err != nil {
+			local.collection.Log.Error = append(local.collection.Log.Error,
+				wrapMsgWithTopic(topic, fmt.Sprintf("Failed to resolve %v after %v: %v", host, latency.Round(time.Millisecond), err)))
+			continue
+		}
+
+		msg := fmt.Sprintf("Resolved %v to %v in %v", host, strings.Join(addresses, ","), latency.Round(time.Millisecond))
+		if latency > consts.KubeDNSResolveLatencyThreshold {
+			local.collection.Log.Warn = append(local.collection.Log.Warn,
+				wrapMsgWithTopic(topic, fmt.Sprintf("%s, which exceeds %v", msg, consts.KubeDNSResolveLatencyThreshold)))
+			continue
+		}
+		local.collection.Log.Info = append(local.collection.Log.Info, wrapMsgWithTopic(topic, msg))
+	}
+
+	return nil
+}
+
+// resolveHost resolves the host with the pod DNS configuration, and returns the
+// resolved addresses and the time taken.
+func resolveHost(host string, timeout time.Duration) ([]string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+	return addresses, time.Since(start), err
+}
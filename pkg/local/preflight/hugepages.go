@@ -0,0 +1,477 @@
+package preflight
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	commontypes "github.com/longhorn/go-common-libs/types"
+
+	"github.com/longhorn/cli/pkg/consts"
+
+	pkgmgr "github.com/longhorn/cli/pkg/local/preflight/packagemanager"
+)
+
+const (
+	numaNodeOnlinePath = "/sys/devices/system/node/online"
+
+	// numaNodeHugePagesPathFormat is the sysfs directory of the 2MiB HugePages of a NUMA node.
+	numaNodeHugePagesPathFormat = "/sys/devices/system/node/node%d/hugepages/hugepages-2048kB"
+
+	// hugePagesSysctlName is the kernel parameter of the default size HugePages, 2MiB on x86_64 and arm64.
+	hugePagesSysctlName = "vm.nr_hugepages"
+)
+
+// sysctlConfigDirectories are the directories of the sysctl configuration files, in the order of
+// precedence. A file in a former directory overrides the file of the same name in a latter one.
+var sysctlConfigDirectories = []string{"/etc/sysctl.d", "/run/sysctl.d", "/usr/lib/sysctl.d"}
+
+// numaHugePages holds the 2MiB HugePages of a NUMA node.
+type numaHugePages struct {
+	Node  int
+	Total int
+	Free  int
+}
+
+// configureHugePages persists the HugePages allocated by the SPDK setup script, which are otherwise
+// released on reboot. The total number of pages is persisted to the sysctl configuration file. When
+// the NUMA split is enabled, the pages are reallocated to the NUMA nodes of the allowed PCI devices,
+// and the per-node allocation is persisted with the hugepages kernel parameter.
+func (local *Installer) configureHugePages() error {
+	logrus.Info("Configuring HugePages")
+
+	pages := local.HugePageSize >> 1
+	if pages == 0 {
+		return nil
+	}
+
+	if local.HugePageNuma {
+		if err := local.configureNumaHugePages(pages); err != nil {
+			return err
+		}
+	}
+
+	line := fmt.Sprintf("%s = %d", hugePagesSysctlName, pages)
+	if local.DryRun {
+		local.logInfo("Would persist HugePages to %v: %v", consts.HugePagesSysctlConfigFile, line)
+		return nil
+	}
+
+	configFile := filepath.Join(consts.VolumeMountHostDirectory, consts.HugePagesSysctlConfigFile)
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %v", consts.HugePagesSysctlConfigFile)
+	}
+
+	content := "# Generated by longhornctl\n" + line + "\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %v", consts.HugePagesSysctlConfigFile)
+	}
+	local.state.Files = appendUnique(local.state.Files, consts.HugePagesSysctlConfigFile)
+	local.saveState()
+
+	if !local.HugePageNuma {
+		// Applying the total reallocates the pages across all NUMA nodes, so it is skipped when they are split.
+		if _, err := local.packageManager.Execute([]string{}, "sysctl", []string{"-p", consts.HugePagesSysctlConfigFile}, commontypes.ExecuteNoTimeout); err != nil {
+			return errors.Wrapf(err, "failed to apply %v", consts.HugePagesSysctlConfigFile)
+		}
+	}
+
+	local.logInfo("Successfully persisted HugePages to %v: %v", consts.HugePagesSysctlConfigFile, line)
+	return nil
+}
+
+// configureNumaHugePages allocates the pages evenly across the NUMA nodes of the allowed PCI devices,
+// releases the pages of the other NUMA nodes, and persists the allocation with the kernel parameters.
+func (local *Installer) configureNumaHugePages(pages int) error {
+	nodes, err := getPciDevicesNumaNodes(local.packageManager, local.pciAddresses)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		local.logWarn("No NUMA node is found for the allowed PCI devices (%v), skipped splitting HugePages across NUMA nodes", formatPciAllowed(local.pciAddresses))
+		return nil
+	}
+
+	onlineNodes, err := getOnlineNumaNodes(local.packageManager)
+	if err != nil {
+		return err
+	}
+
+	split := splitHugePages(pages, nodes)
+	kernelArgs := formatHugePagesKernelArgs(split)
+
+	if local.DryRun {
+		local.logInfo("Would allocate HugePages per NUMA node (%v) and persist them with kernel parameters %q",
+			formatNumaHugePagesSplit(split), kernelArgs)
+		return nil
+	}
+
+	for _, node := range onlineNodes {
+		path := filepath.Join(fmt.Sprintf(numaNodeHugePagesPathFormat, node), "nr_hugepages")
+		script := fmt.Sprintf("echo %d > %s", split[node], path)
+		if _, err := local.packageManager.Execute([]string{}, "sh", []string{"-c", script}, commontypes.ExecuteNoTimeout); err != nil {
+			return errors.Wrapf(err, "failed to allocate HugePages on NUMA node %d", node)
+		}
+	}
+	local.logInfo("Successfully allocated HugePages per NUMA node (%v)", formatNumaHugePagesSplit(split))
+
+	return local.persistKernelArgs(kernelArgs)
+}
+
+// persistKernelArgs adds the kernel parameters to all the boot entries with grubby. On hosts without
+// grubby, the parameters are reported for the user to add them to the boot loader configuration.
+func (local *Installer) persistKernelArgs(kernelArgs string) error {
+	if _, err := local.packageManager.Execute([]string{}, "sh", []string{"-c", "command -v grubby"}, commontypes.ExecuteNoTimeout); err != nil {
+		local.logWarn("grubby is not found. Please add kernel parameters %q to the boot loader configuration to persist HugePages per NUMA node", kernelArgs)
+		return nil
+	}
+
+	if _, err := local.packageManager.Execute([]string{}, "grubby", []string{"--update-kernel=ALL", "--args=" + kernelArgs}, commontypes.ExecuteNoTimeout); err != nil {
+		return errors.Wrapf(err, "failed to add kernel parameters %q", kernelArgs)
+	}
+	local.state.KernelArgs = appendUnique(local.state.KernelArgs, kernelArgs)
+	local.saveState()
+
+	local.logInfo("Successfully added kernel parameters %q to the boot entries", kernelArgs)
+	return nil
+}
+
+// checkPersistentHugePages checks if the required HugePages are allocated on boot, either by the
+// sysctl configuration files or by the hugepages kernel parameter.
+func (local *Checker) checkPersistentHugePages(topic string, pages int) error {
+	persisted, source, err := getPersistentHugePages(local.packageManager)
+	if err != nil {
+		return err
+	}
+
+	if persisted < pages {
+		local.collection.Log.Warn = append(local.collection.Log.Warn,
+			wrapMsgWithTopic(topic, fmt.Sprintf("HugePages are not persisted, %v pages are allocated on boot, required %v pages. Use %s %s %s --%s to persist them",
+				persisted, pages, consts.CmdLonghornctlRemote, consts.SubCmdInstall, consts.SubCmdPreflight, consts.CmdOptEnableSpdk)))
+		return nil
+	}
+
+	local.collection.Log.Info = append(local.collection.Log.Info,
+		wrapMsgWithTopic(topic, fmt.Sprintf("HugePages are persisted by %v, %v pages are allocated on boot", source, persisted)))
+	return nil
+}
+
+// checkNumaHugePages reports the HugePages of each NUMA node, and checks if the NUMA nodes of the
+// allowed PCI devices have HugePages allocated.
+func (local *Checker) checkNumaHugePages(topic string) error {
+	onlineNodes, err := getOnlineNumaNodes(local.packageManager)
+	if err != nil {
+		return err
+	}
+
+	hugePagesByNode := map[int]numaHugePages{}
+	for _, node := range onlineNodes {
+		hugePages, err := getNumaHugePages(local.packageManager, node)
+		if err != nil {
+			return err
+		}
+		hugePagesByNode[node] = hugePages
+
+		local.collection.Log.Info = append(local.collection.Log.Info,
+			wrapMsgWithTopic(topic, fmt.Sprintf("NUMA node %d has %d HugePages, %d free", node, hugePages.Total, hugePages.Free)))
+	}
+
+	state, err := loadInstallerState()
+	if err != nil {
+		return err
+	}
+
+	selection, err := selectPciDevices(local.packageManager, local.AllowPci, state.PciSelectors)
+	if err != nil {
+		return errors.Wrap(err, "failed to select allowed PCI devices")
+	}
+	local.logPciSelection(topic, selection)
+
+	for _, address := range selection.Allowed {
+		node, err := getPciDeviceNumaNode(local.packageManager, address)
+		if err != nil {
+			return err
+		}
+		if node < 0 {
+			continue
+		}
+
+		if hugePagesByNode[node].Total == 0 {
+			local.collection.Log.Warn = append(local.collection.Log.Warn,
+				wrapMsgWithTopic(topic, fmt.Sprintf("NUMA node %d of PCI device %v has no HugePages. Use %s %s %s --%s --%s to split them across the NUMA nodes of the allowed PCI devices",
+					node, address, consts.CmdLonghornctlRemote, consts.SubCmdInstall, consts.SubCmdPreflight, consts.CmdOptEnableSpdk, consts.CmdOptHugePageNuma)))
+		}
+	}
+	return nil
+}
+
+// getPersistentHugePages returns the number of HugePages allocated on boot, and its source. The
+// sysctl configuration files are applied after the kernel parameters, so they take precedence.
+func getPersistentHugePages(packageManager pkgmgr.PackageManager) (int, string, error) {
+	pages, file, err := getSysctlConfigHugePages()
+	if err != nil {
+		return 0, "", err
+	}
+	if file != "" {
+		return pages, file, nil
+	}
+
+	cmdline, err := packageManager.Execute([]string{}, "cat", []string{"/proc/cmdline"}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return 0, "", errors.Wrap(err, "failed to read /proc/cmdline")
+	}
+	pages, err = parseKernelCmdlineHugePages(cmdline)
+	if err != nil {
+		return 0, "", err
+	}
+	return pages, "kernel parameters", nil
+}
+
+// getSysctlConfigHugePages returns the HugePages set by the sysctl configuration files of the host,
+// and the file setting it. The files are applied in the lexicographic order of their names, and
+// /etc/sysctl.conf is applied last.
+func getSysctlConfigHugePages() (int, string, error) {
+	filesByName := map[string]string{}
+	for i := len(sysctlConfigDirectories) - 1; i >= 0; i-- {
+		matches, err := filepath.Glob(filepath.Join(consts.VolumeMountHostDirectory, sysctlConfigDirectories[i], "*.conf"))
+		if err != nil {
+			return 0, "", err
+		}
+		for _, match := range matches {
+			filesByName[filepath.Base(match)] = strings.TrimPrefix(match, consts.VolumeMountHostDirectory)
+		}
+	}
+
+	names := make([]string, 0, len(filesByName))
+	for name := range filesByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		files = append(files, filesByName[name])
+	}
+	files = append(files, "/etc/sysctl.conf")
+
+	pages, source := 0, ""
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(consts.VolumeMountHostDirectory, file))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return 0, "", errors.Wrapf(err, "failed to read %v", file)
+		}
+
+		value, found, err := parseSysctlConfig(string(content), hugePagesSysctlName)
+		if err != nil {
+			return 0, "", errors.Wrapf(err, "failed to parse %v", file)
+		}
+		if found {
+			pages, source = int(value), file
+		}
+	}
+	return pages, source, nil
+}
+
+// parseSysctlConfig returns the last value of the kernel parameter in the sysctl configuration.
+func parseSysctlConfig(content, name string) (uint64, bool, error) {
+	var value uint64
+	found := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		key, rawValue, ok := strings.Cut(strings.TrimPrefix(line, "-"), "=")
+		if !ok || strings.ReplaceAll(strings.TrimSpace(key), "/", ".") != name {
+			continue
+		}
+
+		parsed, err := strconv.ParseUint(strings.TrimSpace(rawValue), 10, 64)
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "invalid %v value %q", name, rawValue)
+		}
+		value, found = parsed, true
+	}
+	return value, found, nil
+}
+
+// parseKernelCmdlineHugePages returns the number of 2MiB HugePages allocated by the kernel parameters.
+// The hugepages parameter applies to the preceding hugepagesz parameter, or the default 2MiB size.
+// It is either a total number, or a comma-separated list of <node>:<pages>.
+//
+// Example:
+//
+//	hugepagesz=2M hugepages=0:512,1:512 hugepagesz=1G hugepages=2
+func parseKernelCmdlineHugePages(cmdline string) (int, error) {
+	total := 0
+	size := "2M"
+	for _, field := range strings.Fields(cmdline) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "hugepagesz":
+			size = strings.ToUpper(value)
+		case "hugepages":
+			if size != "2M" {
+				continue
+			}
+			pages, err := parseKernelHugePages(value)
+			if err != nil {
+				return 0, errors.Wrapf(err, "invalid kernel parameter %q", field)
+			}
+			total += pages
+		}
+	}
+	return total, nil
+}
+
+func parseKernelHugePages(value string) (int, error) {
+	total := 0
+	for _, item := range strings.Split(value, ",") {
+		_, pages, found := strings.Cut(item, ":")
+		if !found {
+			pages = item
+		}
+
+		num, err := strconv.Atoi(pages)
+		if err != nil {
+			return 0, err
+		}
+		total += num
+	}
+	return total, nil
+}
+
+// splitHugePages splits the pages evenly across the NUMA nodes. The remainder is allocated to the
+// former nodes.
+func splitHugePages(pages int, nodes []int) map[int]int {
+	split := map[int]int{}
+	for i, node := range nodes {
+		split[node] = pages / len(nodes)
+		if i < pages%len(nodes) {
+			split[node]++
+		}
+	}
+	return split
+}
+
+// formatHugePagesKernelArgs returns the kernel parameters allocating the 2MiB HugePages per NUMA node.
+func formatHugePagesKernelArgs(split map[int]int) string {
+	return fmt.Sprintf("hugepagesz=2M hugepages=%s", formatNumaHugePagesSplit(split))
+}
+
+// formatNumaHugePagesSplit formats the HugePages per NUMA node as <node>:<pages>, sorted by node.
+func formatNumaHugePagesSplit(split map[int]int) string {
+	nodes := make([]int, 0, len(split))
+	for node := range split {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+
+	items := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		items = append(items, fmt.Sprintf("%d:%d", node, split[node]))
+	}
+	return strings.Join(items, ",")
+}
+
+// getPciDevicesNumaNodes returns the sorted NUMA nodes of the PCI devices, excluding the devices
+// without NUMA affinity.
+func getPciDevicesNumaNodes(packageManager pkgmgr.PackageManager, addresses []string) ([]int, error) {
+	nodeSet := map[int]bool{}
+	for _, address := range addresses {
+		node, err := getPciDeviceNumaNode(packageManager, address)
+		if err != nil {
+			return nil, err
+		}
+		if node >= 0 {
+			nodeSet[node] = true
+		}
+	}
+
+	nodes := make([]int, 0, len(nodeSet))
+	for node := range nodeSet {
+		nodes = append(nodes, node)
+	}
+	sort.Ints(nodes)
+	return nodes, nil
+}
+
+// getPciDeviceNumaNode returns the NUMA node of the PCI device, or -1 if it has no NUMA affinity.
+func getPciDeviceNumaNode(packageManager pkgmgr.PackageManager, address string) (int, error) {
+	path := filepath.Join("/sys/bus/pci/devices", address, "numa_node")
+	return readSysfsInt(packageManager, path)
+}
+
+// getOnlineNumaNodes returns the online NUMA nodes of the host.
+func getOnlineNumaNodes(packageManager pkgmgr.PackageManager) ([]int, error) {
+	output, err := packageManager.Execute([]string{}, "cat", []string{numaNodeOnlinePath}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %v", numaNodeOnlinePath)
+	}
+	return parseNumaNodeList(output)
+}
+
+// getNumaHugePages returns the 2MiB HugePages of the NUMA node.
+func getNumaHugePages(packageManager pkgmgr.PackageManager, node int) (numaHugePages, error) {
+	dir := fmt.Sprintf(numaNodeHugePagesPathFormat, node)
+
+	total, err := readSysfsInt(packageManager, filepath.Join(dir, "nr_hugepages"))
+	if err != nil {
+		return numaHugePages{}, err
+	}
+	free, err := readSysfsInt(packageManager, filepath.Join(dir, "free_hugepages"))
+	if err != nil {
+		return numaHugePages{}, err
+	}
+	return numaHugePages{Node: node, Total: total, Free: free}, nil
+}
+
+// parseNumaNodeList parses the NUMA node list format of sysfs, for example "0-2,4".
+func parseNumaNodeList(list string) ([]int, error) {
+	var nodes []int
+	for _, item := range strings.Split(strings.TrimSpace(list), ",") {
+		if item == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(item, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid NUMA node list %q", list)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil {
+				return nil, errors.Wrapf(err, "invalid NUMA node list %q", list)
+			}
+		}
+
+		for node := start; node <= end; node++ {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+func readSysfsInt(packageManager pkgmgr.PackageManager, path string) (int, error) {
+	output, err := packageManager.Execute([]string{}, "cat", []string{path}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read %v", path)
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse %v value %q", path, output)
+	}
+	return value, nil
+}
@@ -0,0 +1,312 @@
+package preflight
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/longhorn/cli/pkg/consts"
+	"github.com/longhorn/cli/pkg/utils"
+
+	kubeutils "github.com/longhorn/cli/pkg/utils/kubernetes"
+)
+
+// immutableOSDependencies are the dependencies verified on the immutable operating systems without
+// package manager. They cannot be installed by the preflight installer, so the checker generates the
+// configuration snippet to apply instead.
+type immutableOSDependencies struct {
+	extensions  []string // Talos system extensions, verified by node label or by binary.
+	binaries    []string // Binaries shipped by the base image or the sysext images.
+	services    []string // systemd units enabled to start on boot.
+	modules     []string
+	spdkModules []string
+}
+
+var immutableOSDependencyMap = map[consts.OperatingSystem]immutableOSDependencies{
+	consts.OperatingSystemTalos: {
+		extensions:  []string{"iscsi-tools", "util-linux-tools"},
+		modules:     []string{"nfs", "iscsi_tcp", "dm_crypt"},
+		spdkModules: []string{"nvme_tcp", "uio_pci_generic", "vfio_pci"},
+	},
+	consts.OperatingSystemFlatcar: {
+		binaries:    []string{"iscsiadm", "mount.nfs", "cryptsetup"},
+		services:    []string{"iscsid.service", "iscsid.socket"},
+		modules:     []string{"nfs", "iscsi_tcp", "dm_crypt"},
+		spdkModules: []string{"nvme_tcp", "uio_pci_generic", "vfio_pci"},
+	},
+}
+
+// talosExtensionBinaries are the binaries shipped by the Talos system extensions, used to verify the
+// extensions when the node has no extension label.
+var talosExtensionBinaries = map[string]string{
+	"iscsi-tools":      "iscsiadm",
+	"util-linux-tools": "fstrim",
+}
+
+// hasPackageManager returns true if the OS release is managed with a package manager, unlike Container
+// Optimized OS and the immutable OSes.
+func hasPackageManager(osRelease string) bool {
+	return osRelease != fmt.Sprint(consts.OperatingSystemContainerOptimizedOS) && !isImmutableOS(osRelease)
+}
+
+// isImmutableOS returns true if the OS release is an immutable OS without package manager.
+func isImmutableOS(osRelease string) bool {
+	_, ok := immutableOSDependencyMap[consts.OperatingSystem(osRelease)]
+	return ok
+}
+
+// checkImmutableOS checks the system extensions, binaries, services and kernel modules on the immutable
+// OS, and reports the configuration snippet providing the missing ones.
+func (local *Checker) checkImmutableOS() error {
+	logrus.Infof("Checking dependencies on immutable OS %v", local.osRelease)
+	topic := formatTopic(consts.PreflightCheckTopicImmutableOS)
+
+	operatingSystem := consts.OperatingSystem(local.osRelease)
+	deps := immutableOSDependencyMap[operatingSystem]
+
+	var missingExtensions, missingBinaries, missingServices, missingModules []string
+
+	if len(deps.extensions) > 0 {
+		node, err := kubeutils.GetCurrentNode(local.kubeClient)
+		if err != nil {
+			return wrapInternalError(topic, errors.Wrap(err, "failed to get current node"))
+		}
+
+		for _, extension := range deps.extensions {
+			if version, ok := node.Labels[consts.TalosExtensionLabelPrefix+extension]; ok {
+				local.collection.Log.Info = append(local.collection.Log.Info,
+					wrapMsgWithTopic(topic, fmt.Sprintf("System extension %s %s is installed", extension, version)))
+				continue
+			}
+			if binary, ok := talosExtensionBinaries[extension]; ok && utils.IsCommandAvailableOnHost(binary) {
+				local.collection.Log.Info = append(local.collection.Log.Info,
+					wrapMsgWithTopic(topic, fmt.Sprintf("System extension %s is installed", extension)))
+				continue
+			}
+
+			missingExtensions = append(missingExtensions, extension)
+			local.collection.Log.Error = append(local.collection.Log.Error,
+				wrapMsgWithTopic(topic, fmt.Sprintf("System extension %s is not installed", extension)))
+		}
+	}
+
+	for _, binary := range deps.binaries {
+		if utils.IsCommandAvailableOnHost(binary) {
+			local.collection.Log.Info = append(local.collection.Log.Info,
+				wrapMsgWithTopic(topic, fmt.Sprintf("%s is available", binary)))
+			continue
+		}
+
+		missingBinaries = append(missingBinaries, binary)
+		local.collection.Log.Error = append(local.collection.Log.Error,
+			wrapMsgWithTopic(topic, fmt.Sprintf("%s is not found in the base image nor the sysext images", binary)))
+	}
+
+	if len(deps.services) > 0 {
+		enabled, err := isSystemdUnitEnabledOnHost(deps.services...)
+		if err != nil {
+			return wrapInternalError(topic, err)
+		}
+		if enabled {
+			local.collection.Log.Info = append(local.collection.Log.Info,
+				wrapMsgWithTopic(topic, fmt.Sprintf("%s is enabled", strings.Join(deps.services, " or "))))
+		} else {
+			missingServices = append(missingServices, deps.services[0])
+			local.collection.Log.Error = append(local.collection.Log.Error,
+				wrapMsgWithTopic(topic, fmt.Sprintf("Neither %s is enabled", strings.Join(deps.services, " nor "))))
+		}
+	}
+
+	modules := deps.modules
+	if local.EnableSpdk {
+		modules = append(slices.Clone(modules), deps.spdkModules...)
+	}
+	for _, module := range modules {
+		// Built-in modules are not listed in /proc/modules, but are present in /sys/module.
+		if _, err := os.Stat(filepath.Join("/sys/module", module)); err == nil {
+			local.collection.Log.Info = append(local.collection.Log.Info,
+				wrapMsgWithTopic(topic, fmt.Sprintf("Module %s is loaded", module)))
+			continue
+		}
+
+		missingModules = append(missingModules, module)
+		local.collection.Log.Error = append(local.collection.Log.Error,
+			wrapMsgWithTopic(topic, fmt.Sprintf("Module %s is not loaded", module)))
+	}
+
+	var snippet string
+	var err error
+	switch operatingSystem {
+	case consts.OperatingSystemTalos:
+		snippet, err = generateTalosConfigSnippet(missingExtensions, missingModules)
+	case consts.OperatingSystemFlatcar:
+		if len(missingBinaries) > 0 {
+			local.collection.Log.Warn = append(local.collection.Log.Warn,
+				wrapMsgWithTopic(topic, fmt.Sprintf("Provide %s with a sysext image, see https://www.flatcar.org/docs/latest/provisioning/sysext/", strings.Join(missingBinaries, ", "))))
+		}
+		snippet, err = generateFlatcarIgnitionSnippet(missingServices, missingModules)
+	}
+	if err != nil {
+		return wrapInternalError(topic, errors.Wrap(err, "failed to generate configuration snippet"))
+	}
+
+	if snippet != "" {
+		local.collection.Log.Warn = append(local.collection.Log.Warn,
+			wrapMsgWithTopic(topic, fmt.Sprintf("Apply the following configuration to provide the missing dependencies:\n%s", snippet)))
+	}
+
+	return nil
+}
+
+// isSystemdUnitEnabledOnHost checks if any of the units is enabled in the host systemd configuration,
+// or by the vendor configuration of the base image.
+func isSystemdUnitEnabledOnHost(units ...string) (bool, error) {
+	for _, unit := range units {
+		for _, dir := range []string{"etc/systemd/system", "usr/lib/systemd/system"} {
+			pattern := filepath.Join(consts.VolumeMountHostDirectory, dir, "*.wants", unit)
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return false, errors.Wrapf(err, "failed to check if %s is enabled", unit)
+			}
+			if len(matches) > 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// generateTalosConfigSnippet generates the Image Factory schematic installing the missing system extensions,
+// and the machine configuration patch loading the missing kernel modules.
+func generateTalosConfigSnippet(extensions, modules []string) (string, error) {
+	var documents []string
+
+	if len(extensions) > 0 {
+		officialExtensions := make([]string, 0, len(extensions))
+		for _, extension := range extensions {
+			officialExtensions = append(officialExtensions, "siderolabs/"+extension)
+		}
+
+		schematic := map[string]any{
+			"customization": map[string]any{
+				"systemExtensions": map[string]any{
+					"officialExtensions": officialExtensions,
+				},
+			},
+		}
+		out, err := marshalYAML(schematic)
+		if err != nil {
+			return "", err
+		}
+		documents = append(documents, "# Image Factory schematic (https://factory.talos.dev), upgrade the node to the resulting installer image\n"+out)
+	}
+
+	if len(modules) > 0 {
+		kernelModules := make([]map[string]string, 0, len(modules))
+		for _, module := range modules {
+			kernelModules = append(kernelModules, map[string]string{"name": module})
+		}
+
+		patch := map[string]any{
+			"machine": map[string]any{
+				"kernel": map[string]any{
+					"modules": kernelModules,
+				},
+			},
+		}
+		out, err := marshalYAML(patch)
+		if err != nil {
+			return "", err
+		}
+		documents = append(documents, "# Machine configuration patch, apply with talosctl patch machineconfig\n"+out)
+	}
+
+	return strings.Join(documents, "---\n"), nil
+}
+
+// marshalYAML marshals the value to YAML indented with 2 spaces, as in the Talos documentation.
+func marshalYAML(value any) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+type ignitionConfig struct {
+	Ignition struct {
+		Version string `json:"version"`
+	} `json:"ignition"`
+	Storage *ignitionStorage `json:"storage,omitempty"`
+	Systemd *ignitionSystemd `json:"systemd,omitempty"`
+}
+
+type ignitionStorage struct {
+	Files []ignitionFile `json:"files"`
+}
+
+type ignitionFile struct {
+	Path     string `json:"path"`
+	Mode     int    `json:"mode"`
+	Contents struct {
+		Source string `json:"source"`
+	} `json:"contents"`
+}
+
+type ignitionSystemd struct {
+	Units []ignitionUnit `json:"units"`
+}
+
+type ignitionUnit struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// generateFlatcarIgnitionSnippet generates the Ignition configuration enabling the missing services, and
+// persisting the missing kernel modules in modules-load.d. The configuration is merged into the node
+// configuration, and applied when the node is provisioned.
+func generateFlatcarIgnitionSnippet(services, modules []string) (string, error) {
+	if len(services) == 0 && len(modules) == 0 {
+		return "", nil
+	}
+
+	config := ignitionConfig{}
+	config.Ignition.Version = "3.3.0"
+
+	if len(modules) > 0 {
+		file := ignitionFile{
+			Path: consts.ModulesLoadConfigFile,
+			Mode: 0644,
+		}
+		file.Contents.Source = "data:," + url.PathEscape(strings.Join(modules, "\n")+"\n")
+		config.Storage = &ignitionStorage{Files: []ignitionFile{file}}
+	}
+
+	if len(services) > 0 {
+		units := make([]ignitionUnit, 0, len(services))
+		for _, service := range services {
+			units = append(units, ignitionUnit{Name: service, Enabled: true})
+		}
+		config.Systemd = &ignitionSystemd{Units: units}
+	}
+
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
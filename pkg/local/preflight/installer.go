@@ -43,6 +43,7 @@ type Installer struct {
 	kubeClient *kubeclient.Clientset
 
 	osRelease      string
+	kernelRelease  string
 	packageManager pkgmgr.PackageManager
 
 	packages        []Package
@@ -51,21 +52,11 @@ type Installer struct {
 	spdkDepPackages []Package
 	spdkDepModules  []Package
 
-	collection types.NodeCollection
-}
+	pciAddresses []string // PCI addresses resolved from the allowed PCI device selectors.
 
-type Package struct {
-	Name     string
-	Required bool
-}
-
-func requiredPackages(required bool, names ...string) []Package {
-	packages := make([]Package, 0, len(names))
-	for _, name := range names {
-		packages = append(packages, Package{Name: name, Required: required})
-	}
+	state *installerState
 
-	return packages
+	collection types.NodeCollection
 }
 
 // Init initializes the Installer.
@@ -89,6 +80,10 @@ func (local *Installer) Init() error {
 	local.osRelease = osRelease
 	local.logger = logrus.WithField("os", local.osRelease)
 
+	if isImmutableOS(osRelease) {
+		return errors.Errorf("operating system (%v) has no package manager. Use '%s %s %s' to get the configuration providing the missing dependencies", osRelease, consts.CmdLonghornctlRemote, consts.SubCmdCheck, consts.SubCmdPreflight)
+	}
+
 	packageManagerType, err := utils.GetPackageManagerType(osRelease)
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to get package manager")
@@ -116,56 +111,47 @@ func (local *Installer) Init() error {
 		return err
 	}
 
-	switch packageManagerType {
-	case pkgmgr.PackageManagerApt:
-		local.packageManager = pkgMgr
-		local.packages = requiredPackages(true, "nfs-common", "open-iscsi", "cryptsetup")
-		local.modules = requiredPackages(true, "nfs", "dm_crypt")
-		local.services = requiredPackages(true, "iscsid")
-		// Kernel module nvme_tcp is shipped with the distro by default since ubuntu 26.04. linux-modules-extra is not required any more.
-		// The installation is kept for older ubuntu versions to ensure the module is present, and it won't cause issue on newer versions as the package manager will skip installation if the package is not found.
-		local.spdkDepPackages = requiredPackages(false, "linux-modules-extra-"+kernelRelease)
-		local.spdkDepModules = requiredPackages(true, "nvme_tcp", "uio_pci_generic", "vfio_pci")
-		return nil
-
-	case pkgmgr.PackageManagerYum:
-		local.packageManager = pkgMgr
-		local.packages = requiredPackages(true, "nfs-utils", "iscsi-initiator-utils", "cryptsetup")
-		local.modules = requiredPackages(true, "nfs", "iscsi_tcp", "dm_crypt")
-		local.services = requiredPackages(true, "iscsid")
-		local.spdkDepPackages = requiredPackages(true)
-		local.spdkDepModules = requiredPackages(true, "nvme_tcp", "uio_pci_generic", "vfio_pci")
-		return nil
-
-	case pkgmgr.PackageManagerZypper, pkgmgr.PackageManagerTransactionalUpdate:
-		local.packageManager = pkgMgr
-		local.packages = requiredPackages(true, "nfs-client", "open-iscsi", "cryptsetup")
-		local.modules = requiredPackages(true, "nfs", "iscsi_tcp", "dm_crypt")
-		local.services = requiredPackages(true, "iscsid")
-		local.spdkDepPackages = requiredPackages(true)
-		local.spdkDepModules = requiredPackages(true, "nvme_tcp", "uio_pci_generic", "vfio_pci")
-		return nil
+	if local.BundleDir != "" {
+		if err := pkgMgr.UseLocalRepository(local.BundleDir); err != nil {
+			return errors.Wrapf(err, "failed to use preflight bundle %v", local.BundleDir)
+		}
+		local.logger = local.logger.WithField("bundle", local.BundleDir)
+	}
 
-	case pkgmgr.PackageManagerPacman:
-		local.packageManager = pkgMgr
-		local.packages = requiredPackages(true, "nfs-utils", "open-iscsi", "cryptsetup")
-		local.modules = requiredPackages(true, "nfs", "iscsi_tcp", "dm_crypt")
-		local.services = requiredPackages(true, "iscsid")
-		local.spdkDepPackages = requiredPackages(true)
-		local.spdkDepModules = requiredPackages(true, "nvme_tcp", "uio_pci_generic", "vfio_pci")
-		return nil
+	local.state, err = loadInstallerState()
+	if err != nil {
+		return err
+	}
 
-	default:
-		return errors.Errorf("Operating system (%v) package manager (%s) is not supported", osRelease, packageManagerType)
+	deps, err := loadDependencies(packageManagerType, osRelease, kernelRelease, local.DependencyConfig)
+	if err != nil {
+		return err
 	}
+
+	local.packageManager = pkgMgr
+	local.kernelRelease = kernelRelease
+	local.packages = deps.packages
+	local.modules = deps.modules
+	local.services = deps.services
+	local.spdkDepPackages = deps.spdkDepPackages
+	local.spdkDepModules = deps.spdkDepModules
+	return nil
 }
 
 func (local *Installer) Run() error {
 	var rebootRequired bool
 	var err error
 
+	defer local.cleanupBundle()
+
+	if err := local.runHook("pre-install", local.PreHook); err != nil {
+		return err
+	}
+
 	if local.UpdatePackages {
-		if err := local.updatePackageList(); err != nil {
+		if local.BundleDir != "" {
+			logrus.Infof("Skipping package list update, installing packages from preflight bundle %v", local.BundleDir)
+		} else if err := local.updatePackageList(); err != nil {
 			return err
 		}
 	}
@@ -176,19 +162,38 @@ func (local *Installer) Run() error {
 	}
 
 	if rebootRequired {
-		logrus.Warn("Need to reboot the system and execute longhornctl install preflight again")
-		local.collection.Log.Warn = append(local.collection.Log.Warn, "Need to reboot the system and execute longhornctl install preflight again")
-		return nil
+		if local.DryRun {
+			local.logInfo("Would need to reboot the system and execute longhornctl install preflight again")
+			return nil
+		}
+
+		return local.requireReboot()
 	}
 
 	if err := local.probeModules(consts.DependencyModuleDefault); err != nil {
 		return err
 	}
 
+	if local.collection.RebootRequired {
+		return local.requireReboot()
+	}
+
 	if err := local.startServices(); err != nil {
 		return err
 	}
 
+	if err := local.runHook("post-install", local.PostHook); err != nil {
+		return err
+	}
+
+	if err := local.configureSysctl(); err != nil {
+		return err
+	}
+
+	if err := local.configureMultipath(); err != nil {
+		return err
+	}
+
 	if local.EnableSpdk {
 		// Load ublk_drv module if supported by the kernel
 		if _, err := local.packageManager.Modprobe("ublk_drv", "--dry-run"); err != nil {
@@ -203,10 +208,22 @@ func (local *Installer) Run() error {
 			return err
 		}
 
+		if local.collection.RebootRequired {
+			return local.requireReboot()
+		}
+
+		if err := local.selectPciDevices(); err != nil {
+			return err
+		}
+
 		if err := local.configureSPDKEnv(); err != nil {
 			return err
 		}
 
+		if err := local.configureHugePages(); err != nil {
+			return err
+		}
+
 		// At this point, HugePages size is updated on the node, but won't be visible to the k8s cluster until kubelet is restarted.
 		if err := local.restartKubelet(); err != nil {
 			return err
@@ -216,6 +233,20 @@ func (local *Installer) Run() error {
 	return nil
 }
 
+// cleanupBundle removes the preflight bundle copied to the host from the bundle image, which is copied
+// again by each run of the preflight installer DaemonSet. A bundle directory provided by the user is kept.
+func (local *Installer) cleanupBundle() {
+	if local.BundleDir != consts.PreflightBundleHostDirectory {
+		return
+	}
+
+	if err := os.RemoveAll(filepath.Join(consts.VolumeMountHostDirectory, consts.PreflightBundleHostDirectory)); err != nil {
+		local.logWarn("Failed to remove preflight bundle %v: %v", consts.PreflightBundleHostDirectory, err)
+		return
+	}
+	logrus.Infof("Removed preflight bundle %v", consts.PreflightBundleHostDirectory)
+}
+
 // Output converts the collection to JSON and output to stdout or the output file.
 func (local *Installer) Output() error {
 	local.logger.Trace("Outputting preflight checks results")
@@ -231,13 +262,29 @@ func (local *Installer) Output() error {
 // startServices starts services.
 func (local *Installer) startServices() error {
 	for _, svc := range local.services {
+		if local.DryRun {
+			if _, err := local.packageManager.GetServiceStatus(svc.Name); err == nil {
+				logrus.Infof("Service %s is already running", svc.Name)
+			} else {
+				local.logInfo("Would start service %s", svc.Name)
+			}
+			continue
+		}
+
 		logrus.Infof("Starting service %s", svc.Name)
 
+		_, notEnabledErr := local.packageManager.CheckServiceEnabled(svc.Name)
+
 		_, err := local.packageManager.StartService(svc.Name)
 		if err != nil {
 			return errors.Wrapf(err, "failed to start service %s", svc.Name)
 		}
 
+		if notEnabledErr != nil {
+			local.state.Services = appendUnique(local.state.Services, svc.Name)
+			local.saveState()
+		}
+
 		logrus.Infof("Successfully started service %s", svc.Name)
 		local.collection.Log.Info = append(local.collection.Log.Info, fmt.Sprintf("Successfully started service %s", svc.Name))
 	}
@@ -245,7 +292,16 @@ func (local *Installer) startServices() error {
 	return nil
 }
 
-// probeModules probes kernel modules.
+// requireReboot reports that the node needs a reboot before executing the installer again.
+func (local *Installer) requireReboot() error {
+	logrus.Warn("Need to reboot the system and execute longhornctl install preflight again")
+	local.collection.Log.Warn = append(local.collection.Log.Warn, "Need to reboot the system and execute longhornctl install preflight again")
+	local.collection.RebootRequired = true
+	return nil
+}
+
+// probeModules probes kernel modules. The modules only installed for a pending kernel update are
+// reported, and the node is flagged to require a reboot to activate them.
 func (local *Installer) probeModules(dependencyModule consts.DependencyModuleType) error {
 	var modules []Package
 	switch dependencyModule {
@@ -257,27 +313,69 @@ func (local *Installer) probeModules(dependencyModule consts.DependencyModuleTyp
 		return errors.Errorf("dependency module type (%d) is not supported", dependencyModule)
 	}
 	for _, mod := range modules {
+		notLoadedErr := local.packageManager.CheckModLoaded(mod.Name)
+
+		if local.DryRun {
+			if notLoadedErr == nil {
+				logrus.Infof("Module %s is already loaded", mod.Name)
+				continue
+			}
+
+			availability, releases, err := getModuleAvailability(local.packageManager, local.kernelRelease, mod.Name)
+			if err != nil {
+				return err
+			}
+			switch availability {
+			case moduleNotBooted:
+				local.logWarn("Would need to reboot the system: %s", formatModuleNotBootedMsg(mod.Name, local.kernelRelease, releases))
+			case moduleMissing:
+				local.logWarn("Would fail to load module %s: %s", mod.Name, formatModuleMissingMsg(mod.Name, local.kernelRelease))
+			default:
+				local.logInfo("Would load module %s", mod.Name)
+			}
+			continue
+		}
+
 		logrus.Infof("Probing module %s", mod.Name)
 
 		_, err := local.packageManager.Modprobe(mod.Name)
 		if err != nil {
+			// The module installed for a pending kernel update is persisted below, so it is loaded on the next boot.
+			availability, releases, availabilityErr := getModuleAvailability(local.packageManager, local.kernelRelease, mod.Name)
+			if availabilityErr == nil {
+				switch availability {
+				case moduleNotBooted:
+					local.logWarn("%s", formatModuleNotBootedMsg(mod.Name, local.kernelRelease, releases))
+					local.collection.RebootRequired = true
+					continue
+				case moduleMissing:
+					return errors.Wrap(err, formatModuleMissingMsg(mod.Name, local.kernelRelease))
+				}
+			}
 			return errors.Wrapf(err, "failed to probe module %s", mod.Name)
 		}
 
+		if notLoadedErr != nil {
+			local.state.Modules = appendUnique(local.state.Modules, mod.Name)
+			local.saveState()
+		}
+
 		logrus.Infof("Successfully probed module %s", mod.Name)
 		local.collection.Log.Info = append(local.collection.Log.Info, fmt.Sprintf("Successfully probed module %s", mod.Name))
 	}
 
-	return nil
+	return local.persistModules(modules)
 }
 
 // checkAndinstallPackages check and installs packages with a package manager.
 func (local *Installer) checkAndinstallPackages(spdkDependent bool) (bool, error) {
 	var rebootRequired = false
 
-	_, err := local.packageManager.StartPackageSession()
-	if err != nil {
-		return false, errors.Wrap(err, "failed to start package session")
+	if !local.DryRun {
+		_, err := local.packageManager.StartPackageSession()
+		if err != nil {
+			return false, errors.Wrap(err, "failed to start package session")
+		}
 	}
 
 	packages := local.packages
@@ -289,33 +387,129 @@ func (local *Installer) checkAndinstallPackages(spdkDependent bool) (bool, error
 
 		_, err := local.packageManager.CheckPackageInstalled(pkg.Name)
 		if err != nil {
-			logrus.Infof("Installing package %s", pkg.Name)
+			if local.DryRun {
+				local.logInfo("Would install package %s", pkg.Name)
+				if local.packageManager.NeedReboot() {
+					rebootRequired = true
+				}
+				continue
+			}
+
+			name := local.packageInstallName(pkg)
+			logrus.Infof("Installing package %s", name)
 
-			_, err := local.packageManager.InstallPackage(pkg.Name)
+			_, err := local.packageManager.InstallPackage(name)
 			if err != nil {
 				if pkg.Required {
-					return false, errors.Wrapf(err, "failed to install package %s", pkg.Name)
+					return false, errors.Wrapf(err, "failed to install package %s", name)
 				} else {
-					local.collection.Log.Warn = append(local.collection.Log.Warn, fmt.Sprintf("Failed to install package %s: %v", pkg.Name, err))
+					local.collection.Log.Warn = append(local.collection.Log.Warn, fmt.Sprintf("Failed to install package %s: %v", name, err))
 				}
-			} else {
-				logrus.Infof("Successfully installed package %s", pkg.Name)
-				local.collection.Log.Info = append(local.collection.Log.Info, fmt.Sprintf("Successfully installed package %s", pkg.Name))
+				continue
+			}
 
-				if local.packageManager.NeedReboot() {
-					rebootRequired = true
-				}
+			logrus.Infof("Successfully installed package %s", name)
+			local.collection.Log.Info = append(local.collection.Log.Info, fmt.Sprintf("Successfully installed package %s", name))
+
+			local.state.Packages = appendUnique(local.state.Packages, pkg.Name)
+			local.saveState()
+
+			if local.packageManager.NeedReboot() {
+				// The package is not visible until the reboot, its version is verified on the next run.
+				rebootRequired = true
+				continue
 			}
 		} else {
 			logrus.Infof("Package %s already installed", pkg.Name)
 		}
+
+		if err := local.checkPackageVersion(pkg); err != nil {
+			if pinned := local.packageInstallName(pkg); pinned != pkg.Name {
+				reboot, pinErr := local.installPinnedPackage(pkg, pinned, err)
+				if pinErr == nil {
+					rebootRequired = rebootRequired || reboot
+					continue
+				}
+				err = pinErr
+			}
+
+			if pkg.Required {
+				return false, err
+			}
+			logrus.Warn(err)
+			local.collection.Log.Warn = append(local.collection.Log.Warn, err.Error())
+		}
 	}
 
 	return rebootRequired, nil
 }
 
+// installPinnedPackage installs the pinned version of the installed package violating the version
+// constraint, upgrading or downgrading it, and verifies the installed version afterwards.
+func (local *Installer) installPinnedPackage(pkg Package, pinned string, versionErr error) (bool, error) {
+	if local.DryRun {
+		local.logInfo("Would install package %s, since %v", pinned, versionErr)
+		return local.packageManager.NeedReboot(), nil
+	}
+
+	logrus.Infof("Installing package %s, since %v", pinned, versionErr)
+	if _, err := local.packageManager.InstallPackage(pinned); err != nil {
+		return false, errors.Wrapf(err, "failed to install package %s, since %v", pinned, versionErr)
+	}
+	local.logInfo("Successfully installed package %s", pinned)
+
+	if local.packageManager.NeedReboot() {
+		// The package is not visible until the reboot, its version is verified on the next run.
+		return true, nil
+	}
+	return false, local.checkPackageVersion(pkg)
+}
+
+// packageInstallName returns the name to install the package, pinned to the version when the
+// version constraint requires an exact version.
+func (local *Installer) packageInstallName(pkg Package) string {
+	if pkg.Version == "" {
+		return pkg.Name
+	}
+
+	operator, version, err := parseVersionConstraint(pkg.Version)
+	if err != nil || operator != "=" {
+		return pkg.Name
+	}
+
+	return local.packageManager.VersionedPackageName(pkg.Name, version)
+}
+
+// checkPackageVersion checks if the installed package satisfies the version constraint.
+func (local *Installer) checkPackageVersion(pkg Package) error {
+	if pkg.Version == "" {
+		return nil
+	}
+
+	version, err := local.packageManager.GetPackageVersion(pkg.Name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get version of package %s", pkg.Name)
+	}
+
+	satisfied, err := isVersionSatisfied(version, pkg.Version)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check version of package %s", pkg.Name)
+	}
+	if !satisfied {
+		return errors.Errorf("package %s version %s does not satisfy version constraint %s", pkg.Name, version, pkg.Version)
+	}
+
+	logrus.Infof("Package %s version %s satisfies version constraint %s", pkg.Name, version, pkg.Version)
+	return nil
+}
+
 // updatePackageList updates list of available packages.
 func (local *Installer) updatePackageList() error {
+	if local.DryRun {
+		local.logInfo("Would update package list")
+		return nil
+	}
+
 	logrus.Info("Updating package list")
 	_, err := local.packageManager.UpdatePackageList()
 	if err != nil {
@@ -328,6 +522,15 @@ func (local *Installer) updatePackageList() error {
 
 // configureSPDKEnv configures SPDK environment.
 func (local *Installer) configureSPDKEnv() error {
+	// The resolved PCI addresses override the allowed PCI devices option inherited from the container
+	// environment, which may contain PCI device selectors unknown to the setup script.
+	envs := append(getEnvsForConfiguringSPDKEnv(local.SpdkOptions), fmt.Sprintf("%s=%s", consts.EnvPciAllowed, formatPciAllowed(local.pciAddresses)))
+
+	if local.DryRun {
+		local.logInfo("Would configure SPDK environment with scripts/setup.sh (envs: %v)", envs)
+		return nil
+	}
+
 	// Blindly remove the SPDK source code directory if it exists.
 	spdkPath := filepath.Join(consts.VolumeMountHostDirectory, consts.SpdkPath)
 	if err := os.RemoveAll(spdkPath); err != nil {
@@ -342,7 +545,6 @@ func (local *Installer) configureSPDKEnv() error {
 
 	// Configure SPDK environment
 	logrus.Info("Configuring SPDK environment")
-	envs := getEnvsForConfiguringSPDKEnv(local.SpdkOptions)
 	args := []string{filepath.Join(consts.SpdkPath, "scripts/setup.sh")}
 	if _, err := local.packageManager.Execute(envs, "bash", args, commontypes.ExecuteNoTimeout); err != nil {
 		logrus.WithError(err).Error("Failed to configure SPDK environment")
@@ -382,6 +584,12 @@ func (local *Installer) restartKubelet() error {
 	if currentHugePagesCapacity.Cmp(*requiredHugePagesCapacity) < 0 {
 		logrus.Infof("K8s node CR doesn't have enough hugepages-2Mi capacity. Required: %v, Current: %v", requiredHugePagesCapacity, currentHugePagesCapacity)
 
+		if local.DryRun {
+			local.logInfo("Would restart kubelet service within %s, since node CR has insufficient hugepages-2Mi capacity. Required: %v, Current: %v",
+				local.RestartKubeletWindow, requiredHugePagesCapacity, currentHugePagesCapacity)
+			return nil
+		}
+
 		restartWindow, err := time.ParseDuration(local.RestartKubeletWindow)
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse %q argument", consts.CmdOptRestartKubeletWindow)
@@ -424,3 +632,57 @@ func (local *Installer) restartKubeletService() error {
 	local.collection.Log.Error = append(local.collection.Log.Error, errMsg)
 	return errors.New(errMsg)
 }
+
+// saveState records the changes made by the installer on the host, so they can be
+// reverted by longhornctl uninstall preflight.
+func (local *Installer) saveState() {
+	if err := local.state.save(); err != nil {
+		logrus.WithError(err).Warn("Failed to record preflight installer changes")
+		local.collection.Log.Warn = append(local.collection.Log.Warn, fmt.Sprintf("Failed to record preflight installer changes: %v", err))
+	}
+}
+
+// runHook runs the hook script on the host, and adds its output to the node result.
+func (local *Installer) runHook(name, scriptPath string) error {
+	if scriptPath == "" {
+		return nil
+	}
+
+	if local.DryRun {
+		local.logInfo("Would run %s hook %s", name, scriptPath)
+		return nil
+	}
+
+	script, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s hook %s", name, scriptPath)
+	}
+
+	logrus.Infof("Running %s hook", name)
+	output, err := local.packageManager.Execute([]string{}, "sh", []string{"-c", string(script)}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return errors.Wrapf(err, "failed to run %s hook: %s", name, strings.TrimSpace(output))
+	}
+
+	output = strings.TrimSpace(output)
+	if output == "" {
+		local.logInfo("Completed %s hook", name)
+	} else {
+		local.logInfo("Completed %s hook with output:\n%s", name, output)
+	}
+	return nil
+}
+
+// logInfo logs the message and records it in the collection.
+func (local *Installer) logInfo(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	logrus.Info(msg)
+	local.collection.Log.Info = append(local.collection.Log.Info, msg)
+}
+
+// logWarn logs the warning and records it in the collection.
+func (local *Installer) logWarn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	logrus.Warn(msg)
+	local.collection.Log.Warn = append(local.collection.Log.Warn, msg)
+}
@@ -0,0 +1,118 @@
+package preflight
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	commontypes "github.com/longhorn/go-common-libs/types"
+
+	pkgmgr "github.com/longhorn/cli/pkg/local/preflight/packagemanager"
+)
+
+// kernelModulesDirectory is the directory of the kernel modules of each installed kernel release, populated
+// by the kernel and kernel modules packages (e.g. linux-modules-extra, kernel-modules, kernel-default).
+const kernelModulesDirectory = "/lib/modules"
+
+// moduleAvailability is the availability of a kernel module on the host.
+type moduleAvailability int
+
+const (
+	moduleAvailable moduleAvailability = iota // Available for the running kernel, or built into it.
+	moduleNotBooted                           // Only available for the newer installed kernels, activated by a reboot.
+	moduleMissing                             // Not available for the running kernel nor any newer installed one.
+)
+
+// getModuleAvailability returns the availability of the kernel module for the running kernel release, and
+// the newer installed kernel releases providing it when it is not available for the running one.
+func getModuleAvailability(packageManager pkgmgr.PackageManager, kernelRelease, module string) (moduleAvailability, []string, error) {
+	if isModuleAvailable(packageManager, kernelRelease, module) {
+		return moduleAvailable, nil, nil
+	}
+
+	output, err := packageManager.Execute([]string{}, "ls", []string{"-1", kernelModulesDirectory}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return moduleMissing, nil, errors.Wrapf(err, "failed to list installed kernels in %v", kernelModulesDirectory)
+	}
+
+	var releases []string
+	for _, release := range parseInstalledKernelReleases(output, kernelRelease) {
+		if isModuleAvailable(packageManager, release, module) && isModuleInstalledByPackage(packageManager, release, module) {
+			releases = append(releases, release)
+		}
+	}
+
+	if len(releases) == 0 {
+		return moduleMissing, nil, nil
+	}
+	return moduleNotBooted, releases, nil
+}
+
+// isModuleAvailable checks if the kernel module is built into the kernel release, or installed for it.
+func isModuleAvailable(packageManager pkgmgr.PackageManager, kernelRelease, module string) bool {
+	_, err := packageManager.Execute([]string{}, "modprobe", []string{"--dry-run", "--set-version", kernelRelease, module}, commontypes.ExecuteNoTimeout)
+	return err == nil
+}
+
+// isModuleInstalledByPackage checks if the kernel module of the kernel release is installed by the kernel or
+// kernel modules packages, so a leftover modules directory of a removed kernel is not mistaken for a pending
+// kernel update. The built-in modules are checked with the built-in modules list of the kernel package. The
+// module is assumed to be installed by a package when neither rpm nor dpkg is available to query the owner.
+func isModuleInstalledByPackage(packageManager pkgmgr.PackageManager, kernelRelease, module string) bool {
+	var binary string
+	var args []string
+	switch {
+	case isCommandFound(packageManager, "rpm"):
+		binary, args = "rpm", []string{"-qf"}
+	case isCommandFound(packageManager, "dpkg-query"):
+		binary, args = "dpkg-query", []string{"-S"}
+	default:
+		return true
+	}
+
+	output, err := packageManager.Execute([]string{}, "modinfo", []string{"--set-version", kernelRelease, "--field", "filename", module}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return false
+	}
+
+	path := strings.TrimSpace(output)
+	if path == "" || strings.HasPrefix(path, "(builtin)") {
+		path = filepath.Join(kernelModulesDirectory, kernelRelease, "modules.builtin")
+	}
+
+	_, err = packageManager.Execute([]string{}, binary, append(args, path), commontypes.ExecuteNoTimeout)
+	return err == nil
+}
+
+// parseInstalledKernelReleases returns the kernel releases of the kernel modules directory listing newer than
+// the running kernel release, sorted from the oldest to the newest. The older kernel releases are never booted
+// by default, so their modules are not activated by a reboot.
+func parseInstalledKernelReleases(output, kernelRelease string) []string {
+	var releases []string
+	for _, release := range strings.Fields(output) {
+		if compareVersions(release, kernelRelease) <= 0 {
+			continue
+		}
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return compareVersions(releases[i], releases[j]) < 0
+	})
+	return releases
+}
+
+// formatModuleNotBootedMsg returns the message of the kernel module only available for the newer installed
+// kernel releases.
+func formatModuleNotBootedMsg(module, kernelRelease string, releases []string) string {
+	return fmt.Sprintf("%s is installed for kernel %s but not for the running kernel %s, reboot required to activate modules",
+		module, strings.Join(releases, ", "), kernelRelease)
+}
+
+// formatModuleMissingMsg returns the message of the kernel module not available for the running kernel release
+// nor any newer installed one.
+func formatModuleMissingMsg(module, kernelRelease string) string {
+	return fmt.Sprintf("%s is missing, it is not installed for the running kernel %s nor any newer installed kernel", module, kernelRelease)
+}
@@ -0,0 +1,159 @@
+package preflight
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/cli/pkg/consts"
+)
+
+// modulesLoadDirectories are the directories read by systemd-modules-load.service
+// to load the kernel modules on boot. See man 5 modules-load.d.
+var modulesLoadDirectories = []string{
+	"/etc/modules-load.d",
+	"/run/modules-load.d",
+	"/usr/local/lib/modules-load.d",
+	"/usr/lib/modules-load.d",
+	"/lib/modules-load.d",
+}
+
+// modulesLoadLegacyFile is the file listing the kernel modules to load on boot on Debian-based distros.
+const modulesLoadLegacyFile = "/etc/modules"
+
+// persistModules adds the kernel modules to the Longhorn modules-load.d configuration
+// file on the host, so they are loaded again after a reboot.
+func (local *Installer) persistModules(modules []Package) error {
+	if len(modules) == 0 {
+		return nil
+	}
+
+	configFile := filepath.Join(consts.VolumeMountHostDirectory, consts.ModulesLoadConfigFile)
+
+	persisted, err := readModulesLoadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to read %v", consts.ModulesLoadConfigFile)
+	}
+	created := os.IsNotExist(err)
+
+	var added []string
+	for _, mod := range modules {
+		name := normalizeModuleName(mod.Name)
+		if slices.Contains(persisted, name) {
+			continue
+		}
+		persisted = append(persisted, name)
+		added = append(added, name)
+	}
+
+	if len(added) == 0 {
+		logrus.Infof("Kernel modules are already persisted in %v", consts.ModulesLoadConfigFile)
+		return nil
+	}
+
+	if local.DryRun {
+		local.logInfo("Would persist kernel modules to %v: %v", consts.ModulesLoadConfigFile, strings.Join(added, ", "))
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %v", consts.ModulesLoadConfigFile)
+	}
+
+	content := "# Generated by longhornctl\n" + strings.Join(persisted, "\n") + "\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %v", consts.ModulesLoadConfigFile)
+	}
+
+	if created {
+		local.state.Files = appendUnique(local.state.Files, consts.ModulesLoadConfigFile)
+		local.saveState()
+	}
+
+	msg := fmt.Sprintf("Successfully persisted kernel modules to %v: %v", consts.ModulesLoadConfigFile, strings.Join(added, ", "))
+	logrus.Info(msg)
+	local.collection.Log.Info = append(local.collection.Log.Info, msg)
+	return nil
+}
+
+// getPersistedModules returns the kernel modules configured to be loaded on boot on the host.
+func getPersistedModules() (map[string]bool, error) {
+	modules := map[string]bool{}
+
+	var files []string
+	for _, dir := range modulesLoadDirectories {
+		entries, err := os.ReadDir(filepath.Join(consts.VolumeMountHostDirectory, dir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to read directory %v", dir)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".conf") {
+				continue
+			}
+			files = append(files, filepath.Join(consts.VolumeMountHostDirectory, dir, entry.Name()))
+		}
+	}
+	files = append(files, filepath.Join(consts.VolumeMountHostDirectory, modulesLoadLegacyFile))
+
+	for _, file := range files {
+		names, err := readModulesLoadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to read %v", file)
+		}
+
+		for _, name := range names {
+			modules[name] = true
+		}
+	}
+
+	return modules, nil
+}
+
+// readModulesLoadFile returns the kernel modules listed in the modules-load.d configuration file.
+func readModulesLoadFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var modules []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if name := parseModulesLoadLine(scanner.Text()); name != "" {
+			modules = append(modules, name)
+		}
+	}
+	return modules, scanner.Err()
+}
+
+// parseModulesLoadLine returns the kernel module name of the modules-load.d configuration line.
+// Empty lines and lines starting with "#" or ";" are ignored.
+func parseModulesLoadLine(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return ""
+	}
+	return normalizeModuleName(strings.Fields(line)[0])
+}
+
+// normalizeModuleName returns the kernel module name with dashes replaced by underscores,
+// since modprobe treats them as equivalent.
+func normalizeModuleName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
@@ -0,0 +1,123 @@
+package preflight
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	commontypes "github.com/longhorn/go-common-libs/types"
+
+	"github.com/longhorn/cli/pkg/consts"
+)
+
+// multipathBlacklist blacklists the iSCSI devices exposed by the Longhorn engine, so
+// multipathd does not claim the Longhorn volumes.
+// https://longhorn.io/kb/troubleshooting-volume-with-multipath/
+const multipathBlacklist = `# Generated by longhornctl
+blacklist {
+    device {
+        vendor "` + consts.LonghornIscsiVendor + `"
+        product "` + consts.LonghornIscsiProduct + `"
+    }
+}
+`
+
+// configureMultipath writes the Longhorn blacklist drop-in to the multipath configuration
+// directory, reloads multipathd, and verifies that no Longhorn device is claimed.
+func (local *Installer) configureMultipath() error {
+	if !local.ConfigureMultipath {
+		return nil
+	}
+
+	logrus.Info("Configuring multipathd")
+
+	if _, err := local.packageManager.GetServiceStatus("multipathd.service"); err != nil {
+		local.logInfo("multipathd.service is not running, skipped configuring multipath blacklist")
+		return nil
+	}
+
+	configDir := consts.MultipathDefaultConfigDir
+	content, err := os.ReadFile(filepath.Join(consts.VolumeMountHostDirectory, consts.MultipathConfigFile))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to read %v", consts.MultipathConfigFile)
+	}
+	if dir := parseMultipathConfigDir(string(content)); dir != "" {
+		configDir = dir
+	}
+	configFile := filepath.Join(configDir, consts.MultipathLonghornConfigFileName)
+
+	if local.DryRun {
+		local.logInfo("Would write multipath blacklist to %v and reconfigure multipathd", configFile)
+		return nil
+	}
+
+	hostConfigFile := filepath.Join(consts.VolumeMountHostDirectory, configFile)
+	_, err = os.Stat(hostConfigFile)
+	created := os.IsNotExist(err)
+
+	if err := os.MkdirAll(filepath.Dir(hostConfigFile), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %v", configDir)
+	}
+	if err := os.WriteFile(hostConfigFile, []byte(multipathBlacklist), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %v", configFile)
+	}
+
+	if created {
+		local.state.Files = appendUnique(local.state.Files, configFile)
+		local.saveState()
+	}
+
+	if _, err := local.packageManager.Execute([]string{}, "multipathd", []string{"reconfigure"}, commontypes.ExecuteNoTimeout); err != nil {
+		return errors.Wrap(err, "failed to reconfigure multipathd")
+	}
+	local.logInfo("Successfully wrote multipath blacklist to %v and reconfigured multipathd", configFile)
+
+	output, err := local.packageManager.Execute([]string{}, "multipath", []string{"-ll"}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return errors.Wrap(err, "failed to list multipath devices")
+	}
+
+	if claimed := findLonghornMultipathMaps(output); len(claimed) > 0 {
+		msg := fmt.Sprintf("Longhorn devices are still claimed by multipathd: %v. Please flush them with multipath -f after detaching the volumes", strings.Join(claimed, ", "))
+		logrus.Warn(msg)
+		local.collection.Log.Warn = append(local.collection.Log.Warn, msg)
+		return nil
+	}
+
+	local.logInfo("No Longhorn device is claimed by multipathd")
+	return nil
+}
+
+// parseMultipathConfigDir returns the config_dir of the multipath.conf content,
+// or an empty string if it is not configured.
+func parseMultipathConfigDir(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "config_dir" {
+			continue
+		}
+		return strings.Trim(fields[1], `"`)
+	}
+	return ""
+}
+
+// findLonghornMultipathMaps returns the multipath maps of the Longhorn devices from the output of "multipath -ll".
+//
+// Example output:
+//
+//	mpatha (360000000000000000e00000000010001) dm-0 IET,VIRTUAL-DISK
+//	size=10G features='0' hwhandler='0' wp=rw
+func findLonghornMultipathMaps(output string) []string {
+	var maps []string
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, consts.LonghornIscsiVendor+","+consts.LonghornIscsiProduct) {
+			continue
+		}
+		maps = append(maps, strings.Fields(line)[0])
+	}
+	return maps
+}
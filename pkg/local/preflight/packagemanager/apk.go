@@ -0,0 +1,140 @@
+package packagemanager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	commonns "github.com/longhorn/go-common-libs/ns"
+	commontypes "github.com/longhorn/go-common-libs/types"
+)
+
+// ApkPackageManager manages the packages with apk, and the services with OpenRC, on Alpine Linux.
+type ApkPackageManager struct {
+	executor *commonns.Executor
+
+	localRepository string
+}
+
+func NewApkPackageManager(executor *commonns.Executor) *ApkPackageManager {
+	return &ApkPackageManager{
+		executor: executor,
+	}
+}
+
+// UpdatePackageList updates list of available packages
+func (c *ApkPackageManager) UpdatePackageList() (string, error) {
+	return c.executor.Execute([]string{}, "apk", []string{"update"}, commontypes.ExecuteNoTimeout)
+}
+
+// StartPackageSession start a session to install/uninstall packages in a unique transaction
+func (c *ApkPackageManager) StartPackageSession() (string, error) {
+	return "", nil
+}
+
+// InstallPackage executes the installation command
+func (c *ApkPackageManager) InstallPackage(name string) (string, error) {
+	args := []string{"add", "--no-progress"}
+	if c.localRepository != "" {
+		// The index of the local repository is not signed.
+		args = append(args, "--repositories-file", "/dev/null", "--repository", c.localRepository, "--allow-untrusted")
+	}
+	return c.executor.Execute([]string{}, "apk", append(args, name), commontypes.ExecuteNoTimeout)
+}
+
+// UninstallPackage executes the uninstallation command
+func (c *ApkPackageManager) UninstallPackage(name string) (string, error) {
+	return c.executor.Execute([]string{}, "apk", []string{"del", "--no-progress", name}, commontypes.ExecuteNoTimeout)
+}
+
+// Execute executes the given command with the specified environment variables, binary, and arguments.
+func (c *ApkPackageManager) Execute(envs []string, binary string, args []string, timeout time.Duration) (string, error) {
+	return c.executor.Execute(envs, binary, args, timeout)
+}
+
+// Modprobe executes the modprobe command
+func (c *ApkPackageManager) Modprobe(module string, opts ...string) (string, error) {
+	return c.executor.Execute([]string{}, "modprobe", append(opts, module), commontypes.ExecuteNoTimeout)
+}
+
+// CheckModLoaded checks if a module is loaded
+func (c *ApkPackageManager) CheckModLoaded(module string) error {
+	_, err := c.executor.Execute([]string{}, "grep", []string{module, "/proc/modules"}, commontypes.ExecuteNoTimeout)
+	return err
+}
+
+// StartService adds the service to the default runlevel and starts it
+func (c *ApkPackageManager) StartService(name string) (string, error) {
+	return openrcStartService(c.executor, name)
+}
+
+// RestartService executes the service restart command
+func (c *ApkPackageManager) RestartService(name string) (string, error) {
+	return openrcRestartService(c.executor, name)
+}
+
+// GetServiceStatus executes the service status command
+func (c *ApkPackageManager) GetServiceStatus(name string) (string, error) {
+	return openrcGetServiceStatus(c.executor, name)
+}
+
+// DisableService removes the service from the default runlevel, and stops the service
+func (c *ApkPackageManager) DisableService(name string) (string, error) {
+	return openrcDisableService(c.executor, name)
+}
+
+// CheckServiceEnabled checks if a service is added to a runlevel
+func (c *ApkPackageManager) CheckServiceEnabled(name string) (string, error) {
+	return openrcCheckServiceEnabled(c.executor, name)
+}
+
+// CheckPackageInstalled checks if a package is installed
+func (c *ApkPackageManager) CheckPackageInstalled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "apk", []string{"info", "--installed", name}, commontypes.ExecuteNoTimeout)
+}
+
+// GetPackageVersion returns the version of the installed package
+func (c *ApkPackageManager) GetPackageVersion(name string) (string, error) {
+	// example output of apk list --installed open-iscsi:
+	// open-iscsi-2.1.9-r0 x86_64 {open-iscsi} (GPL-2.0-or-later) [installed]
+	output, err := c.executor.Execute([]string{}, "apk", []string{"list", "--installed", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], name+"-") {
+			continue
+		}
+		return strings.TrimPrefix(fields[0], name+"-"), nil
+	}
+	return "", fmt.Errorf("failed to parse version of package %s: %q", name, output)
+}
+
+// VersionedPackageName returns the name used to install the version of the package
+func (c *ApkPackageManager) VersionedPackageName(name, version string) string {
+	return name + "=" + version
+}
+
+// NeedReboot tells if a reboot is needed after package installation
+func (c *ApkPackageManager) NeedReboot() bool {
+	return false
+}
+
+// DownloadPackages downloads the packages and their dependencies to the architecture subdirectory of the
+// directory, and generates the unsigned APKINDEX of the local repository. apk looks up the index of a
+// repository in <repository>/<arch>/APKINDEX.tar.gz.
+func (c *ApkPackageManager) DownloadPackages(dir string, names ...string) (string, error) {
+	script := fmt.Sprintf(`set -e
+arch_dir=%[1]s/$(apk --print-arch)
+mkdir -p "$arch_dir"
+apk fetch --no-progress --recursive --output "$arch_dir" %[2]s
+apk index --allow-untrusted --output "$arch_dir/APKINDEX.tar.gz" "$arch_dir"/*.apk`, shellQuote(dir), shellQuoteAll(names))
+	return c.executor.Execute([]string{}, "sh", []string{"-c", script}, commontypes.ExecuteNoTimeout)
+}
+
+// UseLocalRepository installs the packages from the local repository directory only
+func (c *ApkPackageManager) UseLocalRepository(dir string) error {
+	c.localRepository = dir
+	return nil
+}
@@ -1,6 +1,8 @@
 package packagemanager
 
 import (
+	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -10,6 +12,8 @@ import (
 
 type AptPackageManager struct {
 	executor *commonns.Executor
+
+	localRepository string
 }
 
 func NewAptPackageManager(executor *commonns.Executor) *AptPackageManager {
@@ -30,7 +34,15 @@ func (c *AptPackageManager) StartPackageSession() (string, error) {
 
 // InstallPackage executes the installation command
 func (c *AptPackageManager) InstallPackage(name string) (string, error) {
-	return c.executor.Execute([]string{}, "apt", []string{"install", name, "-y"}, commontypes.ExecuteNoTimeout)
+	args := []string{"install", name, "-y"}
+	if strings.Contains(name, "=") {
+		// The pinned version may be older than the installed one.
+		args = append(args, "--allow-downgrades")
+	}
+	if c.localRepository != "" {
+		args = append(args, "--with-source", filepath.Join(c.localRepository, "Packages"))
+	}
+	return c.executor.Execute([]string{}, "apt", args, commontypes.ExecuteNoTimeout)
 }
 
 // UninstallPackage executes the uninstallation command
@@ -74,6 +86,16 @@ func (c *AptPackageManager) GetServiceStatus(name string) (string, error) {
 	return c.executor.Execute([]string{}, "systemctl", []string{"status", "--no-pager", name}, commontypes.ExecuteNoTimeout)
 }
 
+// DisableService executes the service disable command, and stops the service
+func (c *AptPackageManager) DisableService(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "disable", "--now", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckServiceEnabled checks if a service is enabled
+func (c *AptPackageManager) CheckServiceEnabled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "is-enabled", name}, commontypes.ExecuteNoTimeout)
+}
+
 // CheckPackageInstalled checks if a package is installed
 func (c *AptPackageManager) CheckPackageInstalled(name string) (output string, err error) {
 	// Check man 1 dpkg-query for status flags.
@@ -93,7 +115,42 @@ func (c *AptPackageManager) CheckPackageInstalled(name string) (output string, e
 	return output, ErrPackageNotInstalled
 }
 
+// GetPackageVersion returns the version of the installed package
+func (c *AptPackageManager) GetPackageVersion(name string) (string, error) {
+	output, err := c.executor.Execute([]string{}, "dpkg-query", []string{"-f=${Version}", "-W", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// VersionedPackageName returns the name used to install the version of the package. apt requires the
+// full version, so the version without Debian revision matches all its revisions with a prefix match.
+func (c *AptPackageManager) VersionedPackageName(name, version string) string {
+	if !strings.Contains(version, "-") {
+		return name + "=" + version + "-*"
+	}
+	return name + "=" + version
+}
+
 // NeedReboot tells if a reboot is needed after package installation
 func (c *AptPackageManager) NeedReboot() bool {
 	return false
 }
+
+// DownloadPackages downloads the packages and their dependencies to the directory, and generates
+// the Packages index of the local repository
+func (c *AptPackageManager) DownloadPackages(dir string, names ...string) (string, error) {
+	script := fmt.Sprintf(`set -e
+mkdir -p %[1]s
+cd %[1]s
+apt-get download $(apt-cache depends --recurse --no-recommends --no-suggests --no-conflicts --no-breaks --no-replaces --no-enhances %[2]s | grep '^\w' | sort -u)
+apt-ftparchive packages . > Packages`, shellQuote(dir), shellQuoteAll(names))
+	return c.executor.Execute([]string{}, "sh", []string{"-c", script}, commontypes.ExecuteNoTimeout)
+}
+
+// UseLocalRepository installs the packages from the Packages index of the local repository directory
+func (c *AptPackageManager) UseLocalRepository(dir string) error {
+	c.localRepository = dir
+	return nil
+}
@@ -0,0 +1,65 @@
+package packagemanager
+
+import (
+	"fmt"
+	"strings"
+
+	commonns "github.com/longhorn/go-common-libs/ns"
+	commontypes "github.com/longhorn/go-common-libs/types"
+)
+
+// openrcRunlevel is the runlevel the services are added to, so they are started on boot.
+const openrcRunlevel = "default"
+
+// openrcStartService adds the service to the default runlevel and starts it
+func openrcStartService(executor *commonns.Executor, name string) (string, error) {
+	service := openrcServiceName(name)
+	output, err := executor.Execute([]string{}, "rc-update", []string{"add", service, openrcRunlevel}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return output, err
+	}
+
+	return executor.Execute([]string{}, "rc-service", []string{service, "start"}, commontypes.ExecuteNoTimeout)
+}
+
+// openrcRestartService executes the service restart command
+func openrcRestartService(executor *commonns.Executor, name string) (string, error) {
+	return executor.Execute([]string{}, "rc-service", []string{openrcServiceName(name), "restart"}, commontypes.ExecuteNoTimeout)
+}
+
+// openrcGetServiceStatus executes the service status command. Like systemctl, it exits with code 3 when
+// the service is stopped, and with code 4 when the service does not exist. OpenRC has no socket
+// activation, so the socket units are never found.
+func openrcGetServiceStatus(executor *commonns.Executor, name string) (string, error) {
+	if strings.HasSuffix(name, ".socket") {
+		return executor.Execute([]string{}, "sh", []string{"-c", "exit 4"}, commontypes.ExecuteNoTimeout)
+	}
+
+	script := fmt.Sprintf("rc-service --exists %[1]s || exit 4; rc-service %[1]s status", shellQuote(openrcServiceName(name)))
+	return executor.Execute([]string{}, "sh", []string{"-c", script}, commontypes.ExecuteNoTimeout)
+}
+
+// openrcDisableService removes the service from the default runlevel, and stops the service
+func openrcDisableService(executor *commonns.Executor, name string) (string, error) {
+	service := openrcServiceName(name)
+	output, err := executor.Execute([]string{}, "rc-update", []string{"del", service, openrcRunlevel}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return output, err
+	}
+
+	return executor.Execute([]string{}, "rc-service", []string{service, "stop"}, commontypes.ExecuteNoTimeout)
+}
+
+// openrcCheckServiceEnabled checks if a service is added to a runlevel
+func openrcCheckServiceEnabled(executor *commonns.Executor, name string) (string, error) {
+	// example output of rc-update show:
+	//  iscsid | default
+	// The service name is compared as a plain string, not a pattern.
+	script := fmt.Sprintf(`rc-update show | awk -v service=%s '$1 == service && $2 == "|" { found = 1 } END { exit !found }'`, shellQuote(openrcServiceName(name)))
+	return executor.Execute([]string{}, "sh", []string{"-c", script}, commontypes.ExecuteNoTimeout)
+}
+
+// openrcServiceName returns the OpenRC service name of the systemd unit name.
+func openrcServiceName(name string) string {
+	return strings.TrimSuffix(name, ".service")
+}
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
 	commonns "github.com/longhorn/go-common-libs/ns"
+	commontypes "github.com/longhorn/go-common-libs/types"
 )
 
 type PackageManagerType string
@@ -18,7 +20,10 @@ const (
 	PackageManagerZypper              = PackageManagerType("zypper")
 	PackageManagerTransactionalUpdate = PackageManagerType("transactional-update")
 	PackageManagerPacman              = PackageManagerType("pacman")
-	// PackageManagerQlist            = PackageManagerType("qlist")
+	PackageManagerApk                 = PackageManagerType("apk")
+	PackageManagerRpmOstree           = PackageManagerType("rpm-ostree")
+	PackageManagerQlist               = PackageManagerType("qlist")
+	PackageManagerTdnf                = PackageManagerType("tdnf")
 )
 
 var (
@@ -36,9 +41,54 @@ type PackageManager interface {
 	StartService(name string) (string, error)
 	RestartService(name string) (string, error)
 	GetServiceStatus(name string) (string, error)
+	DisableService(name string) (string, error)
+	CheckServiceEnabled(name string) (string, error)
 	CheckPackageInstalled(name string) (string, error)
+	GetPackageVersion(name string) (string, error)
+	VersionedPackageName(name, version string) string
 	Execute(envs []string, binary string, args []string, timeout time.Duration) (string, error)
 	NeedReboot() bool
+	DownloadPackages(dir string, names ...string) (string, error)
+	UseLocalRepository(dir string) error
+}
+
+// localRepositoryName is the name of the repository created from the preflight bundle directory.
+const localRepositoryName = "longhorn-preflight-bundle"
+
+// shellQuote quotes the string as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellQuoteAll quotes each string as a single shell word, and joins them with spaces.
+func shellQuoteAll(strs []string) string {
+	quoted := make([]string, 0, len(strs))
+	for _, s := range strs {
+		quoted = append(quoted, shellQuote(s))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// createRepositoryScript returns the shell script generating the rpm-md repository metadata of the
+// directory, with createrepo_c or the legacy createrepo.
+func createRepositoryScript(dir string) string {
+	return fmt.Sprintf(`if command -v createrepo_c >/dev/null 2>&1; then
+	createrepo_c %[1]s
+elif command -v createrepo >/dev/null 2>&1; then
+	createrepo %[1]s
+else
+	echo "createrepo_c or createrepo is required to generate the repository metadata of %[1]s" >&2
+	exit 1
+fi`, shellQuote(dir))
+}
+
+// getRpmPackageVersion returns the version-release of the installed rpm package
+func getRpmPackageVersion(executor *commonns.Executor, name string) (string, error) {
+	output, err := executor.Execute([]string{}, "rpm", []string{"-q", "--qf", "%{VERSION}-%{RELEASE}", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
 }
 
 func New(pkgMgrType PackageManagerType, executor *commonns.Executor) (PackageManager, error) {
@@ -53,6 +103,14 @@ func New(pkgMgrType PackageManagerType, executor *commonns.Executor) (PackageMan
 		return NewTransactionalUpdatePackageManager(executor), nil
 	case PackageManagerPacman:
 		return NewPacmanPackageManager(executor), nil
+	case PackageManagerApk:
+		return NewApkPackageManager(executor), nil
+	case PackageManagerRpmOstree:
+		return NewRpmOstreePackageManager(executor), nil
+	case PackageManagerQlist:
+		return NewQlistPackageManager(executor), nil
+	case PackageManagerTdnf:
+		return NewTdnfPackageManager(executor), nil
 	default:
 		return nil, fmt.Errorf("unknown package manager type: %s", pkgMgrType)
 	}
@@ -1,6 +1,8 @@
 package packagemanager
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	commonns "github.com/longhorn/go-common-libs/ns"
@@ -73,12 +75,50 @@ func (c *PacmanPackageManager) GetServiceStatus(name string) (string, error) {
 	return c.executor.Execute([]string{}, "systemctl", []string{"status", "--no-pager", name}, commontypes.ExecuteNoTimeout)
 }
 
+// DisableService executes the service disable command, and stops the service
+func (c *PacmanPackageManager) DisableService(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "disable", "--now", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckServiceEnabled checks if a service is enabled
+func (c *PacmanPackageManager) CheckServiceEnabled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "is-enabled", name}, commontypes.ExecuteNoTimeout)
+}
+
 // CheckPackageInstalled checks if a package is installed
 func (c *PacmanPackageManager) CheckPackageInstalled(name string) (string, error) {
 	return c.executor.Execute([]string{}, "pacman", []string{"-Q", name}, commontypes.ExecuteNoTimeout)
 }
 
+// GetPackageVersion returns the version of the installed package
+func (c *PacmanPackageManager) GetPackageVersion(name string) (string, error) {
+	output, err := c.executor.Execute([]string{}, "pacman", []string{"-Q", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return "", fmt.Errorf("failed to parse version of package %s: %q", name, output)
+	}
+	return fields[1], nil
+}
+
+// VersionedPackageName returns the package name, since pacman only installs the version in the sync database
+func (c *PacmanPackageManager) VersionedPackageName(name, version string) string {
+	return name
+}
+
 // NeedReboot tells if a reboot is needed after package installation
 func (c *PacmanPackageManager) NeedReboot() bool {
 	return false
 }
+
+// DownloadPackages is not supported, since pacman requires a configured repository database
+func (c *PacmanPackageManager) DownloadPackages(dir string, names ...string) (string, error) {
+	return "", fmt.Errorf("downloading packages to a local repository is not supported by %s", PackageManagerPacman)
+}
+
+// UseLocalRepository is not supported, since pacman requires a configured repository database
+func (c *PacmanPackageManager) UseLocalRepository(dir string) error {
+	return fmt.Errorf("installing packages from a local repository is not supported by %s", PackageManagerPacman)
+}
@@ -0,0 +1,169 @@
+package packagemanager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	commonns "github.com/longhorn/go-common-libs/ns"
+	commontypes "github.com/longhorn/go-common-libs/types"
+)
+
+// QlistPackageManager manages the packages with emerge and qlist (app-portage/portage-utils) on Gentoo.
+// The packages are identified by their category/name atoms. The services are managed with OpenRC,
+// or with systemd when the host is booted with systemd.
+type QlistPackageManager struct {
+	executor *commonns.Executor
+
+	systemd bool
+}
+
+func NewQlistPackageManager(executor *commonns.Executor) *QlistPackageManager {
+	// sd_booted(3): the system is booted with systemd if /run/systemd/system exists.
+	_, err := executor.Execute([]string{}, "test", []string{"-d", "/run/systemd/system"}, commontypes.ExecuteNoTimeout)
+
+	return &QlistPackageManager{
+		executor: executor,
+		systemd:  err == nil,
+	}
+}
+
+// UpdatePackageList synchronizes the ebuild repositories
+func (c *QlistPackageManager) UpdatePackageList() (string, error) {
+	return c.executor.Execute([]string{}, "emerge", []string{"--sync", "--quiet"}, commontypes.ExecuteNoTimeout)
+}
+
+// StartPackageSession start a session to install/uninstall packages in a unique transaction
+func (c *QlistPackageManager) StartPackageSession() (string, error) {
+	return "", nil
+}
+
+// InstallPackage executes the installation command. The package is not rebuilt when already installed.
+func (c *QlistPackageManager) InstallPackage(name string) (string, error) {
+	return c.executor.Execute([]string{}, "emerge", []string{"--noreplace", "--quiet-build", name}, commontypes.ExecuteNoTimeout)
+}
+
+// UninstallPackage removes the package from the world set, and unmerges it if nothing depends on it
+func (c *QlistPackageManager) UninstallPackage(name string) (string, error) {
+	output, err := c.executor.Execute([]string{}, "emerge", []string{"--deselect", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return output, err
+	}
+
+	return c.executor.Execute([]string{}, "emerge", []string{"--depclean", name}, commontypes.ExecuteNoTimeout)
+}
+
+// Execute executes the given command with the specified environment variables, binary, and arguments.
+func (c *QlistPackageManager) Execute(envs []string, binary string, args []string, timeout time.Duration) (string, error) {
+	return c.executor.Execute(envs, binary, args, timeout)
+}
+
+// Modprobe executes the modprobe command
+func (c *QlistPackageManager) Modprobe(module string, opts ...string) (string, error) {
+	return c.executor.Execute([]string{}, "modprobe", append(opts, module), commontypes.ExecuteNoTimeout)
+}
+
+// CheckModLoaded checks if a module is loaded
+func (c *QlistPackageManager) CheckModLoaded(module string) error {
+	_, err := c.executor.Execute([]string{}, "grep", []string{module, "/proc/modules"}, commontypes.ExecuteNoTimeout)
+	return err
+}
+
+// StartService executes the service start command
+func (c *QlistPackageManager) StartService(name string) (string, error) {
+	if !c.systemd {
+		return openrcStartService(c.executor, name)
+	}
+
+	output, err := c.executor.Execute([]string{}, "systemctl", []string{"-q", "enable", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return output, err
+	}
+
+	return c.executor.Execute([]string{}, "systemctl", []string{"start", name}, commontypes.ExecuteNoTimeout)
+}
+
+// RestartService executes the service restart command
+func (c *QlistPackageManager) RestartService(name string) (string, error) {
+	if !c.systemd {
+		return openrcRestartService(c.executor, name)
+	}
+	return c.executor.Execute([]string{}, "systemctl", []string{"restart", name}, commontypes.ExecuteNoTimeout)
+}
+
+// GetServiceStatus executes the service status command
+func (c *QlistPackageManager) GetServiceStatus(name string) (string, error) {
+	if !c.systemd {
+		return openrcGetServiceStatus(c.executor, name)
+	}
+	return c.executor.Execute([]string{}, "systemctl", []string{"status", "--no-pager", name}, commontypes.ExecuteNoTimeout)
+}
+
+// DisableService executes the service disable command, and stops the service
+func (c *QlistPackageManager) DisableService(name string) (string, error) {
+	if !c.systemd {
+		return openrcDisableService(c.executor, name)
+	}
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "disable", "--now", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckServiceEnabled checks if a service is enabled
+func (c *QlistPackageManager) CheckServiceEnabled(name string) (string, error) {
+	if !c.systemd {
+		return openrcCheckServiceEnabled(c.executor, name)
+	}
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "is-enabled", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckPackageInstalled checks if a package is installed
+func (c *QlistPackageManager) CheckPackageInstalled(name string) (string, error) {
+	output, err := c.executor.Execute([]string{}, "qlist", []string{"-I", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return output, err
+	}
+	// qlist lists nothing, but does not fail, when the package is not installed.
+	if strings.TrimSpace(output) == "" {
+		return output, ErrPackageNotInstalled
+	}
+	return output, nil
+}
+
+// GetPackageVersion returns the version of the installed package, with the ebuild revision
+func (c *QlistPackageManager) GetPackageVersion(name string) (string, error) {
+	// example output of qlist -Iv sys-block/open-iscsi:
+	// sys-block/open-iscsi-2.1.9-r1
+	output, err := c.executor.Execute([]string{}, "qlist", []string{"-Iv", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return "", err
+	}
+
+	packageName := name[strings.LastIndex(name, "/")+1:]
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		line = line[strings.LastIndex(line, "/")+1:]
+		if strings.HasPrefix(line, packageName+"-") {
+			return strings.TrimPrefix(line, packageName+"-"), nil
+		}
+	}
+	return "", fmt.Errorf("failed to parse version of package %s: %q", name, output)
+}
+
+// VersionedPackageName returns the atom used to install the version of the package
+func (c *QlistPackageManager) VersionedPackageName(name, version string) string {
+	return "=" + name + "-" + version
+}
+
+// NeedReboot tells if a reboot is needed after package installation
+func (c *QlistPackageManager) NeedReboot() bool {
+	return false
+}
+
+// DownloadPackages is not supported, since emerge builds the packages from sources on the node
+func (c *QlistPackageManager) DownloadPackages(dir string, names ...string) (string, error) {
+	return "", fmt.Errorf("downloading packages to a local repository is not supported by %s", PackageManagerQlist)
+}
+
+// UseLocalRepository is not supported, since emerge builds the packages from sources on the node
+func (c *QlistPackageManager) UseLocalRepository(dir string) error {
+	return fmt.Errorf("installing packages from a local repository is not supported by %s", PackageManagerQlist)
+}
@@ -0,0 +1,149 @@
+package packagemanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	commonns "github.com/longhorn/go-common-libs/ns"
+	commontypes "github.com/longhorn/go-common-libs/types"
+)
+
+// RpmOstreePackageManager layers the packages on the immutable root of the rpm-ostree based systems,
+// such as Fedora CoreOS and RHEL CoreOS. The layered packages are only available after booting
+// into the new deployment.
+type RpmOstreePackageManager struct {
+	executor *commonns.Executor
+}
+
+func NewRpmOstreePackageManager(executor *commonns.Executor) *RpmOstreePackageManager {
+	return &RpmOstreePackageManager{
+		executor: executor,
+	}
+}
+
+// UpdatePackageList updates list of available packages
+func (c *RpmOstreePackageManager) UpdatePackageList() (string, error) {
+	return c.executor.Execute([]string{}, "rpm-ostree", []string{"refresh-md"}, commontypes.ExecuteNoTimeout)
+}
+
+// StartPackageSession start a session to install/uninstall packages in a unique transaction
+func (c *RpmOstreePackageManager) StartPackageSession() (string, error) {
+	return "", nil
+}
+
+// InstallPackage layers the package on a new deployment, based on the pending deployment if any.
+// Packages already requested in the pending deployment are skipped.
+func (c *RpmOstreePackageManager) InstallPackage(name string) (string, error) {
+	return c.executor.Execute([]string{}, "rpm-ostree", []string{"install", "--idempotent", name}, commontypes.ExecuteNoTimeout)
+}
+
+// UninstallPackage removes the layered package on a new deployment
+func (c *RpmOstreePackageManager) UninstallPackage(name string) (string, error) {
+	return c.executor.Execute([]string{}, "rpm-ostree", []string{"uninstall", "--idempotent", name}, commontypes.ExecuteNoTimeout)
+}
+
+// Execute executes the given command with the specified environment variables, binary, and arguments.
+func (c *RpmOstreePackageManager) Execute(envs []string, binary string, args []string, timeout time.Duration) (string, error) {
+	return c.executor.Execute(envs, binary, args, timeout)
+}
+
+// Modprobe executes the modprobe command
+func (c *RpmOstreePackageManager) Modprobe(module string, opts ...string) (string, error) {
+	return c.executor.Execute([]string{}, "modprobe", append(opts, module), commontypes.ExecuteNoTimeout)
+}
+
+// CheckModLoaded checks if a module is loaded
+func (c *RpmOstreePackageManager) CheckModLoaded(module string) error {
+	_, err := c.executor.Execute([]string{}, "grep", []string{module, "/proc/modules"}, commontypes.ExecuteNoTimeout)
+	return err
+}
+
+// StartService executes the service start command
+func (c *RpmOstreePackageManager) StartService(name string) (string, error) {
+	output, err := c.executor.Execute([]string{}, "systemctl", []string{"-q", "enable", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return output, err
+	}
+
+	return c.executor.Execute([]string{}, "systemctl", []string{"start", name}, commontypes.ExecuteNoTimeout)
+}
+
+// RestartService executes the service restart command
+func (c *RpmOstreePackageManager) RestartService(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"restart", name}, commontypes.ExecuteNoTimeout)
+}
+
+// GetServiceStatus executes the service status command
+func (c *RpmOstreePackageManager) GetServiceStatus(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"status", "--no-pager", name}, commontypes.ExecuteNoTimeout)
+}
+
+// DisableService executes the service disable command, and stops the service
+func (c *RpmOstreePackageManager) DisableService(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "disable", "--now", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckServiceEnabled checks if a service is enabled
+func (c *RpmOstreePackageManager) CheckServiceEnabled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "is-enabled", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckPackageInstalled checks if a package is installed in the booted deployment. The packages
+// layered on a pending deployment are not installed until the reboot.
+func (c *RpmOstreePackageManager) CheckPackageInstalled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "rpm", []string{"-q", name}, commontypes.ExecuteNoTimeout)
+}
+
+// GetPackageVersion returns the version of the package installed in the booted deployment
+func (c *RpmOstreePackageManager) GetPackageVersion(name string) (string, error) {
+	return getRpmPackageVersion(c.executor, name)
+}
+
+// VersionedPackageName returns the name used to install the version of the package
+func (c *RpmOstreePackageManager) VersionedPackageName(name, version string) string {
+	return name + "-" + version
+}
+
+// NeedReboot tells if a reboot is needed to boot into a pending deployment. It returns true when
+// the deployments cannot be retrieved, so the packages are checked again after a reboot.
+func (c *RpmOstreePackageManager) NeedReboot() bool {
+	output, err := c.executor.Execute([]string{}, "rpm-ostree", []string{"status", "--json"}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return true
+	}
+
+	pending, err := hasPendingDeployment(output)
+	if err != nil {
+		return true
+	}
+	return pending
+}
+
+// DownloadPackages is not supported, since rpm-ostree only layers packages from the configured repositories
+func (c *RpmOstreePackageManager) DownloadPackages(dir string, names ...string) (string, error) {
+	return "", fmt.Errorf("downloading packages to a local repository is not supported by %s", PackageManagerRpmOstree)
+}
+
+// UseLocalRepository is not supported, since rpm-ostree only layers packages from the configured repositories
+func (c *RpmOstreePackageManager) UseLocalRepository(dir string) error {
+	return fmt.Errorf("installing packages from a local repository is not supported by %s", PackageManagerRpmOstree)
+}
+
+// hasPendingDeployment tells if the first deployment of the rpm-ostree status is not the booted one,
+// which means it will be booted on the next reboot.
+func hasPendingDeployment(statusJSON string) (bool, error) {
+	var status struct {
+		Deployments []struct {
+			Booted bool `json:"booted"`
+		} `json:"deployments"`
+	}
+	if err := json.Unmarshal([]byte(statusJSON), &status); err != nil {
+		return false, fmt.Errorf("failed to parse rpm-ostree status: %w", err)
+	}
+
+	if len(status.Deployments) == 0 {
+		return false, fmt.Errorf("no deployment found in rpm-ostree status")
+	}
+	return !status.Deployments[0].Booted, nil
+}
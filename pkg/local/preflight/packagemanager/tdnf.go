@@ -0,0 +1,133 @@
+package packagemanager
+
+import (
+	"fmt"
+	"time"
+
+	commonns "github.com/longhorn/go-common-libs/ns"
+	commontypes "github.com/longhorn/go-common-libs/types"
+)
+
+// TdnfPackageManager manages the packages with tdnf on Azure Linux (CBL-Mariner) and VMware Photon OS.
+type TdnfPackageManager struct {
+	executor *commonns.Executor
+
+	localRepository string
+}
+
+func NewTdnfPackageManager(executor *commonns.Executor) *TdnfPackageManager {
+	return &TdnfPackageManager{
+		executor: executor,
+	}
+}
+
+// UpdatePackageList updates list of available packages
+func (c *TdnfPackageManager) UpdatePackageList() (string, error) {
+	return c.executor.Execute([]string{}, "tdnf", []string{"makecache", "-y"}, commontypes.ExecuteNoTimeout)
+}
+
+// StartPackageSession start a session to install/uninstall packages in a unique transaction
+func (c *TdnfPackageManager) StartPackageSession() (string, error) {
+	return "", nil
+}
+
+// InstallPackage executes the installation command
+func (c *TdnfPackageManager) InstallPackage(name string) (string, error) {
+	args := []string{"install", "-y", name}
+	if c.localRepository != "" {
+		args = append(args,
+			"--disablerepo=*",
+			fmt.Sprintf("--repofrompath=%s,%s", localRepositoryName, c.localRepository),
+			"--enablerepo="+localRepositoryName,
+			"--nogpgcheck",
+		)
+	}
+	return c.executor.Execute([]string{}, "tdnf", args, commontypes.ExecuteNoTimeout)
+}
+
+// UninstallPackage executes the uninstallation command
+func (c *TdnfPackageManager) UninstallPackage(name string) (string, error) {
+	return c.executor.Execute([]string{}, "tdnf", []string{"remove", "-y", name}, commontypes.ExecuteNoTimeout)
+}
+
+// Execute executes the given command with the specified environment variables, binary, and arguments.
+func (c *TdnfPackageManager) Execute(envs []string, binary string, args []string, timeout time.Duration) (string, error) {
+	return c.executor.Execute(envs, binary, args, timeout)
+}
+
+// Modprobe executes the modprobe command
+func (c *TdnfPackageManager) Modprobe(module string, opts ...string) (string, error) {
+	return c.executor.Execute([]string{}, "modprobe", append(opts, module), commontypes.ExecuteNoTimeout)
+}
+
+// CheckModLoaded checks if a module is loaded
+func (c *TdnfPackageManager) CheckModLoaded(module string) error {
+	_, err := c.executor.Execute([]string{}, "grep", []string{module, "/proc/modules"}, commontypes.ExecuteNoTimeout)
+	return err
+}
+
+// StartService executes the service start command
+func (c *TdnfPackageManager) StartService(name string) (string, error) {
+	output, err := c.executor.Execute([]string{}, "systemctl", []string{"-q", "enable", name}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return output, err
+	}
+
+	return c.executor.Execute([]string{}, "systemctl", []string{"start", name}, commontypes.ExecuteNoTimeout)
+}
+
+// RestartService executes the service restart command
+func (c *TdnfPackageManager) RestartService(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"restart", name}, commontypes.ExecuteNoTimeout)
+}
+
+// GetServiceStatus executes the service status command
+func (c *TdnfPackageManager) GetServiceStatus(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"status", "--no-pager", name}, commontypes.ExecuteNoTimeout)
+}
+
+// DisableService executes the service disable command, and stops the service
+func (c *TdnfPackageManager) DisableService(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "disable", "--now", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckServiceEnabled checks if a service is enabled
+func (c *TdnfPackageManager) CheckServiceEnabled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "is-enabled", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckPackageInstalled checks if a package is installed
+func (c *TdnfPackageManager) CheckPackageInstalled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "rpm", []string{"-q", name}, commontypes.ExecuteNoTimeout)
+}
+
+// GetPackageVersion returns the version of the installed package
+func (c *TdnfPackageManager) GetPackageVersion(name string) (string, error) {
+	return getRpmPackageVersion(c.executor, name)
+}
+
+// VersionedPackageName returns the name used to install the version of the package
+func (c *TdnfPackageManager) VersionedPackageName(name, version string) string {
+	return name + "-" + version
+}
+
+// NeedReboot tells if a reboot is needed after package installation
+func (c *TdnfPackageManager) NeedReboot() bool {
+	return false
+}
+
+// DownloadPackages downloads the packages and their dependencies to the directory, and generates
+// the metadata of the local repository
+func (c *TdnfPackageManager) DownloadPackages(dir string, names ...string) (string, error) {
+	script := fmt.Sprintf(`set -e
+mkdir -p %[1]s
+tdnf install -y --downloadonly --alldeps --downloaddir %[1]s %[2]s
+%[3]s`, shellQuote(dir), shellQuoteAll(names), createRepositoryScript(dir))
+	return c.executor.Execute([]string{}, "sh", []string{"-c", script}, commontypes.ExecuteNoTimeout)
+}
+
+// UseLocalRepository installs the packages only from the local repository directory
+func (c *TdnfPackageManager) UseLocalRepository(dir string) error {
+	c.localRepository = dir
+	return nil
+}
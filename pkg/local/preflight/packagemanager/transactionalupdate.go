@@ -13,6 +13,8 @@ const (
 
 type TransactionalUpdatePackageManager struct {
 	executor *commonns.Executor
+
+	localRepository string
 }
 
 func NewTransactionalUpdatePackageManager(executor *commonns.Executor) *TransactionalUpdatePackageManager {
@@ -34,6 +36,11 @@ func (c *TransactionalUpdatePackageManager) StartPackageSession() (string, error
 
 // InstallPackage executes the installation command
 func (c *TransactionalUpdatePackageManager) InstallPackage(name string) (string, error) {
+	if c.localRepository != "" {
+		// "pkg install" does not accept the zypper global options, so run zypper in the snapshot directly.
+		args := append([]string{"--continue", "--non-interactive", "run", "zypper"}, zypperLocalRepositoryOptions(c.localRepository)...)
+		return c.executor.Execute([]string{}, packageCommand, append(args, "--non-interactive", "install", name), commontypes.ExecuteNoTimeout)
+	}
 	return c.executor.Execute([]string{}, packageCommand, []string{"--continue", "--non-interactive", "pkg", "install", name}, commontypes.ExecuteNoTimeout)
 }
 
@@ -78,11 +85,31 @@ func (c *TransactionalUpdatePackageManager) GetServiceStatus(name string) (strin
 	return c.executor.Execute([]string{}, "systemctl", []string{"status", "--no-pager", name}, commontypes.ExecuteNoTimeout)
 }
 
+// DisableService executes the service disable command, and stops the service
+func (c *TransactionalUpdatePackageManager) DisableService(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "disable", "--now", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckServiceEnabled checks if a service is enabled
+func (c *TransactionalUpdatePackageManager) CheckServiceEnabled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "is-enabled", name}, commontypes.ExecuteNoTimeout)
+}
+
 // CheckPackageInstalled checks if a package is installed
 func (c *TransactionalUpdatePackageManager) CheckPackageInstalled(name string) (string, error) {
 	return c.executor.Execute([]string{}, "rpm", []string{"-q", name}, commontypes.ExecuteNoTimeout)
 }
 
+// GetPackageVersion returns the version of the installed package
+func (c *TransactionalUpdatePackageManager) GetPackageVersion(name string) (string, error) {
+	return getRpmPackageVersion(c.executor, name)
+}
+
+// VersionedPackageName returns the name used to install the version of the package
+func (c *TransactionalUpdatePackageManager) VersionedPackageName(name, version string) string {
+	return name + "=" + version
+}
+
 // NeedReboot tells if a reboot is needed after package installation
 // Note: SLE Micro OS always requires a reboot after package installation
 // to ensure newly installed packages are properly integrated into the
@@ -90,3 +117,14 @@ func (c *TransactionalUpdatePackageManager) CheckPackageInstalled(name string) (
 func (c *TransactionalUpdatePackageManager) NeedReboot() bool {
 	return true
 }
+
+// DownloadPackages downloads the packages and their dependencies not installed yet to the directory
+func (c *TransactionalUpdatePackageManager) DownloadPackages(dir string, names ...string) (string, error) {
+	return zypperDownloadPackages(c.executor, dir, names...)
+}
+
+// UseLocalRepository installs the packages with the local repository directory added as a temporary repository
+func (c *TransactionalUpdatePackageManager) UseLocalRepository(dir string) error {
+	c.localRepository = dir
+	return nil
+}
@@ -1,6 +1,7 @@
 package packagemanager
 
 import (
+	"fmt"
 	"time"
 
 	commonns "github.com/longhorn/go-common-libs/ns"
@@ -9,6 +10,8 @@ import (
 
 type YumPackageManager struct {
 	executor *commonns.Executor
+
+	localRepository string
 }
 
 func NewYumPackageManager(executor *commonns.Executor) *YumPackageManager {
@@ -29,7 +32,16 @@ func (c *YumPackageManager) StartPackageSession() (string, error) {
 
 // InstallPackage executes the installation command
 func (c *YumPackageManager) InstallPackage(name string) (string, error) {
-	return c.executor.Execute([]string{}, "yum", []string{"install", name, "-y"}, commontypes.ExecuteNoTimeout)
+	args := []string{"install", name, "-y"}
+	if c.localRepository != "" {
+		args = append(args,
+			"--disablerepo=*",
+			fmt.Sprintf("--repofrompath=%s,%s", localRepositoryName, c.localRepository),
+			"--enablerepo="+localRepositoryName,
+			"--nogpgcheck",
+		)
+	}
+	return c.executor.Execute([]string{}, "yum", args, commontypes.ExecuteNoTimeout)
 }
 
 // UninstallPackage executes the uninstallation command
@@ -73,12 +85,53 @@ func (c *YumPackageManager) GetServiceStatus(name string) (string, error) {
 	return c.executor.Execute([]string{}, "systemctl", []string{"status", "--no-pager", name}, commontypes.ExecuteNoTimeout)
 }
 
+// DisableService executes the service disable command, and stops the service
+func (c *YumPackageManager) DisableService(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "disable", "--now", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckServiceEnabled checks if a service is enabled
+func (c *YumPackageManager) CheckServiceEnabled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "is-enabled", name}, commontypes.ExecuteNoTimeout)
+}
+
 // CheckPackageInstalled checks if a package is installed
 func (c *YumPackageManager) CheckPackageInstalled(name string) (string, error) {
 	return c.executor.Execute([]string{}, "rpm", []string{"-q", name}, commontypes.ExecuteNoTimeout)
 }
 
+// GetPackageVersion returns the version of the installed package
+func (c *YumPackageManager) GetPackageVersion(name string) (string, error) {
+	return getRpmPackageVersion(c.executor, name)
+}
+
+// VersionedPackageName returns the name used to install the version of the package
+func (c *YumPackageManager) VersionedPackageName(name, version string) string {
+	return name + "-" + version
+}
+
 // NeedReboot tells if a reboot is needed after package installation
 func (c *YumPackageManager) NeedReboot() bool {
 	return false
 }
+
+// DownloadPackages downloads the packages and all their dependencies to the directory, and generates
+// the repository metadata. It requires the download command of dnf, yum alone cannot resolve the
+// dependencies already installed.
+func (c *YumPackageManager) DownloadPackages(dir string, names ...string) (string, error) {
+	script := fmt.Sprintf(`set -e
+if ! dnf download --help >/dev/null 2>&1; then
+	echo "dnf download is required to download the packages, install dnf-plugins-core or export the bundle on a host with dnf" >&2
+	exit 1
+fi
+mkdir -p %[1]s
+dnf download --resolve --alldeps --destdir %[1]s %[2]s
+%[3]s`, shellQuote(dir), shellQuoteAll(names), createRepositoryScript(dir))
+	return c.executor.Execute([]string{}, "sh", []string{"-c", script}, commontypes.ExecuteNoTimeout)
+}
+
+// UseLocalRepository installs the packages only from the local repository directory
+func (c *YumPackageManager) UseLocalRepository(dir string) error {
+	c.localRepository = dir
+	return nil
+}
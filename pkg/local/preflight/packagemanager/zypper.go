@@ -1,6 +1,8 @@
 package packagemanager
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	commonns "github.com/longhorn/go-common-libs/ns"
@@ -9,6 +11,8 @@ import (
 
 type ZypperPackageManager struct {
 	executor *commonns.Executor
+
+	localRepository string
 }
 
 func NewZypperPackageManager(executor *commonns.Executor) *ZypperPackageManager {
@@ -29,7 +33,12 @@ func (c *ZypperPackageManager) StartPackageSession() (string, error) {
 
 // InstallPackage executes the installation command
 func (c *ZypperPackageManager) InstallPackage(name string) (string, error) {
-	return c.executor.Execute([]string{}, "zypper", []string{"--non-interactive", "install", name}, commontypes.ExecuteNoTimeout)
+	args := append(zypperLocalRepositoryOptions(c.localRepository), "--non-interactive", "install")
+	if strings.Contains(name, "=") {
+		// The pinned version may be older than the installed one.
+		args = append(args, "--oldpackage")
+	}
+	return c.executor.Execute([]string{}, "zypper", append(args, name), commontypes.ExecuteNoTimeout)
 }
 
 // UninstallPackage executes the uninstallation command
@@ -73,12 +82,67 @@ func (c *ZypperPackageManager) GetServiceStatus(name string) (string, error) {
 	return c.executor.Execute([]string{}, "systemctl", []string{"status", "--no-pager", name}, commontypes.ExecuteNoTimeout)
 }
 
+// DisableService executes the service disable command, and stops the service
+func (c *ZypperPackageManager) DisableService(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "disable", "--now", name}, commontypes.ExecuteNoTimeout)
+}
+
+// CheckServiceEnabled checks if a service is enabled
+func (c *ZypperPackageManager) CheckServiceEnabled(name string) (string, error) {
+	return c.executor.Execute([]string{}, "systemctl", []string{"-q", "is-enabled", name}, commontypes.ExecuteNoTimeout)
+}
+
 // CheckPackageInstalled checks if a package is installed
 func (c *ZypperPackageManager) CheckPackageInstalled(name string) (string, error) {
 	return c.executor.Execute([]string{}, "rpm", []string{"-q", name}, commontypes.ExecuteNoTimeout)
 }
 
+// GetPackageVersion returns the version of the installed package
+func (c *ZypperPackageManager) GetPackageVersion(name string) (string, error) {
+	return getRpmPackageVersion(c.executor, name)
+}
+
+// VersionedPackageName returns the name used to install the version of the package
+func (c *ZypperPackageManager) VersionedPackageName(name, version string) string {
+	return name + "=" + version
+}
+
 // NeedReboot tells if a reboot is needed after package installation
 func (c *ZypperPackageManager) NeedReboot() bool {
 	return false
 }
+
+// DownloadPackages downloads the packages and their dependencies not installed yet to the directory
+func (c *ZypperPackageManager) DownloadPackages(dir string, names ...string) (string, error) {
+	return zypperDownloadPackages(c.executor, dir, names...)
+}
+
+// UseLocalRepository installs the packages with the local repository directory added as a temporary repository
+func (c *ZypperPackageManager) UseLocalRepository(dir string) error {
+	c.localRepository = dir
+	return nil
+}
+
+// zypperDownloadPackages downloads the packages and all their dependencies to the directory, and generates
+// the repository metadata. The packages are resolved against an empty installation root sharing the
+// repositories of the host, so the dependencies already installed on the host are downloaded as well,
+// and then moved out of the repository subdirectories of the package cache.
+func zypperDownloadPackages(executor *commonns.Executor, dir string, names ...string) (string, error) {
+	script := fmt.Sprintf(`set -e
+mkdir -p %[1]s
+root=$(mktemp -d)
+trap 'rm -rf "$root"' EXIT
+zypper --non-interactive --gpg-auto-import-keys --installroot "$root" --pkg-cache-dir "$root/cache" install --download-only %[2]s
+find "$root" -name '*.rpm' -exec mv -f {} %[1]s \;
+%[3]s`, shellQuote(dir), shellQuoteAll(names), createRepositoryScript(dir))
+	return executor.Execute([]string{}, "sh", []string{"-c", script}, commontypes.ExecuteNoTimeout)
+}
+
+// zypperLocalRepositoryOptions returns the zypper global options adding the local repository directory
+// as a temporary repository, without refreshing the unreachable remote repositories.
+func zypperLocalRepositoryOptions(dir string) []string {
+	if dir == "" {
+		return []string{}
+	}
+	return []string{"--no-gpg-checks", "--no-refresh", "--plus-repo", "dir:" + dir}
+}
@@ -0,0 +1,553 @@
+package preflight
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	commontypes "github.com/longhorn/go-common-libs/types"
+
+	"github.com/longhorn/cli/pkg/consts"
+
+	pkgmgr "github.com/longhorn/cli/pkg/local/preflight/packagemanager"
+)
+
+// pciAddressPattern matches the PCI address in the domain:bus:device.function format.
+var pciAddressPattern = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
+// listPciDevicesScript prints the address, class, driver, and the serial number and model of the
+// NVMe controller, of each PCI device of the host, separated by tabs.
+const listPciDevicesScript = `for dev in /sys/bus/pci/devices/*; do
+	driver=$(readlink "$dev/driver"); driver=${driver##*/}
+	serial=$(cat "$dev"/nvme/nvme*/serial 2>/dev/null | head -n 1)
+	model=$(cat "$dev"/nvme/nvme*/model 2>/dev/null | head -n 1)
+	printf '%s\t%s\t%s\t%s\t%s\n' "${dev##*/}" "$(cat "$dev/class")" "$driver" "$serial" "$model"
+done`
+
+// listNvmeDisksScript prints the address of each NVMe controller bound to the kernel nvme driver, followed
+// by its disks, separated by tabs. With the native NVMe multipath, the disks are the namespace heads of
+// the NVMe subsystem of the controller, rather than the hidden namespace paths of the controller.
+const listNvmeDisksScript = `for ctrl in /sys/bus/pci/devices/*/nvme/nvme*; do
+	[ -d "$ctrl" ] || continue
+	dev=${ctrl%/nvme/*}
+	disks=""
+	for ns in "$ctrl"/nvme*n* /sys/class/nvme-subsystem/*/nvme*n*; do
+		case "$ns" in
+		/sys/class/nvme-subsystem/*) [ -e "${ns%/*}/${ctrl##*/}" ] || continue ;;
+		esac
+		[ -e "/sys/block/${ns##*/}" ] && disks="$disks	${ns##*/}"
+	done
+	printf '%s%s\n' "${dev##*/}" "$disks"
+done`
+
+// resolveDiskScript prints the name of the disk of the device path $1, or of the disk holding the
+// partition.
+const resolveDiskScript = `name=$(basename "$(readlink -f "$1")")
+if [ -e "/sys/class/block/$name/partition" ]; then
+	name=$(basename "$(dirname "$(readlink -f "/sys/class/block/$name")")")
+fi
+echo "$name"`
+
+// userspacePciDrivers are the drivers binding the PCI devices to the userspace for SPDK.
+var userspacePciDrivers = []string{"vfio-pci", "uio_pci_generic", "igb_uio"}
+
+// pciDevice is a PCI device of the host. The serial number and model are only known for the NVMe
+// controllers bound to the kernel nvme driver.
+type pciDevice struct {
+	Address string
+	Class   string
+	Driver  string
+	Serial  string
+	Model   string
+}
+
+func (device *pciDevice) isNvme() bool {
+	return device.Class == consts.PciClassNvme
+}
+
+// isBoundToUserspace checks if the device is bound to a userspace driver, after which it is not an NVMe
+// controller of the kernel anymore.
+func (device *pciDevice) isBoundToUserspace() bool {
+	return slices.Contains(userspacePciDrivers, device.Driver)
+}
+
+func (device *pciDevice) String() string {
+	if device.Serial == "" {
+		return device.Address
+	}
+	return fmt.Sprintf("%s (model %q, serial %q)", device.Address, device.Model, device.Serial)
+}
+
+// pciSelectorResolution is the PCI addresses resolved from a PCI device selector.
+type pciSelectorResolution struct {
+	Selector  string
+	Addresses []string
+	Allowed   []string // PCI addresses of the selector safe to be bound to the userspace driver.
+}
+
+// pciSelection is the PCI devices selected by the allowed PCI devices option on the host.
+type pciSelection struct {
+	Resolutions []pciSelectorResolution
+	Allowed     []string          // Sorted PCI addresses safe to be bound to the userspace driver.
+	Refused     map[string]string // Reasons of the selected PCI devices refused to be bound, by PCI address.
+}
+
+// selectPciDevices resolves the allowed PCI devices option to the PCI addresses on the host, and
+// reports the resolution.
+func (local *Installer) selectPciDevices() error {
+	selection, err := selectPciDevices(local.packageManager, local.AllowPci, local.state.PciSelectors)
+	if err != nil {
+		return errors.Wrap(err, "failed to select allowed PCI devices")
+	}
+
+	for _, resolution := range selection.Resolutions {
+		if len(resolution.Addresses) == 0 {
+			local.logWarn("PCI device selector %q matches no PCI device", resolution.Selector)
+			continue
+		}
+		local.logInfo("Resolved PCI device selector %q to %v", resolution.Selector, strings.Join(resolution.Addresses, ", "))
+	}
+	for _, address := range sortedKeys(selection.Refused) {
+		local.logWarn("Refused to bind PCI device %v to the userspace driver: %v", address, selection.Refused[address])
+	}
+
+	local.pciAddresses = selection.Allowed
+	if len(selection.Resolutions) > 0 {
+		local.logInfo("Allowed PCI devices: %v", formatPciAllowed(local.pciAddresses))
+	}
+
+	if !local.DryRun {
+		// The selectors other than the PCI addresses do not match the devices once bound to the userspace driver.
+		local.state.PciSelectors = map[string][]string{}
+		for _, resolution := range selection.Resolutions {
+			if len(resolution.Allowed) > 0 {
+				local.state.PciSelectors[resolution.Selector] = resolution.Allowed
+			}
+		}
+		local.saveState()
+	}
+	return nil
+}
+
+// selectPciDevices resolves the comma-separated PCI device selectors to the PCI addresses on the host.
+// The devices in use, with a mounted filesystem, a filesystem or member signature, an active swap or a
+// device holding them, are refused, as well as the NVMe controllers whose disks are not found.
+//
+// Supported selectors:
+//   - PCI address, in the domain:bus:device.function or the bus:device.function format
+//   - serial=<serial number> of the NVMe controller
+//   - model=<shell pattern> matching the model of the NVMe controllers
+//   - /dev/disk/by-id/<name> of the disk
+//   - all-unused-nvme for all the NVMe controllers not in use
+//
+// The devices already bound to the userspace driver are also matched by the selectors they were resolved
+// from by the previous run of the installer, in recorded.
+func selectPciDevices(packageManager pkgmgr.PackageManager, allowPci string, recorded map[string][]string) (*pciSelection, error) {
+	selection := &pciSelection{
+		Refused: map[string]string{},
+	}
+
+	selectors := parsePciSelectors(allowPci)
+	if len(selectors) == 0 {
+		return selection, nil
+	}
+
+	output, err := packageManager.Execute([]string{}, "sh", []string{"-c", listPciDevicesScript}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list PCI devices")
+	}
+	devices := parsePciDevices(output)
+
+	disks, err := listPciDisks(packageManager)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := newBlockDeviceUsage(packageManager)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := map[string]bool{}
+	for _, selector := range selectors {
+		addresses, err := resolvePciSelector(packageManager, selector, devices, disks)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, matchRecordedPciDevices(devices, recorded[selector], addresses)...)
+
+		resolution := pciSelectorResolution{Selector: selector}
+		for _, device := range addresses {
+			resolution.Addresses = append(resolution.Addresses, device.String())
+
+			diskNames := disks.byAddress[device.Address]
+			if disks.nvmeControllers[device.Address] && len(diskNames) == 0 {
+				// Fail closed, the NVMe controller in use cannot be told apart from an unused one.
+				selection.Refused[device.Address] = "no disk is found for the NVMe controller to verify it is not in use"
+				continue
+			}
+			if reason := usage.inUseReason(diskNames); reason != "" {
+				selection.Refused[device.Address] = reason
+				continue
+			}
+			allowed[device.Address] = true
+			resolution.Allowed = append(resolution.Allowed, device.Address)
+		}
+		selection.Resolutions = append(selection.Resolutions, resolution)
+	}
+
+	selection.Allowed = sortedKeys(allowed)
+	return selection, nil
+}
+
+// resolvePciSelector returns the PCI devices matching the selector.
+func resolvePciSelector(packageManager pkgmgr.PackageManager, selector string, devices []pciDevice, disks *pciDisks) ([]pciDevice, error) {
+	if strings.HasPrefix(selector, consts.PciSelectorDiskByIDPrefix) {
+		output, err := packageManager.Execute([]string{}, "sh", []string{"-c", resolveDiskScript, "sh", selector}, commontypes.ExecuteNoTimeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve disk %v", selector)
+		}
+		addresses := disks.addressesOf(strings.TrimSpace(output))
+		return matchPciDevices(devices, func(device *pciDevice) bool { return slices.Contains(addresses, device.Address) }), nil
+	}
+
+	return matchPciDevices(devices, newPciSelectorMatcher(selector)), nil
+}
+
+// newPciSelectorMatcher returns the function matching the PCI devices of the selector, except the
+// /dev/disk/by-id selector resolved on the host.
+func newPciSelectorMatcher(selector string) func(device *pciDevice) bool {
+	switch {
+	case selector == consts.PciSelectorAllUnusedNvme:
+		// The devices in use are refused afterwards.
+		return func(device *pciDevice) bool { return device.isNvme() && device.Driver == "nvme" }
+	case strings.HasPrefix(selector, consts.PciSelectorSerialPrefix):
+		serial := strings.TrimPrefix(selector, consts.PciSelectorSerialPrefix)
+		return func(device *pciDevice) bool { return device.isNvme() && device.Serial == serial }
+	case strings.HasPrefix(selector, consts.PciSelectorModelPrefix):
+		pattern := strings.TrimPrefix(selector, consts.PciSelectorModelPrefix)
+		return func(device *pciDevice) bool {
+			matched, _ := path.Match(pattern, device.Model)
+			return device.isNvme() && device.Model != "" && matched
+		}
+	default:
+		address := normalizePciAddress(selector)
+		return func(device *pciDevice) bool { return device.Address == address }
+	}
+}
+
+// matchRecordedPciDevices returns the devices bound to the userspace driver at the recorded PCI addresses,
+// except the devices already matched.
+func matchRecordedPciDevices(devices []pciDevice, recorded []string, matched []pciDevice) []pciDevice {
+	return matchPciDevices(devices, func(device *pciDevice) bool {
+		return device.isBoundToUserspace() && slices.Contains(recorded, device.Address) &&
+			!slices.ContainsFunc(matched, func(other pciDevice) bool { return other.Address == device.Address })
+	})
+}
+
+func matchPciDevices(devices []pciDevice, match func(device *pciDevice) bool) []pciDevice {
+	var matched []pciDevice
+	for i := range devices {
+		if match(&devices[i]) {
+			matched = append(matched, devices[i])
+		}
+	}
+	return matched
+}
+
+// parsePciSelectors returns the PCI device selectors of the comma-separated allowed PCI devices option.
+func parsePciSelectors(allowPci string) []string {
+	var selectors []string
+	for _, selector := range strings.Split(allowPci, consts.CmdOptSeperator) {
+		selector = strings.TrimSpace(selector)
+		if selector == "" || selector == consts.PciSelectorNone {
+			continue
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors
+}
+
+// normalizePciAddress prepends the PCI domain to the short bus:device.function address.
+func normalizePciAddress(address string) string {
+	address = strings.ToLower(address)
+	if strings.Count(address, ":") == 1 {
+		address = "0000:" + address
+	}
+	return address
+}
+
+// parsePciDevices parses the output of listPciDevicesScript.
+func parsePciDevices(output string) []pciDevice {
+	var devices []pciDevice
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 || fields[0] == "" {
+			continue
+		}
+		devices = append(devices, pciDevice{
+			Address: fields[0],
+			Class:   strings.TrimSpace(fields[1]),
+			Driver:  fields[2],
+			Serial:  strings.TrimSpace(fields[3]),
+			Model:   strings.TrimSpace(fields[4]),
+		})
+	}
+	return devices
+}
+
+// parsePciAddressFromSysfsPath returns the PCI address of the device closest to the sysfs device path,
+// or an empty string if the device is not on the PCI bus.
+//
+// Example path:
+//
+//	/sys/devices/pci0000:00/0000:00:1d.0/0000:01:00.0/nvme/nvme0/nvme0n1/nvme0n1p1
+func parsePciAddressFromSysfsPath(sysfsPath string) string {
+	elements := strings.Split(sysfsPath, "/")
+	for i := len(elements) - 1; i >= 0; i-- {
+		if pciAddressPattern.MatchString(elements[i]) {
+			return elements[i]
+		}
+	}
+	return ""
+}
+
+// pciDisks holds the disks of the host by the PCI address of their controller.
+type pciDisks struct {
+	byAddress map[string][]string
+	// nvmeControllers are the PCI addresses of the NVMe controllers bound to the kernel nvme driver.
+	nvmeControllers map[string]bool
+}
+
+// addressesOf returns the sorted PCI addresses of the controllers of the disk. A multipath NVMe namespace
+// is reached through several controllers.
+func (disks *pciDisks) addressesOf(disk string) []string {
+	var addresses []string
+	for address, names := range disks.byAddress {
+		if slices.Contains(names, disk) {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// listPciDisks returns the disks of the host by the PCI address of their controller. The disks are found
+// by their sysfs device path, and the NVMe disks by their controller and NVMe subsystem, since the
+// multipath NVMe namespaces are virtual devices not under the controller.
+func listPciDisks(packageManager pkgmgr.PackageManager) (*pciDisks, error) {
+	output, err := packageManager.Execute([]string{}, "sh", []string{"-c", "readlink -f /sys/block/*"}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list disks")
+	}
+
+	nvmeOutput, err := packageManager.Execute([]string{}, "sh", []string{"-c", listNvmeDisksScript}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list NVMe disks")
+	}
+
+	disks := parseNvmeDisks(nvmeOutput)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if address := parsePciAddressFromSysfsPath(line); address != "" {
+			disks.byAddress[address] = appendUnique(disks.byAddress[address], path.Base(line))
+		}
+	}
+	return disks, nil
+}
+
+// parseNvmeDisks parses the output of listNvmeDisksScript.
+//
+// Example output:
+//
+//	0000:01:00.0	nvme0n1	nvme0n2
+//	0000:02:00.0
+func parseNvmeDisks(output string) *pciDisks {
+	disks := &pciDisks{
+		byAddress:       map[string][]string{},
+		nvmeControllers: map[string]bool{},
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !pciAddressPattern.MatchString(fields[0]) {
+			continue
+		}
+
+		address := fields[0]
+		disks.nvmeControllers[address] = true
+		for _, name := range fields[1:] {
+			disks.byAddress[address] = appendUnique(disks.byAddress[address], name)
+		}
+	}
+	return disks
+}
+
+// blockDevice is a block device reported by lsblk.
+type blockDevice struct {
+	Name       string
+	Parent     string
+	Type       string
+	FsType     string
+	MountPoint string
+}
+
+// blockDeviceUsage holds the block devices of the host, and the active swap devices.
+type blockDeviceUsage struct {
+	devices []blockDevice
+	swaps   map[string]bool
+}
+
+func newBlockDeviceUsage(packageManager pkgmgr.PackageManager) (*blockDeviceUsage, error) {
+	output, err := packageManager.Execute([]string{}, "lsblk", []string{"-P", "-n", "-o", "KNAME,PKNAME,TYPE,FSTYPE,MOUNTPOINT"}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list block devices")
+	}
+
+	swaps, err := packageManager.Execute([]string{}, "cat", []string{"/proc/swaps"}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read /proc/swaps")
+	}
+
+	return &blockDeviceUsage{
+		devices: parseLsblkPairs(output),
+		swaps:   parseSwaps(swaps),
+	}, nil
+}
+
+// inUseReason returns the reason the disks are in use, or an empty string if none of the disks, their
+// partitions and the devices holding them, recursively, is in use. A device is in use when it is mounted,
+// used as an active swap, has a filesystem or a member signature (e.g. LVM, LUKS, MD RAID or ZFS), or is
+// a device-mapper or MD RAID device holding the disks.
+func (usage *blockDeviceUsage) inUseReason(disks []string) string {
+	// lsblk lists a device held by several devices once per holder, with the holder as the parent.
+	related := map[string]bool{}
+	queue := slices.Clone(disks)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if related[name] {
+			continue
+		}
+		related[name] = true
+
+		for _, device := range usage.devices {
+			if device.Parent == name {
+				queue = append(queue, device.Name)
+			}
+		}
+	}
+
+	var reasons []string
+	reported := map[string]bool{}
+	for _, device := range usage.devices {
+		if !related[device.Name] || reported[device.Name] {
+			continue
+		}
+
+		var reason string
+		switch {
+		case device.MountPoint == "[SWAP]" || usage.swaps[device.Name]:
+			reason = fmt.Sprintf("/dev/%s is an active swap", device.Name)
+		case device.MountPoint != "":
+			reason = fmt.Sprintf("/dev/%s is mounted at %s", device.Name, device.MountPoint)
+		case device.FsType == "LVM2_member":
+			reason = fmt.Sprintf("/dev/%s is an LVM physical volume", device.Name)
+		case device.FsType != "":
+			reason = fmt.Sprintf("/dev/%s has a %s signature", device.Name, device.FsType)
+		case device.Type != "" && device.Type != "disk" && device.Type != "part":
+			reason = fmt.Sprintf("/dev/%s is an active %s device", device.Name, device.Type)
+		default:
+			continue
+		}
+		reasons = append(reasons, reason)
+		reported[device.Name] = true
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// lsblkPairPattern matches the KEY="value" pairs of the lsblk -P output.
+var lsblkPairPattern = regexp.MustCompile(`([A-Z:]+)="([^"]*)"`)
+
+// parseLsblkPairs parses the output of lsblk -P -o KNAME,PKNAME,TYPE,FSTYPE,MOUNTPOINT.
+//
+// Example line:
+//
+//	KNAME="nvme0n1p1" PKNAME="nvme0n1" TYPE="part" FSTYPE="ext4" MOUNTPOINT="/boot"
+func parseLsblkPairs(output string) []blockDevice {
+	var devices []blockDevice
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		values := map[string]string{}
+		for _, match := range lsblkPairPattern.FindAllStringSubmatch(scanner.Text(), -1) {
+			values[match[1]] = match[2]
+		}
+		if values["KNAME"] == "" {
+			continue
+		}
+		devices = append(devices, blockDevice{
+			Name:       values["KNAME"],
+			Parent:     values["PKNAME"],
+			Type:       values["TYPE"],
+			FsType:     values["FSTYPE"],
+			MountPoint: values["MOUNTPOINT"],
+		})
+	}
+	return devices
+}
+
+// parseSwaps returns the names of the active swap devices of /proc/swaps.
+func parseSwaps(swaps string) map[string]bool {
+	devices := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(swaps))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+		devices[strings.TrimPrefix(fields[0], "/dev/")] = true
+	}
+	return devices
+}
+
+// formatPciAllowed returns the PCI_ALLOWED value of the SPDK setup script. The non-valid address
+// blocks all PCI devices when none is allowed, since an empty value allows all of them.
+func formatPciAllowed(addresses []string) string {
+	if len(addresses) == 0 {
+		return consts.PciSelectorNone
+	}
+	return strings.Join(addresses, " ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// logPciSelection reports the PCI devices selected by the allowed PCI devices option of the checker.
+func (local *Checker) logPciSelection(topic string, selection *pciSelection) {
+	for _, resolution := range selection.Resolutions {
+		if len(resolution.Addresses) == 0 {
+			local.collection.Log.Warn = append(local.collection.Log.Warn,
+				wrapMsgWithTopic(topic, fmt.Sprintf("PCI device selector %q matches no PCI device", resolution.Selector)))
+			continue
+		}
+		local.collection.Log.Info = append(local.collection.Log.Info,
+			wrapMsgWithTopic(topic, fmt.Sprintf("PCI device selector %q is resolved to %v", resolution.Selector, strings.Join(resolution.Addresses, ", "))))
+	}
+	for _, address := range sortedKeys(selection.Refused) {
+		local.collection.Log.Warn = append(local.collection.Log.Warn,
+			wrapMsgWithTopic(topic, fmt.Sprintf("PCI device %v will not be bound to the userspace driver: %v", address, selection.Refused[address])))
+	}
+}
@@ -0,0 +1,88 @@
+package preflight
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/longhorn/cli/pkg/consts"
+)
+
+// installerState records the changes made by the preflight installer on the host,
+// so they can be reverted by the preflight uninstaller. Only the changes made by
+// the installer are recorded, the packages, modules and services already present
+// on the host are left untouched.
+type installerState struct {
+	Packages   []string `json:"packages,omitempty"`   // Packages installed by the installer.
+	Modules    []string `json:"modules,omitempty"`    // Kernel modules loaded by the installer.
+	Services   []string `json:"services,omitempty"`   // Services enabled by the installer.
+	Files      []string `json:"files,omitempty"`      // Files created by the installer.
+	KernelArgs []string `json:"kernelArgs,omitempty"` // Kernel parameters added to the boot entries by the installer.
+
+	// PciSelectors are the PCI addresses resolved from each allowed PCI device selector, so the devices are
+	// still matched once bound to the userspace driver. They are not reverted.
+	PciSelectors map[string][]string `json:"pciSelectors,omitempty"`
+}
+
+// stateFilePath returns the path of the installer state file on the host mount.
+func stateFilePath() string {
+	return filepath.Join(consts.VolumeMountHostDirectory, consts.PreflightInstallerStateFile)
+}
+
+// loadInstallerState reads the installer state from the host. It returns an empty
+// state when the installer has not recorded any change yet.
+func loadInstallerState() (*installerState, error) {
+	state := &installerState{}
+
+	content, err := os.ReadFile(stateFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read %v", consts.PreflightInstallerStateFile)
+	}
+
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %v", consts.PreflightInstallerStateFile)
+	}
+	return state, nil
+}
+
+// save writes the installer state to the host, or removes the state file when
+// there is no change left to revert.
+func (state *installerState) save() error {
+	path := stateFilePath()
+
+	if state.isEmpty() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove %v", consts.PreflightInstallerStateFile)
+		}
+		return nil
+	}
+
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to convert installer state to JSON")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %v", consts.PreflightInstallerStateFile)
+	}
+	return errors.Wrapf(os.WriteFile(path, content, 0644), "failed to write %v", consts.PreflightInstallerStateFile)
+}
+
+func (state *installerState) isEmpty() bool {
+	return len(state.Packages) == 0 && len(state.Modules) == 0 && len(state.Services) == 0 && len(state.Files) == 0 && len(state.KernelArgs) == 0 &&
+		len(state.PciSelectors) == 0
+}
+
+// appendUnique appends the item to the list if it is not in the list yet.
+func appendUnique(list []string, item string) []string {
+	if slices.Contains(list, item) {
+		return list
+	}
+	return append(list, item)
+}
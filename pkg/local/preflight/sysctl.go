@@ -0,0 +1,270 @@
+package preflight
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	commontypes "github.com/longhorn/go-common-libs/types"
+
+	"github.com/longhorn/cli/pkg/consts"
+
+	pkgmgr "github.com/longhorn/cli/pkg/local/preflight/packagemanager"
+)
+
+// recommendedSysctls are the minimum recommended values of the kernel parameters.
+//
+//   - fs.inotify.max_user_instances: exhausted by the many pods watching files on the node.
+var recommendedSysctls = map[string]uint64{
+	"fs.inotify.max_user_instances": 8192,
+}
+
+// recommendedSpdkSysctls are the minimum recommended values of the kernel parameters when SPDK is enabled.
+//
+//   - fs.aio-max-nr: exhausted by the asynchronous I/O contexts of SPDK.
+var recommendedSpdkSysctls = map[string]uint64{
+	"fs.aio-max-nr": 1048576,
+}
+
+// recommendedContainerdOpenFiles is the minimum recommended open files limit of containerd.
+const recommendedContainerdOpenFiles = 65536
+
+// checkHostSettings checks the kernel parameters, the containerd open files limit and the
+// cgroup version of the host against the recommended values.
+func (local *Checker) checkHostSettings() error {
+	logrus.Info("Checking kernel parameters, containerd open files limit and cgroup version")
+	topic := formatTopic(consts.PreflightCheckTopicHostSettings)
+
+	var internalError = map[string]any{}
+
+	sysctls := getRecommendedSysctls(local.EnableSpdk)
+	for _, name := range sortedKeys(sysctls) {
+		value, err := getSysctl(local.packageManager, name)
+		if err != nil {
+			internalError[name] = err
+			continue
+		}
+
+		recommended := sysctls[name]
+		if value < recommended {
+			local.collection.Log.Warn = append(local.collection.Log.Warn,
+				wrapMsgWithTopic(topic, fmt.Sprintf("%s is %d, lower than the recommended %d. Use %s %s %s --%s to persist the recommended value",
+					name, value, recommended, consts.CmdLonghornctlRemote, consts.SubCmdInstall, consts.SubCmdPreflight, consts.CmdOptConfigureSysctl)))
+		} else {
+			local.collection.Log.Info = append(local.collection.Log.Info,
+				wrapMsgWithTopic(topic, fmt.Sprintf("%s is %d", name, value)))
+		}
+	}
+
+	if err := local.checkContainerdOpenFiles(topic); err != nil {
+		internalError["containerd open files"] = err
+	}
+
+	if err := local.checkCgroupVersion(topic); err != nil {
+		internalError["cgroup version"] = err
+	}
+
+	if len(internalError) > 0 {
+		return wrapAggregatedInternalError(topic, "Failed to check host settings:", internalError)
+	}
+
+	return nil
+}
+
+// checkContainerdOpenFiles checks the soft open files limit of the containerd process.
+func (local *Checker) checkContainerdOpenFiles(topic string) error {
+	output, err := local.packageManager.Execute([]string{}, "pgrep", []string{"-x", "-o", "containerd"}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		if isExitCode(err, 1) {
+			local.collection.Log.Info = append(local.collection.Log.Info,
+				wrapMsgWithTopic(topic, "containerd process is not found, skipped checking its open files limit"))
+			return nil
+		}
+		return errors.Wrap(err, "failed to find containerd process")
+	}
+	pid := strings.TrimSpace(output)
+
+	limits, err := local.packageManager.Execute([]string{}, "cat", []string{filepath.Join("/proc", pid, "limits")}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read limits of containerd process %v", pid)
+	}
+
+	openFiles, err := parseMaxOpenFiles(limits)
+	if err != nil {
+		return err
+	}
+
+	if openFiles < recommendedContainerdOpenFiles {
+		local.collection.Log.Warn = append(local.collection.Log.Warn,
+			wrapMsgWithTopic(topic, fmt.Sprintf("containerd open files limit is %d, lower than the recommended %d. Please increase LimitNOFILE of the containerd service",
+				openFiles, recommendedContainerdOpenFiles)))
+		return nil
+	}
+
+	local.collection.Log.Info = append(local.collection.Log.Info,
+		wrapMsgWithTopic(topic, fmt.Sprintf("containerd open files limit is %d", openFiles)))
+	return nil
+}
+
+// checkCgroupVersion checks if the host runs in the unified cgroup v2 hierarchy. The hybrid
+// cgroup v1/v2 setup mounts cgroup v2 at /sys/fs/cgroup/unified alongside the v1 controllers.
+func (local *Checker) checkCgroupVersion(topic string) error {
+	fsType, err := getFilesystemType(local.packageManager, "/sys/fs/cgroup")
+	if err != nil {
+		return err
+	}
+
+	if fsType == "cgroup2fs" {
+		local.collection.Log.Info = append(local.collection.Log.Info, wrapMsgWithTopic(topic, "cgroup v2 is used"))
+		return nil
+	}
+
+	unifiedFsType, err := getFilesystemType(local.packageManager, "/sys/fs/cgroup/unified")
+	if err == nil && unifiedFsType == "cgroup2fs" {
+		local.collection.Log.Warn = append(local.collection.Log.Warn,
+			wrapMsgWithTopic(topic, "hybrid cgroup v1/v2 hierarchy is used. Please switch to the unified cgroup v2 hierarchy (systemd.unified_cgroup_hierarchy=1)"))
+		return nil
+	}
+
+	local.collection.Log.Warn = append(local.collection.Log.Warn,
+		wrapMsgWithTopic(topic, "cgroup v1 is used, which is deprecated by Kubernetes. Please consider switching to cgroup v2"))
+	return nil
+}
+
+// configureSysctl persists the recommended values of the kernel parameters to the sysctl configuration
+// file on the host, and applies them. The values already higher than recommended are persisted as they
+// are, and the values persisted by a previous run for the kernel parameters not recommended anymore, such
+// as the SPDK ones, are kept.
+func (local *Installer) configureSysctl() error {
+	if !local.ConfigureSysctl {
+		return nil
+	}
+
+	logrus.Info("Configuring kernel parameters")
+
+	configFile := filepath.Join(consts.VolumeMountHostDirectory, consts.SysctlConfigFile)
+	existing, err := os.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to read %v", consts.SysctlConfigFile)
+	}
+
+	sysctls := getRecommendedSysctls(local.EnableSpdk)
+	var lines []string
+	for _, name := range sortedKeys(getRecommendedSysctls(true)) {
+		recommended, ok := sysctls[name]
+		if !ok {
+			value, found, err := parseSysctlConfig(string(existing), name)
+			if err != nil {
+				return errors.Wrapf(err, "failed to parse %v", consts.SysctlConfigFile)
+			}
+			if found {
+				lines = append(lines, fmt.Sprintf("%s = %d", name, value))
+			}
+			continue
+		}
+
+		value, err := getSysctl(local.packageManager, name)
+		if err != nil {
+			return err
+		}
+
+		if value >= recommended {
+			logrus.Infof("Kernel parameter %s is %d, no change is required", name, value)
+		}
+		lines = append(lines, fmt.Sprintf("%s = %d", name, max(value, recommended)))
+	}
+
+	if local.DryRun {
+		local.logInfo("Would persist kernel parameters to %v: %v", consts.SysctlConfigFile, strings.Join(lines, ", "))
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %v", consts.SysctlConfigFile)
+	}
+
+	content := "# Generated by longhornctl\n" + strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %v", consts.SysctlConfigFile)
+	}
+	local.state.Files = appendUnique(local.state.Files, consts.SysctlConfigFile)
+	local.saveState()
+
+	if _, err := local.packageManager.Execute([]string{}, "sysctl", []string{"-p", consts.SysctlConfigFile}, commontypes.ExecuteNoTimeout); err != nil {
+		return errors.Wrapf(err, "failed to apply %v", consts.SysctlConfigFile)
+	}
+
+	local.logInfo("Successfully persisted kernel parameters to %v: %v", consts.SysctlConfigFile, strings.Join(lines, ", "))
+	return nil
+}
+
+// getRecommendedSysctls returns the minimum recommended values of the kernel parameters, including the
+// SPDK ones when SPDK is enabled.
+func getRecommendedSysctls(enableSpdk bool) map[string]uint64 {
+	sysctls := map[string]uint64{}
+	for name, value := range recommendedSysctls {
+		sysctls[name] = value
+	}
+	if enableSpdk {
+		for name, value := range recommendedSpdkSysctls {
+			sysctls[name] = value
+		}
+	}
+	return sysctls
+}
+
+// getSysctl reads the kernel parameter from /proc/sys of the host.
+func getSysctl(packageManager pkgmgr.PackageManager, name string) (uint64, error) {
+	path := filepath.Join("/proc/sys", strings.ReplaceAll(name, ".", "/"))
+	output, err := packageManager.Execute([]string{}, "cat", []string{path}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read %v", path)
+	}
+
+	value, err := strconv.ParseUint(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse %v value %q", name, output)
+	}
+	return value, nil
+}
+
+// getFilesystemType returns the filesystem type of the path on the host.
+func getFilesystemType(packageManager pkgmgr.PackageManager, path string) (string, error) {
+	output, err := packageManager.Execute([]string{}, "stat", []string{"-f", "-c", "%T", path}, commontypes.ExecuteNoTimeout)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get filesystem type of %v", path)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// parseMaxOpenFiles returns the soft limit of "Max open files" from the content of /proc/<pid>/limits.
+//
+// Example content:
+//
+//	Limit                     Soft Limit           Hard Limit           Units
+//	Max open files            1048576              1048576              files
+func parseMaxOpenFiles(limits string) (uint64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(limits))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 {
+			break
+		}
+		if fields[0] == "unlimited" {
+			return ^uint64(0), nil
+		}
+		return strconv.ParseUint(fields[0], 10, 64)
+	}
+	return 0, errors.New("failed to find Max open files limit")
+}
@@ -0,0 +1,188 @@
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	commonns "github.com/longhorn/go-common-libs/ns"
+	commontypes "github.com/longhorn/go-common-libs/types"
+
+	"github.com/longhorn/cli/pkg/consts"
+	"github.com/longhorn/cli/pkg/types"
+	"github.com/longhorn/cli/pkg/utils"
+
+	pkgmgr "github.com/longhorn/cli/pkg/local/preflight/packagemanager"
+	remote "github.com/longhorn/cli/pkg/remote/preflight"
+)
+
+// Uninstaller provide functions for the preflight uninstaller.
+type Uninstaller struct {
+	remote.UninstallerCmdOptions
+
+	logger *logrus.Entry
+
+	OutputFilePath string
+
+	osRelease      string
+	packageManager pkgmgr.PackageManager
+
+	state *installerState
+
+	collection types.NodeCollection
+}
+
+// Init initializes the Uninstaller.
+func (local *Uninstaller) Init() error {
+	local.collection.Log = &types.LogCollection{}
+
+	osRelease, err := utils.GetOSRelease()
+	if err != nil {
+		return errors.Wrap(err, "failed to get OS release")
+	}
+	local.osRelease = osRelease
+	local.logger = logrus.WithField("os", local.osRelease)
+
+	if !hasPackageManager(local.osRelease) {
+		return nil
+	}
+
+	packageManagerType, err := utils.GetPackageManagerType(osRelease)
+	if err != nil {
+		return errors.Wrap(err, "failed to get package manager")
+	}
+	local.logger = local.logger.WithField("package-manager", packageManagerType)
+
+	namespaces := []commontypes.Namespace{
+		commontypes.NamespaceMnt,
+		commontypes.NamespaceNet,
+	}
+
+	executor, err := commonns.NewNamespaceExecutor(commontypes.ProcessSelf, commontypes.HostProcDirectory, namespaces)
+	if err != nil {
+		return err
+	}
+
+	local.packageManager, err = pkgmgr.New(packageManagerType, executor)
+	if err != nil {
+		return err
+	}
+
+	local.state, err = loadInstallerState()
+	return err
+}
+
+// Run reverts the changes recorded by the preflight installer in the reverse order
+// they were made: services, kernel modules, packages, kernel parameters and then files. The changes
+// failed to be reverted are kept in the record, so the uninstaller can be run again.
+func (local *Uninstaller) Run() error {
+	if !hasPackageManager(local.osRelease) {
+		// The preflight installer does not change the OS without package manager, nothing is recorded.
+		local.logInfo("No preflight installer change to revert on %v", local.osRelease)
+		return nil
+	}
+
+	if local.state.isEmpty() {
+		local.logInfo("No preflight installer change is recorded in %v", consts.PreflightInstallerStateFile)
+		return nil
+	}
+
+	local.state.Services = local.revert(local.state.Services, "disable service", "disabled service", func(name string) error {
+		_, err := local.packageManager.DisableService(name)
+		return err
+	})
+
+	modules := slices.Clone(local.state.Modules)
+	slices.Reverse(modules)
+	local.state.Modules = local.revert(modules, "unload module", "unloaded module", func(name string) error {
+		_, err := local.packageManager.Modprobe(name, "-r")
+		return err
+	})
+
+	if len(local.state.Packages) > 0 {
+		if _, err := local.packageManager.StartPackageSession(); err != nil {
+			return errors.Wrap(err, "failed to start package session")
+		}
+	}
+
+	rebootRequired := false
+	local.state.Packages = local.revert(local.state.Packages, "uninstall package", "uninstalled package", func(name string) error {
+		if _, err := local.packageManager.UninstallPackage(name); err != nil {
+			return err
+		}
+		if local.packageManager.NeedReboot() {
+			rebootRequired = true
+		}
+		return nil
+	})
+
+	local.state.KernelArgs = local.revert(local.state.KernelArgs, "remove kernel parameters", "removed kernel parameters", func(args string) error {
+		_, err := local.packageManager.Execute([]string{}, "grubby", []string{"--update-kernel=ALL", "--remove-args=" + args}, commontypes.ExecuteNoTimeout)
+		return err
+	})
+
+	local.state.Files = local.revert(local.state.Files, "remove file", "removed file", func(name string) error {
+		err := os.Remove(filepath.Join(consts.VolumeMountHostDirectory, name))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	})
+
+	// The PCI devices stay bound to the userspace driver until reboot, but their selectors are not used anymore.
+	local.state.PciSelectors = nil
+
+	if err := local.state.save(); err != nil {
+		return err
+	}
+
+	if rebootRequired {
+		logrus.Warn("Need to reboot the system to complete the package uninstallation")
+		local.collection.Log.Warn = append(local.collection.Log.Warn, "Need to reboot the system to complete the package uninstallation")
+	}
+
+	return nil
+}
+
+// Output converts the collection to JSON and output to stdout or the output file.
+func (local *Uninstaller) Output() error {
+	local.logger.Trace("Outputting preflight uninstaller results")
+
+	jsonBytes, err := json.Marshal(local.collection)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert collection to JSON")
+	}
+
+	return utils.HandleResult(jsonBytes, local.OutputFilePath, local.logger)
+}
+
+// revert reverts each recorded change with the revertFn, and returns the changes
+// that failed to be reverted.
+func (local *Uninstaller) revert(names []string, action, done string, revertFn func(name string) error) []string {
+	var remaining []string
+	for _, name := range names {
+		logrus.Infof("Trying to %s %s", action, name)
+
+		if err := revertFn(name); err != nil {
+			msg := fmt.Sprintf("Failed to %s %s: %v", action, name, err)
+			logrus.Warn(msg)
+			local.collection.Log.Warn = append(local.collection.Log.Warn, msg)
+			remaining = append(remaining, name)
+			continue
+		}
+
+		local.logInfo("Successfully %s %s", done, name)
+	}
+	return remaining
+}
+
+func (local *Uninstaller) logInfo(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	logrus.Info(msg)
+	local.collection.Log.Info = append(local.collection.Log.Info, msg)
+}
@@ -0,0 +1,236 @@
+package preflight
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+
+	commonkube "github.com/longhorn/go-common-libs/kubernetes"
+	commonutils "github.com/longhorn/go-common-libs/utils"
+
+	"github.com/longhorn/cli/pkg/consts"
+	"github.com/longhorn/cli/pkg/types"
+	"github.com/longhorn/cli/pkg/utils"
+
+	kubeutils "github.com/longhorn/cli/pkg/utils/kubernetes"
+)
+
+// BundleExporter provide functions for the preflight bundle export.
+type BundleExporter struct {
+	BundleExporterCmdOptions
+
+	kubeClient *kubeclient.Clientset
+
+	appName string // App name of the DaemonSet.
+}
+
+// BundleExporterCmdOptions holds the options for the command.
+type BundleExporterCmdOptions struct {
+	types.GlobalCmdOptions
+
+	HostTargetDirectory string
+	EnableSpdk          bool
+}
+
+// Init initializes the BundleExporter.
+func (remote *BundleExporter) Init() error {
+	kubeClient, err := kubeutils.NewKubeClient("", remote.KubeConfigPath)
+	if err != nil {
+		return err
+	}
+	remote.kubeClient = kubeClient
+
+	if remote.HostTargetDirectory == "" {
+		return errors.Errorf("%q argument is required", consts.CmdOptTargetDirectory)
+	}
+
+	remote.appName = consts.AppNamePreflightBundleExporter
+	return nil
+}
+
+// Run creates the DaemonSet downloading the packages required by the preflight installer,
+// and their dependencies, to the target directory on each node. Then it waits for the
+// DaemonSet to complete and returns the logs of the output container within the DaemonSet,
+// for example:
+// ip-192-168-208-117:
+//
+//	info:
+//	- Successfully downloaded packages nfs-client, open-iscsi, cryptsetup to /tmp/bundle
+func (remote *BundleExporter) Run() (string, error) {
+	if err := kubeutils.CheckNamespacePodSecurity(remote.kubeClient, remote.Namespace, remote.LabelNamespacePrivileged); err != nil {
+		return "", err
+	}
+
+	newDaemonSet, err := kubeutils.PrepareDaemonSet(remote.newDaemonSet(), remote.kubeClient, remote.NodeSelector, remote.ImagePullSecret, remote.Tolerations)
+	if err != nil {
+		return "", err
+	}
+	daemonSet, err := commonkube.CreateDaemonSet(remote.kubeClient, newDaemonSet)
+	if err != nil {
+		return "", err
+	}
+
+	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameInit, kubeutils.WaitForDaemonSetContainersExit, remote.GetTimeout(consts.TimeoutPhaseRun, consts.ContainerConditionTimeoutLong))
+	if err != nil {
+		return "", err
+	}
+
+	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameOutput, kubeutils.WaitForDaemonSetContainersExit, remote.GetTimeout(consts.TimeoutPhaseOutput, consts.ContainerConditionTimeoutShort))
+	if err != nil {
+		return "", err
+	}
+
+	podCollections, err := kubeutils.GetDaemonSetPodCollections(remote.kubeClient, daemonSet, consts.ContainerNameOutput, false, false, nil)
+	if err != nil {
+		return "", err
+	}
+
+	nodeCollections := map[string]*types.LogCollection{}
+	for _, collection := range podCollections.Pods {
+		var resultMap types.NodeCollection
+		if err := json.Unmarshal([]byte(collection.Log), &resultMap); err != nil {
+			return "", err
+		}
+
+		if reflect.DeepEqual(resultMap, types.NodeCollection{}) {
+			continue
+		}
+
+		nodeCollections[collection.Node] = resultMap.Log
+	}
+
+	if len(nodeCollections) == 0 {
+		return "", nil
+	}
+
+	yamlData, err := yaml.Marshal(nodeCollections)
+	if err != nil {
+		return "", err
+	}
+
+	return string(yamlData), nil
+}
+
+// Cleanup deletes the DaemonSet created for the preflight bundle export.
+func (remote *BundleExporter) Cleanup() error {
+	return errors.Wrap(commonkube.DeleteDaemonSet(remote.kubeClient, remote.Namespace, remote.appName), "failed to delete DaemonSet")
+}
+
+// newDaemonSet prepares a DaemonSet for downloading the packages required by the preflight installer.
+func (remote *BundleExporter) newDaemonSet() *appsv1.DaemonSet {
+	outputFilePath := filepath.Join(consts.VolumeMountSharedDirectory, consts.FileNameOutputJSON)
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      remote.appName,
+			Namespace: remote.Namespace,
+			Labels: map[string]string{
+				"app": remote.appName,
+			},
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": remote.appName,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": remote.appName,
+					},
+				},
+				Spec: corev1.PodSpec{
+					// Required for running systemd tasks.
+					HostNetwork: true,
+					HostPID:     true,
+
+					InitContainers: []corev1.Container{
+						{
+							Name:    consts.ContainerNameInit,
+							Image:   utils.BuildImageName(remote.Image, remote.ImageRegistry),
+							Command: []string{consts.CmdLonghornctlLocal, consts.SubCmdExport, consts.SubCmdPreflight},
+							Env: []corev1.EnvVar{
+								{
+									Name:  consts.EnvLogLevel,
+									Value: remote.LogLevel,
+								},
+								{
+									Name:  consts.EnvOutputFilePath,
+									Value: outputFilePath,
+								},
+								{
+									Name:  consts.EnvTargetDirectory,
+									Value: remote.HostTargetDirectory,
+								},
+								{
+									Name:  consts.EnvEnableSpdk,
+									Value: commonutils.ConvertTypeToString(remote.EnableSpdk),
+								},
+							},
+							SecurityContext: &corev1.SecurityContext{
+								Privileged: ptr.To(true),
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      consts.VolumeMountHostName,
+									MountPath: consts.VolumeMountHostDirectory,
+								},
+								{
+									Name:      consts.VolumeMountSharedName,
+									MountPath: consts.VolumeMountSharedDirectory,
+								},
+							},
+						},
+						{
+							Name:    consts.ContainerNameOutput,
+							Image:   utils.BuildImageName(remote.Image, remote.ImageRegistry),
+							Command: []string{"cat", outputFilePath},
+							Env:     []corev1.EnvVar{},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      consts.VolumeMountSharedName,
+									MountPath: consts.VolumeMountSharedDirectory,
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  consts.ContainerNamePause,
+							Image: utils.BuildImageName(consts.ImagePause, remote.ImageRegistry),
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: consts.VolumeMountHostName,
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{
+									Path: "/",
+								},
+							},
+						},
+						{
+							Name: consts.VolumeMountSharedName,
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
+					},
+				},
+			},
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+			},
+		},
+	}
+}
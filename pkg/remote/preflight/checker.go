@@ -41,7 +41,11 @@ type CheckerCmdOptions struct {
 
 	EnableSpdk      bool
 	HugePageSize    int
+	AllowPci        string
 	UserspaceDriver string
+
+	DependencyConfig string
+	Follow           bool
 }
 
 // Init initializes the Checker.
@@ -59,10 +63,16 @@ func (remote *Checker) Init() error {
 
 // Run creates the DaemonSet for the preflight check, and waits for it to complete.
 func (remote *Checker) Run() (string, error) {
+	if err := kubeutils.CheckNamespacePodSecurity(remote.kubeClient, remote.Namespace, remote.LabelNamespacePrivileged); err != nil {
+		return "", err
+	}
+
 	// Create RBAC to check:
 	// - the node agent existence when the cluster is running on Container-Optimized OS (COS)
 	// - replica count of the DNS deployment
+	// - existence of the Longhorn services resolved by the DNS resolution test
 	// - hugepages-2Mi capacity on nodes
+	// - allocatable CPU and memory on nodes, the pod requests and the Longhorn settings reserving instance manager CPU
 	rbacRules := []rbacv1.PolicyRule{
 		{
 			APIGroups: []string{"apps"},
@@ -74,12 +84,33 @@ func (remote *Checker) Run() (string, error) {
 			Resources: []string{"nodes", "nodes/status"},
 			Verbs:     []string{"get"},
 		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+			Verbs:     []string{"list"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"services"},
+			Verbs:     []string{"get"},
+		},
+		{
+			APIGroups: []string{"longhorn.io"},
+			Resources: []string{"settings"},
+			Verbs:     []string{"get"},
+		},
 	}
 	err := kubeutils.CreateRbac(remote.kubeClient, remote.Namespace, remote.appName, rbacRules)
 	if err != nil {
 		return "", err
 	}
 
+	if remote.DependencyConfig != "" {
+		if err := createDependencyConfigMap(remote.kubeClient, remote.Namespace, remote.appName, remote.DependencyConfig); err != nil {
+			return "", err
+		}
+	}
+
 	newDaemonSet, err := kubeutils.PrepareDaemonSet(remote.newDaemonSet(), remote.kubeClient, remote.NodeSelector, remote.ImagePullSecret, remote.Tolerations)
 	if err != nil {
 		return "", err
@@ -89,12 +120,12 @@ func (remote *Checker) Run() (string, error) {
 		return "", err
 	}
 
-	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameInit, kubeutils.WaitForDaemonSetContainersExit, ptr.To(consts.ContainerConditionMaxTolerationMedium))
+	err = monitorInitContainer(remote.kubeClient, daemonSet, remote.GetTimeout(consts.TimeoutPhaseRun, consts.ContainerConditionTimeoutMedium), remote.Follow)
 	if err != nil {
 		return "", err
 	}
 
-	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameOutput, kubeutils.WaitForDaemonSetContainersExit, ptr.To(consts.ContainerConditionMaxTolerationShort))
+	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameOutput, kubeutils.WaitForDaemonSetContainersExit, remote.GetTimeout(consts.TimeoutPhaseOutput, consts.ContainerConditionTimeoutShort))
 	if err != nil {
 		return "", err
 	}
@@ -146,13 +177,21 @@ func (remote *Checker) Cleanup() error {
 		}
 	}
 
+	if err := commonkube.DeleteConfigMap(remote.kubeClient, remote.Namespace, dependencyConfigMapName(remote.appName)); err != nil {
+		if resultErr != nil {
+			resultErr = errors.Wrap(resultErr, err.Error())
+		} else {
+			resultErr = errors.Wrap(err, "failed to delete dependency config ConfigMap")
+		}
+	}
+
 	return resultErr
 }
 
 // newDaemonSet prepares a DaemonSet for the preflight check.
 func (remote *Checker) newDaemonSet() *appsv1.DaemonSet {
 	outputFilePath := filepath.Join(consts.VolumeMountSharedDirectory, consts.FileNameOutputJSON)
-	return &appsv1.DaemonSet{
+	daemonSet := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      remote.appName,
 			Namespace: remote.Namespace,
@@ -197,10 +236,18 @@ func (remote *Checker) newDaemonSet() *appsv1.DaemonSet {
 									Name:  consts.EnvHugePageSize,
 									Value: commonutils.ConvertTypeToString(remote.HugePageSize),
 								},
+								{
+									Name:  consts.EnvPciAllowed,
+									Value: remote.AllowPci,
+								},
 								{
 									Name:  consts.EnvUserspaceDriver,
 									Value: remote.UserspaceDriver,
 								},
+								{
+									Name:  consts.EnvLonghornNamespace,
+									Value: remote.Namespace,
+								},
 								{
 									Name: consts.EnvCurrentNodeID,
 									ValueFrom: &corev1.EnvVarSource{
@@ -267,4 +314,10 @@ func (remote *Checker) newDaemonSet() *appsv1.DaemonSet {
 			},
 		},
 	}
+
+	if remote.DependencyConfig != "" {
+		setDependencyConfig(daemonSet, remote.appName)
+	}
+
+	return daemonSet
 }
@@ -0,0 +1,77 @@
+package preflight
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+
+	commonkube "github.com/longhorn/go-common-libs/kubernetes"
+
+	"github.com/longhorn/cli/pkg/consts"
+)
+
+// dependencyConfigMapName returns the name of the ConfigMap holding the dependency config of the app.
+func dependencyConfigMapName(appName string) string {
+	return appName + "-" + consts.VolumeMountDependencyConfigName
+}
+
+// createDependencyConfigMap creates the ConfigMap holding the content of the dependency config file,
+// overriding the dependencies embedded in longhornctl-local.
+func createDependencyConfigMap(kubeClient *kubeclient.Clientset, namespace, appName, configFilePath string) error {
+	content, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read dependency config %v", configFilePath)
+	}
+
+	_, err = commonkube.CreateConfigMap(kubeClient, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dependencyConfigMapName(appName),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app": appName,
+			},
+		},
+		Data: map[string]string{
+			consts.FileNameDependencyConfig: string(content),
+		},
+	})
+	return err
+}
+
+// setDependencyConfig mounts the dependency config ConfigMap into the init container of the DaemonSet.
+func setDependencyConfig(daemonSet *appsv1.DaemonSet, appName string) {
+	podSpec := &daemonSet.Spec.Template.Spec
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: consts.VolumeMountDependencyConfigName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: dependencyConfigMapName(appName),
+				},
+			},
+		},
+	})
+
+	for i := range podSpec.InitContainers {
+		container := &podSpec.InitContainers[i]
+		if container.Name != consts.ContainerNameInit {
+			continue
+		}
+
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  consts.EnvDependencyConfig,
+			Value: filepath.Join(consts.VolumeMountDependencyConfigDirectory, consts.FileNameDependencyConfig),
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      consts.VolumeMountDependencyConfigName,
+			MountPath: consts.VolumeMountDependencyConfigDirectory,
+			ReadOnly:  true,
+		})
+	}
+}
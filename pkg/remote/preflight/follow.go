@@ -0,0 +1,28 @@
+package preflight
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+
+	"github.com/longhorn/cli/pkg/consts"
+
+	kubeutils "github.com/longhorn/cli/pkg/utils/kubernetes"
+)
+
+// monitorInitContainer waits for the init container of the DaemonSet pods to exit. When follow is set, the
+// init container logs of each node and the progress summary are streamed to the CLI output meanwhile.
+func monitorInitContainer(kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, timeout time.Duration, follow bool) error {
+	if follow {
+		stop, err := kubeutils.FollowDaemonSetContainer(kubeClient, daemonSet, consts.ContainerNameInit, logrus.StandardLogger().Out)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	return kubeutils.MonitorDaemonSetContainer(kubeClient, daemonSet, consts.ContainerNameInit, kubeutils.WaitForDaemonSetContainersExit, timeout)
+}
@@ -0,0 +1,126 @@
+package preflight
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+
+	commonkube "github.com/longhorn/go-common-libs/kubernetes"
+
+	"github.com/longhorn/cli/pkg/consts"
+)
+
+// hookConfigMapName returns the name of the ConfigMap holding the hook scripts of the app.
+func hookConfigMapName(appName string) string {
+	return appName + "-" + consts.VolumeMountHookName
+}
+
+// readHook returns the script of the hook option, read from the local file, or from the key of the
+// ConfigMap in the namespace when the option is configmap:<name>/<key>.
+func readHook(kubeClient *kubeclient.Clientset, namespace, hook string) (string, error) {
+	reference, isConfigMap := strings.CutPrefix(hook, consts.HookConfigMapReferencePrefix)
+	if !isConfigMap {
+		content, err := os.ReadFile(hook)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read hook script %v", hook)
+		}
+		return string(content), nil
+	}
+
+	name, key, found := strings.Cut(reference, "/")
+	if !found || name == "" || key == "" {
+		return "", errors.Errorf("invalid hook ConfigMap reference %q (expected format %s<name>/<key>)", hook, consts.HookConfigMapReferencePrefix)
+	}
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get hook ConfigMap %v", name)
+	}
+
+	content, ok := configMap.Data[key]
+	if !ok {
+		return "", errors.Errorf("key %v is not found in hook ConfigMap %v", key, name)
+	}
+	return content, nil
+}
+
+// createHookConfigMap creates the ConfigMap holding the pre-install and post-install hook scripts, run by
+// longhornctl-local on the host before and after installing the dependencies.
+func createHookConfigMap(kubeClient *kubeclient.Clientset, namespace, appName, preHook, postHook string) error {
+	data := map[string]string{}
+	for fileName, hook := range map[string]string{
+		consts.FileNamePreHook:  preHook,
+		consts.FileNamePostHook: postHook,
+	} {
+		if hook == "" {
+			continue
+		}
+
+		script, err := readHook(kubeClient, namespace, hook)
+		if err != nil {
+			return err
+		}
+		data[fileName] = script
+	}
+
+	_, err := commonkube.CreateConfigMap(kubeClient, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      hookConfigMapName(appName),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app": appName,
+			},
+		},
+		Data: data,
+	})
+	return err
+}
+
+// setHooks mounts the hook ConfigMap into the init container of the DaemonSet, and sets the paths of the
+// specified hooks.
+func setHooks(daemonSet *appsv1.DaemonSet, appName string, preHook, postHook bool) {
+	podSpec := &daemonSet.Spec.Template.Spec
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: consts.VolumeMountHookName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: hookConfigMapName(appName),
+				},
+			},
+		},
+	})
+
+	for i := range podSpec.InitContainers {
+		container := &podSpec.InitContainers[i]
+		if container.Name != consts.ContainerNameInit {
+			continue
+		}
+
+		if preHook {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:  consts.EnvPreHook,
+				Value: filepath.Join(consts.VolumeMountHookDirectory, consts.FileNamePreHook),
+			})
+		}
+		if postHook {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:  consts.EnvPostHook,
+				Value: filepath.Join(consts.VolumeMountHookDirectory, consts.FileNamePostHook),
+			})
+		}
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      consts.VolumeMountHookName,
+			MountPath: consts.VolumeMountHookDirectory,
+			ReadOnly:  true,
+		})
+	}
+}
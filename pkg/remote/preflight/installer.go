@@ -2,8 +2,11 @@ package preflight
 
 import (
 	"encoding/json"
+	"fmt"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,6 +18,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubeclient "k8s.io/client-go/kubernetes"
 
@@ -42,15 +46,33 @@ type InstallerCmdOptions struct {
 	types.GlobalCmdOptions
 
 	OperatingSystem string
+	DryRun          bool
 
 	UpdatePackages       bool
 	EnableSpdk           bool
 	SpdkOptions          string
 	HugePageSize         int
+	HugePageNuma         bool
 	AllowPci             string
 	DriverOverride       string
 	RestartKubelet       bool
 	RestartKubeletWindow string
+
+	ConfigureSysctl    bool
+	ConfigureMultipath bool
+
+	MaxUnavailable int
+	Drain          bool
+	Reboot         bool
+
+	BundleDir   string
+	BundleImage string
+
+	DependencyConfig string
+	Follow           bool
+
+	PreHook  string
+	PostHook string
 }
 
 // Init initializes the Installer.
@@ -79,17 +101,63 @@ func (remote *Installer) Run() (string, error) {
 	operatingSystem := consts.OperatingSystem(remote.OperatingSystem)
 	switch operatingSystem {
 	case consts.OperatingSystemContainerOptimizedOS:
+		if remote.DryRun {
+			return "", errors.Errorf("%q argument is not supported on Container Optimized OS (%v)", consts.CmdOptDryRun, operatingSystem)
+		}
+		if remote.MaxUnavailable > 0 {
+			return "", errors.Errorf("%q argument is not supported on Container Optimized OS (%v)", consts.CmdOptMaxUnavailable, operatingSystem)
+		}
+		if remote.Reboot {
+			return "", errors.Errorf("%q argument is not supported on Container Optimized OS (%v)", consts.CmdOptReboot, operatingSystem)
+		}
+		if remote.DependencyConfig != "" {
+			return "", errors.Errorf("%q argument is not supported on Container Optimized OS (%v)", consts.CmdOptDependencyConfig, operatingSystem)
+		}
+		if remote.Follow {
+			return "", errors.Errorf("%q argument is not supported on Container Optimized OS (%v)", consts.CmdOptFollow, operatingSystem)
+		}
+		if remote.PreHook != "" || remote.PostHook != "" {
+			return "", errors.Errorf("%q and %q arguments are not supported on Container Optimized OS (%v)", consts.CmdOptPreHook, consts.CmdOptPostHook, operatingSystem)
+		}
+
+		if err := kubeutils.CheckNamespacePodSecurity(remote.kubeClient, remote.Namespace, remote.LabelNamespacePrivileged); err != nil {
+			return "", err
+		}
+
 		logrus.Infof("Installing dependencies on Container Optimized OS (%v)", operatingSystem)
 
-		if err := remote.InstallByContainerOptimizedOS(); err != nil {
+		output, err := remote.InstallByContainerOptimizedOS()
+		if err != nil {
 			return "", errors.Wrapf(err, "failed to install dependencies on Container Optimized OS (%v)", operatingSystem)
 		}
 
 		logrus.Infof("Installed dependencies on Container Optimized OS (%v)", operatingSystem)
-		return "", nil
+		return output, nil
 
 	default:
+		if remote.BundleDir != "" && remote.BundleImage != "" {
+			return "", errors.Errorf("%q and %q arguments are mutually exclusive", consts.CmdOptBundleDir, consts.CmdOptBundleImage)
+		}
+		if filepath.Clean(remote.BundleDir) == consts.PreflightBundleHostDirectory {
+			return "", errors.Errorf("%q argument cannot be %v, which is reserved for the bundle copied from %q argument", consts.CmdOptBundleDir, consts.PreflightBundleHostDirectory, consts.CmdOptBundleImage)
+		}
+		if remote.Drain && remote.MaxUnavailable <= 0 {
+			return "", errors.Errorf("%q argument requires %q argument", consts.CmdOptDrain, consts.CmdOptMaxUnavailable)
+		}
+		if remote.RestartKubelet {
+			if _, err := time.ParseDuration(remote.RestartKubeletWindow); err != nil {
+				return "", errors.Wrapf(err, "failed to parse %q argument", consts.CmdOptRestartKubeletWindow)
+			}
+		}
+
+		if err := kubeutils.CheckNamespacePodSecurity(remote.kubeClient, remote.Namespace, remote.LabelNamespacePrivileged); err != nil {
+			return "", err
+		}
+
 		logrus.Info("Installing dependencies with package manager")
+		if remote.RestartKubelet {
+			logrus.Infof("Kubelet services will be restarted within %s (if needed)", remote.RestartKubeletWindow)
+		}
 
 		// Create RBAC to check hugepages-2Mi capacity on nodes
 		rbacRules := []rbacv1.PolicyRule{
@@ -103,11 +171,15 @@ func (remote *Installer) Run() (string, error) {
 		if err != nil {
 			return "", err
 		}
-		if remote.RestartKubelet {
-			if _, err := time.ParseDuration(remote.RestartKubeletWindow); err != nil {
-				return "", errors.Wrapf(err, "failed to parse %q argument", consts.CmdOptRestartKubeletWindow)
+		if remote.DependencyConfig != "" {
+			if err := createDependencyConfigMap(remote.kubeClient, remote.Namespace, remote.appName, remote.DependencyConfig); err != nil {
+				return "", err
+			}
+		}
+		if remote.PreHook != "" || remote.PostHook != "" {
+			if err := createHookConfigMap(remote.kubeClient, remote.Namespace, remote.appName, remote.PreHook, remote.PostHook); err != nil {
+				return "", err
 			}
-			logrus.Infof("Kubelet services will be restarted within %s (if needed)", remote.RestartKubeletWindow)
 		}
 		output, err := remote.InstallByPackageManager()
 		if err != nil {
@@ -119,11 +191,16 @@ func (remote *Installer) Run() (string, error) {
 	}
 }
 
-// Cleanup deletes the DaemonSet created for the preflight install when it's installed with package manager.
+// Cleanup deletes the DaemonSet created for the preflight install. On Container Optimized OS, it waits for
+// the node agent pods running iscsid to be deleted, and deletes the ConfigMap of the node agent entrypoint.
 func (remote *Installer) Cleanup() error {
 	var resultErr error
 
-	if err := commonkube.DeleteDaemonSet(remote.kubeClient, remote.Namespace, remote.appName); err != nil {
+	if consts.OperatingSystem(remote.OperatingSystem) == consts.OperatingSystemContainerOptimizedOS {
+		if err := remote.cleanupContainerOptimizedOS(); err != nil {
+			resultErr = err
+		}
+	} else if err := commonkube.DeleteDaemonSet(remote.kubeClient, remote.Namespace, remote.appName); err != nil {
 		resultErr = errors.Wrap(err, "failed to delete DaemonSet")
 	}
 
@@ -135,28 +212,114 @@ func (remote *Installer) Cleanup() error {
 		}
 	}
 
+	if err := commonkube.DeleteConfigMap(remote.kubeClient, remote.Namespace, dependencyConfigMapName(remote.appName)); err != nil {
+		if resultErr != nil {
+			resultErr = errors.Wrap(resultErr, err.Error())
+		} else {
+			resultErr = errors.Wrap(err, "failed to delete dependency config ConfigMap")
+		}
+	}
+
+	if err := commonkube.DeleteConfigMap(remote.kubeClient, remote.Namespace, hookConfigMapName(remote.appName)); err != nil {
+		if resultErr != nil {
+			resultErr = errors.Wrap(resultErr, err.Error())
+		} else {
+			resultErr = errors.Wrap(err, "failed to delete hook ConfigMap")
+		}
+	}
+
 	return resultErr
 }
 
 // InstallByContainerOptimizedOS installs the dependencies on Container Optimized OS.
-// It creates a ConfigMap and a DaemonSet. Then it waits for the DaemonSet to be ready.
-func (remote *Installer) InstallByContainerOptimizedOS() error {
+// It creates a ConfigMap and a DaemonSet running the node agent. The node agent writes the result of the
+// installation on each node, printed by the output container once written. Then it waits for the node
+// agent to be ready on the nodes without error, and returns the result in the same format as
+// InstallByPackageManager, for example:
+// gke-cluster-default-pool-0:
+//
+//	info:
+//	- Longhorn data directory /var/lib/longhorn is mounted
+//	- Successfully installed package open-iscsi
+//	- Successfully started service iscsid
+//	- Successfully probed module iscsi_tcp
+func (remote *Installer) InstallByContainerOptimizedOS() (string, error) {
 	newConfigMap := remote.newConfigMapForContainerOptimizedOS()
 	_, err := commonkube.CreateConfigMap(remote.kubeClient, newConfigMap)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	newDaemonSet, err := kubeutils.PrepareDaemonSet(remote.newDaemonSetForContainerOptimizedOS(), remote.kubeClient, remote.NodeSelector, remote.ImagePullSecret, remote.Tolerations)
 	if err != nil {
-		return err
+		return "", err
 	}
 	daemonSet, err := commonkube.CreateDaemonSet(remote.kubeClient, newDaemonSet)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameOutput, kubeutils.WaitForDaemonSetContainersReady, remote.GetTimeout(consts.TimeoutPhaseRun, consts.ContainerConditionTimeoutMedium))
+	if err != nil {
+		return "", err
+	}
+
+	podCollections, err := kubeutils.GetDaemonSetPodCollections(remote.kubeClient, daemonSet, consts.ContainerNameOutput, false, false, nil)
+	if err != nil {
+		return "", err
+	}
+
+	result := newInstallResult()
+	var failedNodes []string
+	for _, collection := range podCollections.Pods {
+		var resultMap types.NodeCollection
+		if err := json.Unmarshal([]byte(collection.Log), &resultMap); err != nil {
+			return "", errors.Wrapf(err, "failed to parse the result of node %v", collection.Node)
+		}
+
+		if resultMap.Log != nil && len(resultMap.Log.Error) > 0 {
+			failedNodes = append(failedNodes, collection.Node)
+		}
+		result.add(collection.Node, &resultMap)
+	}
+
+	yamlData, err := yaml.Marshal(result.nodeCollections)
+	if err != nil {
+		return "", err
+	}
+
+	// The node agent is restarted on the failed nodes, and never becomes ready.
+	if len(failedNodes) > 0 {
+		slices.Sort(failedNodes)
+		logrus.Warnf("Failed to install dependencies on nodes %v, skipped waiting for the node agent to be ready", strings.Join(failedNodes, ", "))
+		return string(yamlData), nil
+	}
+
+	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerName, kubeutils.WaitForDaemonSetContainersReady, remote.GetTimeout(consts.TimeoutPhaseReady, consts.ContainerConditionTimeoutShort))
+	if err != nil {
+		return "", err
 	}
 
-	return kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerName, kubeutils.WaitForDaemonSetContainersReady, ptr.To(consts.ContainerConditionMaxTolerationShort))
+	return string(yamlData), nil
+}
+
+// cleanupContainerOptimizedOS deletes the node agent DaemonSet and waits for its pods to be deleted, so
+// iscsid is stopped on the nodes, then deletes the ConfigMap of the node agent entrypoint.
+func (remote *Installer) cleanupContainerOptimizedOS() error {
+	daemonSet, err := commonkube.GetDaemonSet(remote.kubeClient, remote.Namespace, remote.appName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get DaemonSet")
+	}
+	if err == nil {
+		if err := kubeutils.DeleteDaemonSetAndWait(remote.kubeClient, daemonSet, remote.GetTimeout(consts.TimeoutPhaseReady, consts.ContainerConditionTimeoutShort)); err != nil {
+			return err
+		}
+	}
+
+	if err := commonkube.DeleteConfigMap(remote.kubeClient, remote.Namespace, remote.appName); err != nil {
+		return errors.Wrap(err, "failed to delete node agent ConfigMap")
+	}
+	return nil
 }
 
 // InstallByPackageManager installs the dependencies with package manager.
@@ -171,62 +334,231 @@ func (remote *Installer) InstallByContainerOptimizedOS() error {
 //	- Successfully probed module iscsi_tcp
 //	- Successfully probed module dm_crypt
 //	- Successfully started service iscsid
+//
+// When MaxUnavailable is set, the nodes are processed in batches of at most MaxUnavailable nodes.
+//
+// When Reboot is set, the nodes requiring a reboot are then rebooted one at a time, and the installer
+// is executed again on them.
 func (remote *Installer) InstallByPackageManager() (string, error) {
-	newDaemonSet, err := kubeutils.PrepareDaemonSet(remote.newDaemonSetForPackageManager(), remote.kubeClient, remote.NodeSelector, remote.ImagePullSecret, remote.Tolerations)
+	result := newInstallResult()
+
+	var err error
+	if remote.MaxUnavailable > 0 {
+		err = remote.installByPackageManagerInBatches(result)
+	} else {
+		_, err = remote.installByPackageManagerOnNodes(nil, result)
+	}
 	if err != nil {
 		return "", err
 	}
-	daemonSet, err := commonkube.CreateDaemonSet(remote.kubeClient, newDaemonSet)
+
+	if remote.Reboot && len(result.rebootRequiredNodes) > 0 {
+		if err := remote.rebootNodes(result); err != nil {
+			logCompletedNodes(result)
+			return "", err
+		}
+	}
+
+	if reflect.DeepEqual(result.nodeCollections, map[string]types.LogCollection{}) {
+		return "", nil
+	}
+
+	yamlData, err := yaml.Marshal(result.nodeCollections)
 	if err != nil {
 		return "", err
 	}
 
-	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameInit, kubeutils.WaitForDaemonSetContainersExit, ptr.To(consts.ContainerConditionMaxTolerationLong))
+	return string(yamlData), nil
+}
+
+// installByPackageManagerInBatches runs the preflight installer DaemonSet on MaxUnavailable nodes at a time.
+// Only the nodes the DaemonSet pods can run on are processed, the tainted nodes not tolerated are skipped.
+// With Drain set, each node of the batch is cordoned and drained first, and uncordoned once the batch succeeds
+// unless it was already cordoned before the rollout.
+// The rollout stops on the first failed batch, or the first batch with a node reporting errors, leaving its
+// nodes cordoned for investigation.
+func (remote *Installer) installByPackageManagerInBatches(result *installResult) error {
+	nodeNames, err := kubeutils.ListDaemonSetNodeNames(remote.kubeClient, remote.NodeSelector, remote.Tolerations)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameOutput, kubeutils.WaitForDaemonSetContainersExit, ptr.To(consts.ContainerConditionMaxTolerationShort))
+	batches := utils.SplitIntoBatches(nodeNames, remote.MaxUnavailable)
+	for i, batch := range batches {
+		log := logrus.WithField("batch", fmt.Sprintf("%d/%d", i+1, len(batches)))
+		log.Infof("Installing dependencies on nodes %v", strings.Join(batch, ", "))
+
+		drain := remote.Drain && !remote.DryRun
+		var cordonedNodes []string
+		if drain {
+			for _, nodeName := range batch {
+				unschedulable, err := kubeutils.IsNodeUnschedulable(remote.kubeClient, nodeName)
+				if err != nil {
+					return err
+				}
+
+				if unschedulable {
+					log.Infof("Draining node %v, already cordoned", nodeName)
+				} else {
+					log.Infof("Cordoning and draining node %v", nodeName)
+					if err := kubeutils.CordonNode(remote.kubeClient, nodeName, true); err != nil {
+						return err
+					}
+					cordonedNodes = append(cordonedNodes, nodeName)
+				}
+				if err := kubeutils.DrainNode(remote.kubeClient, nodeName, consts.NodeDrainTimeout); err != nil {
+					return errors.Wrapf(err, "failed to drain node %v, stopped the rollout", nodeName)
+				}
+			}
+		}
+
+		daemonSet, err := remote.installByPackageManagerOnNodes(batch, result)
+		if err != nil {
+			logCompletedNodes(result)
+			return errors.Wrapf(err, "failed to install dependencies on nodes %v, stopped the rollout", strings.Join(batch, ", "))
+		}
+
+		if err := kubeutils.DeleteDaemonSetAndWait(remote.kubeClient, daemonSet, remote.GetTimeout(consts.TimeoutPhaseReady, consts.ContainerConditionTimeoutShort)); err != nil {
+			return err
+		}
+
+		if failedNodes := result.nodesWithErrors(batch); len(failedNodes) > 0 {
+			logCompletedNodes(result)
+			return errors.Errorf("nodes %v reported errors, stopped the rollout", strings.Join(failedNodes, ", "))
+		}
+
+		if drain {
+			for _, nodeName := range cordonedNodes {
+				log.Infof("Uncordoning node %v", nodeName)
+				if err := kubeutils.CordonNode(remote.kubeClient, nodeName, false); err != nil {
+					return err
+				}
+			}
+		}
+
+		log.Info("Installed dependencies on nodes")
+	}
+
+	return nil
+}
+
+// installByPackageManagerOnNodes creates the preflight installer DaemonSet on the given nodes, or on all
+// nodes selected by the NodeSelector when nodeNames is nil. Then it waits for the DaemonSet to complete
+// and adds the logs of output container to the result.
+func (remote *Installer) installByPackageManagerOnNodes(nodeNames []string, result *installResult) (*appsv1.DaemonSet, error) {
+	newDaemonSet, err := kubeutils.PrepareDaemonSet(remote.newDaemonSetForPackageManager(), remote.kubeClient, remote.NodeSelector, remote.ImagePullSecret, remote.Tolerations)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if nodeNames != nil {
+		kubeutils.SetDaemonSetNodeNames(newDaemonSet, nodeNames)
+	}
+	daemonSet, err := commonkube.CreateDaemonSet(remote.kubeClient, newDaemonSet)
+	if err != nil {
+		return nil, err
+	}
+
+	err = monitorInitContainer(remote.kubeClient, daemonSet, remote.GetTimeout(consts.TimeoutPhaseRun, consts.ContainerConditionTimeoutLong), remote.Follow)
+	if err != nil {
+		return nil, err
+	}
+
+	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameOutput, kubeutils.WaitForDaemonSetContainersExit, remote.GetTimeout(consts.TimeoutPhaseOutput, consts.ContainerConditionTimeoutShort))
+	if err != nil {
+		return nil, err
 	}
 
 	podCollections, err := kubeutils.GetDaemonSetPodCollections(remote.kubeClient, daemonSet, consts.ContainerNameOutput, false, false, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	nodeCollections := map[string]*types.LogCollection{}
 	for _, collection := range podCollections.Pods {
 		var resultMap types.NodeCollection
 		if err := json.Unmarshal([]byte(collection.Log), &resultMap); err != nil {
-			return "", err
+			return nil, err
 		}
 
 		if reflect.DeepEqual(resultMap, types.NodeCollection{}) {
 			continue
 		}
 
-		nodeCollections[collection.Node] = resultMap.Log
+		result.add(collection.Node, &resultMap)
 	}
 
-	if reflect.DeepEqual(nodeCollections, map[string]types.LogCollection{}) {
-		return "", nil
+	return daemonSet, nil
+}
+
+// bundleDir returns the host directory of the preflight bundle to install the packages from.
+func (remote *Installer) bundleDir() string {
+	if remote.BundleImage != "" {
+		return consts.PreflightBundleHostDirectory
 	}
+	return remote.BundleDir
+}
 
-	yamlData, err := yaml.Marshal(nodeCollections)
-	if err != nil {
-		return "", err
+// installResult holds the preflight installer result of the nodes.
+type installResult struct {
+	nodeCollections     map[string]*types.LogCollection
+	rebootRequiredNodes []string
+}
+
+func newInstallResult() *installResult {
+	return &installResult{
+		nodeCollections: map[string]*types.LogCollection{},
 	}
+}
 
-	return string(yamlData), nil
+// add appends the node collection to the logs collected from the previous runs on the node.
+func (result *installResult) add(nodeName string, collection *types.NodeCollection) {
+	if collection.RebootRequired && !slices.Contains(result.rebootRequiredNodes, nodeName) {
+		result.rebootRequiredNodes = append(result.rebootRequiredNodes, nodeName)
+	}
+
+	if collection.Log == nil {
+		return
+	}
+
+	log, ok := result.nodeCollections[nodeName]
+	if !ok {
+		result.nodeCollections[nodeName] = collection.Log
+		return
+	}
+	log.Error = append(log.Error, collection.Log.Error...)
+	log.Warn = append(log.Warn, collection.Log.Warn...)
+	log.Info = append(log.Info, collection.Log.Info...)
+}
+
+// nodesWithErrors returns the given nodes whose result has errors.
+func (result *installResult) nodesWithErrors(nodeNames []string) []string {
+	var failed []string
+	for _, nodeName := range nodeNames {
+		if log, ok := result.nodeCollections[nodeName]; ok && len(log.Error) > 0 {
+			failed = append(failed, nodeName)
+		}
+	}
+	return failed
+}
+
+// logCompletedNodes logs the result of the nodes completed before the rollout stopped.
+func logCompletedNodes(result *installResult) {
+	if len(result.nodeCollections) == 0 {
+		return
+	}
+
+	yamlData, err := yaml.Marshal(result.nodeCollections)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to convert the result of the completed nodes to YAML")
+		return
+	}
+	logrus.Infof("Retrieved preflight installer result of the completed nodes:\n%v", string(yamlData))
 }
 
 // newConfigMapForContainerOptimizedOS prepares a ConfigMap for installing the dependencies on Container Optimized OS.
 func (remote *Installer) newConfigMapForContainerOptimizedOS() *corev1.ConfigMap {
 	entrypointScript := `#!/bin/bash
 
-set -euo pipefail
+set -eEuo pipefail
 
 # Define default directories
 HOST_MOUNT_DIR="${HOST_MOUNT_DIR:-/host}"
@@ -234,12 +566,69 @@ KUBERNETES_ROOTFS="${KUBERNETES_ROOTFS:-/home/kubernetes/containerized_mounter/r
 KUBERNETES_MOUNT_DIR="${HOST_MOUNT_DIR}${KUBERNETES_ROOTFS}"
 LONGHORN_DATA_PATHS="${LONGHORN_DATA_PATHS:-/var/lib/longhorn}"
 IFS=',' read -ra LONGHORN_DATA_DIRS <<< "$LONGHORN_DATA_PATHS"  # Split comma-separated dirs
+OUTPUT_FILE_PATH="${OUTPUT_FILE_PATH:-/shared/output.json}"
+
+# Messages of the node result
+INFO_MESSAGES=()
+WARN_MESSAGES=()
+ERROR_MESSAGES=()
+
+log_info() {
+  echo "$1"
+  INFO_MESSAGES+=("$1")
+}
+
+log_error() {
+  echo "ERROR: $1"
+  ERROR_MESSAGES+=("$1")
+}
+
+# Function to print the arguments as a JSON array of strings
+json_array() {
+  local _separator=""
+  local _char
+  printf '['
+  for _message in "$@"; do
+    _message="${_message//\\/\\\\}"
+    _message="${_message//\"/\\\"}"
+    _message="${_message//$'\n'/\\n}"
+    _message="${_message//$'\r'/\\r}"
+    _message="${_message//$'\t'/\\t}"
+    # Escape the other control characters, invalid in JSON strings
+    while [[ "${_message}" =~ [[:cntrl:]] ]]; do
+      _char="${BASH_REMATCH[0]}"
+      _message="${_message//"${_char}"/$(printf '\\u%04x' "'${_char}")}"
+    done
+    printf '%s"%s"' "${_separator}" "${_message}"
+    _separator=","
+  done
+  printf ']'
+}
+
+# Function to write the node result, printed by the output container
+write_result() {
+  printf '{"log":{"error":%s,"warn":%s,"info":%s}}\n' \
+    "$(json_array "${ERROR_MESSAGES[@]}")" \
+    "$(json_array "${WARN_MESSAGES[@]}")" \
+    "$(json_array "${INFO_MESSAGES[@]}")" > "${OUTPUT_FILE_PATH}.tmp"
+  mv "${OUTPUT_FILE_PATH}.tmp" "${OUTPUT_FILE_PATH}"
+}
+
+# Function to record the failed command in the node result before exiting
+on_error() {
+  local _exit_code="$1"
+  log_error "Command \"$2\" failed with exit code ${_exit_code}"
+  write_result
+  exit "${_exit_code}"
+}
+trap 'on_error $? "${BASH_COMMAND}"' ERR
 
 # Function to check the operating system is Container Optimized OS (cos)
 function check_operating_system() {
 	os=$(cat ${HOST_MOUNT_DIR}/etc/os-release | grep '^ID=' | cut -d= -f2)
 	if [ "$os" != "cos" ]; then
-		echo "ERROR: Operating system ($os) is not Container Optimized OS (cos)"
+		log_error "Operating system ($os) is not Container Optimized OS (cos)"
+		write_result
 		exit 1
 	fi
 }
@@ -249,7 +638,7 @@ mount_longhorn_data_dir_on_host() {
   local _longhorn_data_dir="$1"
 
   if is_mounted_on_host "${_longhorn_data_dir}"; then
-    echo "Longhorn data directory ${_longhorn_data_dir} is already mounted"
+    log_info "Longhorn data directory ${_longhorn_data_dir} is already mounted"
   else
     echo "Mounting Longhorn data directory ${_longhorn_data_dir} on the host"
 
@@ -259,6 +648,7 @@ mount_longhorn_data_dir_on_host() {
     nsenter --mount="${HOST_MOUNT_DIR}/proc/1/ns/mnt" mount --rbind "${_longhorn_data_dir}" "${KUBERNETES_ROOTFS}${_longhorn_data_dir}"
     nsenter --mount="${HOST_MOUNT_DIR}/proc/1/ns/mnt" mount --make-shared "${KUBERNETES_ROOTFS}${_longhorn_data_dir}"
     nsenter --mount="${HOST_MOUNT_DIR}/proc/1/ns/mnt" mount -o remount,exec "${_longhorn_data_dir}"
+    log_info "Longhorn data directory ${_longhorn_data_dir} is mounted"
   fi
 }
 
@@ -276,10 +666,11 @@ is_module_loaded_on_host() {
 # Function to load the iscsi_tcp kernel module on the host
 load_iscsi_tcp_module_on_host() {
   if is_module_loaded_on_host "iscsi_tcp"; then
-    echo "iscsi_tcp kernel module is already loaded"
+    log_info "Module iscsi_tcp is already loaded"
   else
     echo "Loading iscsi_tcp kernel module"
     nsenter --mount="${HOST_MOUNT_DIR}/proc/1/ns/mnt" modprobe iscsi_tcp
+    log_info "Successfully probed module iscsi_tcp"
   fi
 }
 
@@ -287,7 +678,9 @@ load_iscsi_tcp_module_on_host() {
 install_and_start_iscsid() {
   echo "Installing and starting open-iscsi"
   zypper install -y open-iscsi
+  log_info "Successfully installed package open-iscsi"
   /sbin/iscsid
+  log_info "Successfully started service iscsid"
 }
 
 # Validate the operating system
@@ -301,6 +694,8 @@ done
 install_and_start_iscsid
 load_iscsi_tcp_module_on_host
 
+write_result
+
 echo "Complete!"
 echo "Keep the container running for iSCSI daemon"
 sleep infinity
@@ -322,6 +717,8 @@ sleep infinity
 
 // newDaemonSetForContainerOptimizedOS prepares a DaemonSet for installing the dependencies on Container Optimized OS.
 func (remote *Installer) newDaemonSetForContainerOptimizedOS() *appsv1.DaemonSet {
+	outputFilePath := filepath.Join(consts.VolumeMountSharedDirectory, consts.FileNameOutputJSON)
+	outputPrintedFilePath := filepath.Join(consts.VolumeMountSharedDirectory, "output-printed")
 	return &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      remote.appName,
@@ -362,6 +759,10 @@ func (remote *Installer) newDaemonSetForContainerOptimizedOS() *appsv1.DaemonSet
 									Name:  "LONGHORN_DATA_PATHS",
 									Value: "/var/lib/longhorn",
 								},
+								{
+									Name:  consts.EnvOutputFilePath,
+									Value: outputFilePath,
+								},
 							},
 							SecurityContext: &corev1.SecurityContext{
 								Capabilities: &corev1.Capabilities{
@@ -380,6 +781,10 @@ func (remote *Installer) newDaemonSetForContainerOptimizedOS() *appsv1.DaemonSet
 									Name:      consts.VolumeMountEntrypointName,
 									MountPath: consts.VolumeMountEntrypointDirectory,
 								},
+								{
+									Name:      consts.VolumeMountSharedName,
+									MountPath: consts.VolumeMountSharedDirectory,
+								},
 							},
 
 							ReadinessProbe: &corev1.Probe{
@@ -415,6 +820,28 @@ func (remote *Installer) newDaemonSetForContainerOptimizedOS() *appsv1.DaemonSet
 								FailureThreshold:    3,
 							},
 						},
+						{
+							// Prints the node result once written by the node agent, and keeps running, so the
+							// result is printed once per pod.
+							Name:  consts.ContainerNameOutput,
+							Image: utils.BuildImageName(consts.ImageBciBase, remote.ImageRegistry),
+							Command: []string{"/bin/bash", "-c", fmt.Sprintf("until [ -f %[1]s ]; do sleep 1; done; cat %[1]s; touch %[2]s; sleep infinity",
+								outputFilePath, outputPrintedFilePath)},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      consts.VolumeMountSharedName,
+									MountPath: consts.VolumeMountSharedDirectory,
+								},
+							},
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									Exec: &corev1.ExecAction{
+										Command: []string{"test", "-f", outputPrintedFilePath},
+									},
+								},
+								PeriodSeconds: 2,
+							},
+						},
 					},
 					Volumes: []corev1.Volume{
 						{
@@ -436,6 +863,12 @@ func (remote *Installer) newDaemonSetForContainerOptimizedOS() *appsv1.DaemonSet
 								},
 							},
 						},
+						{
+							Name: consts.VolumeMountSharedName,
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
 					},
 				},
 			},
@@ -449,7 +882,7 @@ func (remote *Installer) newDaemonSetForContainerOptimizedOS() *appsv1.DaemonSet
 // newDaemonSetForPackageManager prepares a DaemonSet for installing dependencies with the package manager.
 func (remote *Installer) newDaemonSetForPackageManager() *appsv1.DaemonSet {
 	outputFilePath := filepath.Join(consts.VolumeMountSharedDirectory, consts.FileNameOutputJSON)
-	return &appsv1.DaemonSet{
+	daemonSet := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      remote.appName,
 			Namespace: remote.Namespace,
@@ -489,6 +922,10 @@ func (remote *Installer) newDaemonSetForPackageManager() *appsv1.DaemonSet {
 									Name:  consts.EnvOutputFilePath,
 									Value: outputFilePath,
 								},
+								{
+									Name:  consts.EnvDryRun,
+									Value: commonutils.ConvertTypeToString(remote.DryRun),
+								},
 								{
 									Name:  consts.EnvUpdatePackageList,
 									Value: commonutils.ConvertTypeToString(remote.UpdatePackages),
@@ -505,6 +942,10 @@ func (remote *Installer) newDaemonSetForPackageManager() *appsv1.DaemonSet {
 									Name:  consts.EnvHugePageSize,
 									Value: commonutils.ConvertTypeToString(remote.HugePageSize),
 								},
+								{
+									Name:  consts.EnvHugePageNuma,
+									Value: commonutils.ConvertTypeToString(remote.HugePageNuma),
+								},
 								{
 									Name:  consts.EnvPciAllowed,
 									Value: remote.AllowPci,
@@ -521,6 +962,18 @@ func (remote *Installer) newDaemonSetForPackageManager() *appsv1.DaemonSet {
 									Name:  consts.EnvRestartKubeletWindow,
 									Value: remote.RestartKubeletWindow,
 								},
+								{
+									Name:  consts.EnvConfigureSysctl,
+									Value: commonutils.ConvertTypeToString(remote.ConfigureSysctl),
+								},
+								{
+									Name:  consts.EnvConfigureMultipath,
+									Value: commonutils.ConvertTypeToString(remote.ConfigureMultipath),
+								},
+								{
+									Name:  consts.EnvPreflightBundleDir,
+									Value: remote.bundleDir(),
+								},
 								{
 									Name: consts.EnvCurrentNodeID,
 									ValueFrom: &corev1.EnvVarSource{
@@ -586,4 +1039,32 @@ func (remote *Installer) newDaemonSetForPackageManager() *appsv1.DaemonSet {
 			},
 		},
 	}
+
+	if remote.BundleImage != "" {
+		// Copy the preflight bundle from the image to the host, so the package manager can install from it.
+		hostBundleDirectory := filepath.Join(consts.VolumeMountHostDirectory, consts.PreflightBundleHostDirectory)
+		bundleContainer := corev1.Container{
+			Name:    consts.ContainerNameBundle,
+			Image:   utils.BuildImageName(remote.BundleImage, remote.ImageRegistry),
+			Command: []string{"sh", "-c", fmt.Sprintf("mkdir -p %[2]s && cp -a %[1]s/. %[2]s", consts.PreflightBundleImageDirectory, hostBundleDirectory)},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      consts.VolumeMountHostName,
+					MountPath: consts.VolumeMountHostDirectory,
+				},
+			},
+		}
+		podSpec := &daemonSet.Spec.Template.Spec
+		podSpec.InitContainers = append([]corev1.Container{bundleContainer}, podSpec.InitContainers...)
+	}
+
+	if remote.DependencyConfig != "" {
+		setDependencyConfig(daemonSet, remote.appName)
+	}
+
+	if remote.PreHook != "" || remote.PostHook != "" {
+		setHooks(daemonSet, remote.appName, remote.PreHook != "", remote.PostHook != "")
+	}
+
+	return daemonSet
 }
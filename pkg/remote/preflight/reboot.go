@@ -0,0 +1,166 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/utils/ptr"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/cli/pkg/consts"
+	"github.com/longhorn/cli/pkg/utils"
+
+	kubeutils "github.com/longhorn/cli/pkg/utils/kubernetes"
+)
+
+// rebootNodes reboots the nodes requiring a reboot to complete the install, one node at a time.
+// For each node, it takes the cluster-wide reboot Lease, cordons and drains the node, reboots it
+// through the host namespaces, waits for it to be Ready, executes the installer again on it, then
+// uncordons the node unless it was already cordoned, and releases the Lease.
+func (remote *Installer) rebootNodes(result *installResult) error {
+	// The installer DaemonSet is re-created on each rebooted node.
+	if err := kubeutils.DeleteDaemonSetAndWait(remote.kubeClient, remote.newDaemonSetForPackageManager(), remote.GetTimeout(consts.TimeoutPhaseReady, consts.ContainerConditionTimeoutShort)); err != nil {
+		return err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return errors.Wrap(err, "failed to get hostname")
+	}
+	holder := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+
+	nodeNames := slices.Clone(result.rebootRequiredNodes)
+	slices.Sort(nodeNames)
+	result.rebootRequiredNodes = nil
+
+	for i, nodeName := range nodeNames {
+		log := logrus.WithField("node", nodeName)
+		log.Infof("Rebooting node (%d/%d)", i+1, len(nodeNames))
+
+		if err := kubeutils.AcquireLease(remote.kubeClient, remote.Namespace, consts.LeaseNamePreflightReboot, holder, consts.NodeRebootLeaseDuration, consts.NodeRebootLeaseDuration); err != nil {
+			return err
+		}
+
+		err := remote.rebootNode(nodeName, result)
+		if _err := kubeutils.ReleaseLease(remote.kubeClient, remote.Namespace, consts.LeaseNamePreflightReboot, holder); _err != nil {
+			log.WithError(_err).Warn("Failed to release reboot Lease")
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to reboot node %v, stopped the rollout", nodeName)
+		}
+
+		log.Info("Rebooted node and executed preflight installer again")
+	}
+
+	return nil
+}
+
+// rebootNode cordons, drains and reboots the node, then executes the installer again on it.
+// The node is left cordoned when it fails, for investigation. A node already cordoned before
+// the reboot is left cordoned.
+func (remote *Installer) rebootNode(nodeName string, result *installResult) error {
+	node, err := remote.kubeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node %v", nodeName)
+	}
+	bootID := node.Status.NodeInfo.BootID
+	wasUnschedulable := node.Spec.Unschedulable
+
+	if !wasUnschedulable {
+		if err := kubeutils.CordonNode(remote.kubeClient, nodeName, true); err != nil {
+			return err
+		}
+	}
+	if err := kubeutils.DrainNode(remote.kubeClient, nodeName, consts.NodeDrainTimeout); err != nil {
+		return err
+	}
+
+	pod, err := remote.kubeClient.CoreV1().Pods(remote.Namespace).Create(context.TODO(), remote.newRebootPod(nodeName, bootID), metav1.CreateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to create reboot pod")
+	}
+
+	// The reboot pod is deleted as soon as the node is back, so it is not left bound to the node
+	// while the installer is executed again.
+	err = kubeutils.WaitForNodeReboot(remote.kubeClient, nodeName, bootID, consts.NodeRebootTimeout)
+	if _err := remote.kubeClient.CoreV1().Pods(pod.Namespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{GracePeriodSeconds: ptr.To(int64(0))}); _err != nil && !apierrors.IsNotFound(_err) {
+		logrus.WithError(_err).Warnf("Failed to delete reboot pod %v", pod.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	daemonSet, err := remote.installByPackageManagerOnNodes([]string{nodeName}, result)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute preflight installer after reboot")
+	}
+	if err := kubeutils.DeleteDaemonSetAndWait(remote.kubeClient, daemonSet, remote.GetTimeout(consts.TimeoutPhaseReady, consts.ContainerConditionTimeoutShort)); err != nil {
+		return err
+	}
+	if slices.Contains(result.rebootRequiredNodes, nodeName) {
+		return errors.New("node still requires a reboot after reboot")
+	}
+
+	if wasUnschedulable {
+		logrus.WithField("node", nodeName).Info("Leaving node cordoned, it was cordoned before the reboot")
+		return nil
+	}
+	return kubeutils.CordonNode(remote.kubeClient, nodeName, false)
+}
+
+// rebootScript reboots the host when its boot ID is the one given as the first argument.
+const rebootScript = `if [ "$(cat /proc/sys/kernel/random/boot_id)" != "$1" ]; then
+	echo "Boot ID changed from $1, node already rebooted"
+	exit 0
+fi
+exec reboot`
+
+// newRebootPod prepares a pod rebooting the node through the host namespaces. The reboot command of the
+// host is used rather than systemctl, so the OpenRC hosts are rebooted as well. The node is only rebooted
+// while its boot ID is still the given one, so the pod started again by the kubelet after the boot does
+// not reboot the node a second time.
+func (remote *Installer) newRebootPod(nodeName, bootID string) *corev1.Pod {
+	var imagePullSecrets []corev1.LocalObjectReference
+	if remote.ImagePullSecret != "" {
+		imagePullSecrets = []corev1.LocalObjectReference{{Name: remote.ImagePullSecret}}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: consts.AppNamePreflightReboot + "-",
+			Namespace:    remote.Namespace,
+			Labels: map[string]string{
+				"app": consts.AppNamePreflightReboot,
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:      nodeName,
+			HostPID:       true,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Tolerations: []corev1.Toleration{
+				{
+					Operator: corev1.TolerationOpExists,
+				},
+			},
+			ImagePullSecrets: imagePullSecrets,
+			Containers: []corev1.Container{
+				{
+					Name:    consts.ContainerName,
+					Image:   utils.BuildImageName(consts.ImageBciBase, remote.ImageRegistry),
+					Command: []string{"nsenter", "--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid", "--", "sh", "-c", rebootScript, "sh", bootID},
+					SecurityContext: &corev1.SecurityContext{
+						Privileged: ptr.To(true),
+					},
+				},
+			},
+		},
+	}
+}
@@ -89,6 +89,10 @@ func (remote *Exporter) Init() error {
 // It ensures the init container completes and the engine container is ready
 // before collecting volume information and returning it as a YAML string.
 func (remote *Exporter) Run() (string, error) {
+	if err := kubeutils.CheckNamespacePodSecurity(remote.kubeClient, remote.Namespace, remote.LabelNamespacePrivileged); err != nil {
+		return "", err
+	}
+
 	newConfigMap := remote.newConfigMapForSimpleLonghorn()
 	configMap, err := commonkube.GetConfigMap(remote.kubeClient, newConfigMap.Namespace, newConfigMap.Name)
 	if err == nil {
@@ -116,12 +120,12 @@ func (remote *Exporter) Run() (string, error) {
 		return "", err
 	}
 
-	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameInit, kubeutils.WaitForDaemonSetContainersExit, ptr.To(consts.ContainerConditionMaxTolerationMedium))
+	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameInit, kubeutils.WaitForDaemonSetContainersExit, remote.GetTimeout(consts.TimeoutPhaseRun, consts.ContainerConditionTimeoutMedium))
 	if err != nil {
 		return "", err
 	}
 
-	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameEngine, kubeutils.WaitForDaemonSetContainersReady, ptr.To(consts.ContainerConditionMaxTolerationMedium))
+	err = kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameEngine, kubeutils.WaitForDaemonSetContainersReady, remote.GetTimeout(consts.TimeoutPhaseReady, consts.ContainerConditionTimeoutMedium))
 	if err != nil {
 		return "", err
 	}
@@ -59,6 +59,10 @@ func (remote *Trimmer) Init() error {
 
 // Run creates the DaemonSet for the volume trimmer, and waits for it to complete.
 func (remote *Trimmer) Run() error {
+	if err := kubeutils.CheckNamespacePodSecurity(remote.kubeClient, remote.Namespace, remote.LabelNamespacePrivileged); err != nil {
+		return err
+	}
+
 	newDaemonSet, err := kubeutils.PrepareDaemonSet(remote.newDaemonSet(), remote.kubeClient, remote.NodeSelector, remote.ImagePullSecret, remote.Tolerations)
 	if err != nil {
 		return err
@@ -68,7 +72,7 @@ func (remote *Trimmer) Run() error {
 		return err
 	}
 
-	return kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameInit, kubeutils.WaitForDaemonSetContainersExit, ptr.To(consts.ContainerConditionMaxTolerationMedium))
+	return kubeutils.MonitorDaemonSetContainer(remote.kubeClient, daemonSet, consts.ContainerNameInit, kubeutils.WaitForDaemonSetContainersExit, remote.GetTimeout(consts.TimeoutPhaseRun, consts.ContainerConditionTimeoutMedium))
 }
 
 // Cleanup deletes the DaemonSet created for the volume trimmer.
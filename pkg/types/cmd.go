@@ -1,5 +1,11 @@
 package types
 
+import (
+	"time"
+
+	"github.com/longhorn/cli/pkg/consts"
+)
+
 // GlobalCmdOptions is the common options for all subcommands.
 type GlobalCmdOptions struct {
 	LogLevel        string // The log level for the CLI.
@@ -10,4 +16,21 @@ type GlobalCmdOptions struct {
 	NodeSelector    string // The node selector to choose nodes on which to run DaemonSet pods
 	Tolerations     string // The tolerations for DaemonSet pods
 	Namespace       string // The namespace to run DaemonSet pods
+
+	LabelNamespacePrivileged bool // Label the namespace to allow privileged pods when its Pod Security Standard rejects them
+
+	Timeout       time.Duration                         // The timeout of every phase waiting for the DaemonSet pods, replacing the phase defaults
+	PhaseTimeouts map[consts.TimeoutPhase]time.Duration // The timeouts of specific phases, overriding the global timeout
+}
+
+// GetTimeout returns the timeout of the phase: the timeout of the phase if specified, otherwise the global
+// timeout if specified, otherwise the default timeout of the phase for the command.
+func (opts *GlobalCmdOptions) GetTimeout(phase consts.TimeoutPhase, defaultTimeout time.Duration) time.Duration {
+	if timeout, ok := opts.PhaseTimeouts[phase]; ok {
+		return timeout
+	}
+	if opts.Timeout > 0 {
+		return opts.Timeout
+	}
+	return defaultTimeout
 }
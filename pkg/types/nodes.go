@@ -3,4 +3,6 @@ package types
 // NodeCollection represents a collection of nodes.
 type NodeCollection struct {
 	Log *LogCollection `json:"log,omitempty" yaml:"log,omitempty"`
+
+	RebootRequired bool `json:"rebootRequired,omitempty" yaml:"rebootRequired,omitempty"` // The node needs a reboot to complete the preflight install.
 }
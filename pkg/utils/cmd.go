@@ -5,7 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -32,6 +34,89 @@ func SetGlobalOptionsRemote(cmd *cobra.Command, globalOpts *types.GlobalCmdOptio
 	cmd.PersistentFlags().StringVar(&globalOpts.ImagePullSecret, consts.CmdOptImagePullSecret, globalOpts.ImagePullSecret, "Secret with registry credentials for pulling images")
 	cmd.PersistentFlags().StringVar(&globalOpts.NodeSelector, consts.CmdOptNodeSelector, globalOpts.NodeSelector, "Comma-separated list of key=value pairs to match against node labels, selecting the nodes the DaemonSet will run on (e.g. env=prod,zone=us-west).")
 	cmd.PersistentFlags().StringVar(&globalOpts.Namespace, consts.CmdOptNamespace, globalOpts.Namespace, "The namespace to run DaemonSet pods.")
+	cmd.PersistentFlags().BoolVar(&globalOpts.LabelNamespacePrivileged, consts.CmdOptLabelNamespacePrivileged, globalOpts.LabelNamespacePrivileged, "Label the namespace with the privileged Pod Security Standard when it enforces the baseline or restricted standard, or when it has no enforce label and is subject to the cluster default, which may reject the DaemonSet pods.")
+	SetTimeoutOptions(cmd, globalOpts)
+}
+
+// SetTimeoutOptions sets the global timeout options for remote commands.
+func SetTimeoutOptions(cmd *cobra.Command, globalOpts *types.GlobalCmdOptions) {
+	if globalOpts.PhaseTimeouts == nil {
+		globalOpts.PhaseTimeouts = map[consts.TimeoutPhase]time.Duration{}
+	}
+
+	cmd.PersistentFlags().DurationVar(&globalOpts.Timeout, consts.CmdOptTimeout, globalOpts.Timeout, "Maximum wall-clock time to wait for each phase of the DaemonSet pods on all nodes (e.g. 30m), replacing the default of the phase. Use the default of each phase when 0.")
+	cmd.PersistentFlags().Var(&phaseTimeoutsValue{timeouts: globalOpts.PhaseTimeouts}, consts.CmdOptPhaseTimeout, fmt.Sprintf("Comma-separated list of phase=duration pairs overriding the timeout of the phases (%s), e.g. run=1h,output=2m. The run phase waits for the operation on the nodes, the ready phase for the long running pods, and the output phase for the result.", strings.Join(timeoutPhaseNames(), ", ")))
+}
+
+// phaseTimeoutsValue is the flag value of the timeouts per phase, parsed from a comma-separated list of
+// phase=duration pairs.
+type phaseTimeoutsValue struct {
+	timeouts map[consts.TimeoutPhase]time.Duration
+}
+
+func (v *phaseTimeoutsValue) String() string {
+	pairs := make([]string, 0, len(v.timeouts))
+	for _, phase := range consts.TimeoutPhases {
+		if timeout, ok := v.timeouts[phase]; ok {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", phase, timeout))
+		}
+	}
+	return strings.Join(pairs, consts.CmdOptSeperator)
+}
+
+func (v *phaseTimeoutsValue) Set(value string) error {
+	timeouts, err := ParsePhaseTimeouts(value)
+	if err != nil {
+		return err
+	}
+	for phase, timeout := range timeouts {
+		v.timeouts[phase] = timeout
+	}
+	return nil
+}
+
+func (v *phaseTimeoutsValue) Type() string {
+	return "phase=duration"
+}
+
+// ParsePhaseTimeouts parses a comma-separated list of phase=duration pairs.
+func ParsePhaseTimeouts(value string) (map[consts.TimeoutPhase]time.Duration, error) {
+	timeouts := map[consts.TimeoutPhase]time.Duration{}
+	for _, pair := range strings.Split(value, consts.CmdOptSeperator) {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		phase, rawTimeout, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, errors.Errorf("invalid phase timeout %q (expected format phase=duration)", pair)
+		}
+
+		timeoutPhase := consts.TimeoutPhase(strings.TrimSpace(phase))
+		if !slices.Contains(consts.TimeoutPhases, timeoutPhase) {
+			return nil, errors.Errorf("invalid phase %q (expected one of %s)", phase, strings.Join(timeoutPhaseNames(), ", "))
+		}
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(rawTimeout))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid timeout of phase %s", timeoutPhase)
+		}
+		if timeout <= 0 {
+			return nil, errors.Errorf("timeout of phase %s must be positive", timeoutPhase)
+		}
+
+		timeouts[timeoutPhase] = timeout
+	}
+	return timeouts, nil
+}
+
+func timeoutPhaseNames() []string {
+	names := make([]string, 0, len(consts.TimeoutPhases))
+	for _, phase := range consts.TimeoutPhases {
+		names = append(names, string(phase))
+	}
+	return names
 }
 
 // SetFlagHidden adds a option flag to the given command and mark it as hidden.
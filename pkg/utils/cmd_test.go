@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/longhorn/cli/pkg/consts"
+	"github.com/longhorn/cli/pkg/types"
+)
+
+func TestParsePhaseTimeouts(t *testing.T) {
+	for testName, testCase := range map[string]struct {
+		expected    map[consts.TimeoutPhase]time.Duration
+		value       string
+		expectedErr bool
+	}{
+		"empty":          {map[consts.TimeoutPhase]time.Duration{}, "", false},
+		"single phase":   {map[consts.TimeoutPhase]time.Duration{consts.TimeoutPhaseRun: time.Hour}, "run=1h", false},
+		"multiple phase": {map[consts.TimeoutPhase]time.Duration{consts.TimeoutPhaseRun: 30 * time.Minute, consts.TimeoutPhaseOutput: 2 * time.Minute}, " run=30m, output = 2m ", false},
+		"unknown phase":  {nil, "install=1h", true},
+		"missing value":  {nil, "run", true},
+		"invalid value":  {nil, "run=1", true},
+		"zero value":     {nil, "ready=0s", true},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			timeouts, err := ParsePhaseTimeouts(testCase.value)
+			if testCase.expectedErr {
+				if err == nil {
+					t.Errorf("expected error, got %v", timeouts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(timeouts, testCase.expected) {
+				t.Errorf("expected %v, got %v", testCase.expected, timeouts)
+			}
+		})
+	}
+}
+
+func TestGetTimeout(t *testing.T) {
+	for testName, testCase := range map[string]struct {
+		expected time.Duration
+		opts     types.GlobalCmdOptions
+	}{
+		"default":        {10 * time.Minute, types.GlobalCmdOptions{}},
+		"global timeout": {time.Hour, types.GlobalCmdOptions{Timeout: time.Hour}},
+		"phase timeout": {2 * time.Hour, types.GlobalCmdOptions{
+			Timeout:       time.Hour,
+			PhaseTimeouts: map[consts.TimeoutPhase]time.Duration{consts.TimeoutPhaseRun: 2 * time.Hour},
+		}},
+		"other phase timeout": {time.Hour, types.GlobalCmdOptions{
+			Timeout:       time.Hour,
+			PhaseTimeouts: map[consts.TimeoutPhase]time.Duration{consts.TimeoutPhaseOutput: 2 * time.Hour},
+		}},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			if got := testCase.opts.GetTimeout(consts.TimeoutPhaseRun, 10*time.Minute); got != testCase.expected {
+				t.Errorf("expected %v, got %v", testCase.expected, got)
+			}
+		})
+	}
+}
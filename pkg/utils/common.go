@@ -35,3 +35,21 @@ func getImagePathWithTag(image string) string {
 	// first segment is not a registry → keep full image reference
 	return image
 }
+
+// SplitIntoBatches splits the items into batches of at most batchSize items, preserving their order.
+// All items are returned in a single batch if batchSize is not positive.
+func SplitIntoBatches[T any](items []T, batchSize int) [][]T {
+	if len(items) == 0 {
+		return nil
+	}
+	if batchSize <= 0 {
+		return [][]T{items}
+	}
+
+	batches := make([][]T, 0, (len(items)+batchSize-1)/batchSize)
+	for start := 0; start < len(items); start += batchSize {
+		end := min(start+batchSize, len(items))
+		batches = append(batches, items[start:end])
+	}
+	return batches
+}
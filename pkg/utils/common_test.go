@@ -1,6 +1,9 @@
 package utils
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestBuildImageName(t *testing.T) {
 	for testName, testCase := range map[string]struct {
@@ -18,3 +21,24 @@ func TestBuildImageName(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitIntoBatches(t *testing.T) {
+	for testName, testCase := range map[string]struct {
+		expected  [][]string
+		items     []string
+		batchSize int
+	}{
+		"no items":               {nil, nil, 2},
+		"batch size not set":     {[][]string{{"a", "b", "c"}}, []string{"a", "b", "c"}, 0},
+		"batch size larger":      {[][]string{{"a", "b"}}, []string{"a", "b"}, 3},
+		"evenly split":           {[][]string{{"a", "b"}, {"c", "d"}}, []string{"a", "b", "c", "d"}, 2},
+		"last batch smaller":     {[][]string{{"a", "b"}, {"c"}}, []string{"a", "b", "c"}, 2},
+		"batch size of one item": {[][]string{{"a"}, {"b"}}, []string{"a", "b"}, 1},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			if got := SplitIntoBatches(testCase.items, testCase.batchSize); !reflect.DeepEqual(got, testCase.expected) {
+				t.Errorf("expected %v, got %v", testCase.expected, got)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package kubernetes
 import (
 	"context"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,20 +14,24 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
 	kubeclient "k8s.io/client-go/kubernetes"
 
+	commonkube "github.com/longhorn/go-common-libs/kubernetes"
 	lhtypes "github.com/longhorn/longhorn-manager/types"
 
 	"github.com/longhorn/cli/pkg/consts"
 	"github.com/longhorn/cli/pkg/types"
 )
 
-type monitorDaemonSetContainerConditionFunc func(ctx context.Context, logger *logrus.Entry, kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string, maxConditionToleration *int) error
+type monitorDaemonSetContainerConditionFunc func(ctx context.Context, logger *logrus.Entry, kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string) error
 
 // MonitorDaemonSetContainer monitors the specified container within the given DaemonSet until a certain condition is met.
 // The condition is defined by the monitorDaemonSetContainerConditionFunc.
-// Returns nil on success, or an error if the condition check fails or the timeout is reached.
-func MonitorDaemonSetContainer(kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string, conditionFunc monitorDaemonSetContainerConditionFunc, maxConditionToleration *int) error {
+// Returns nil on success, or an error if the condition check fails or the timeout is reached. The timeout is
+// measured in wall-clock time, and the error lists the nodes on which the condition is still not met.
+func MonitorDaemonSetContainer(kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string, conditionFunc monitorDaemonSetContainerConditionFunc, timeout time.Duration) error {
 	selector := fmt.Sprintf("app=%s", daemonSet.Labels["app"])
 	workload, err := NewWorkload(kubeClient, daemonSet, "DaemonSet", selector)
 	if err != nil {
@@ -37,52 +43,47 @@ func MonitorDaemonSetContainer(kubeClient *kubeclient.Clientset, daemonSet *apps
 		"namespace": daemonSet.Namespace,
 		"name":      daemonSet.Name,
 		"container": containerName,
+		"timeout":   timeout,
 	})
 
-	ctx, cancel := context.WithTimeoutCause(context.Background(), time.Hour, errors.Errorf("timed out waiting for the DaemonSet %s container %s condition", daemonSet.Name, containerName))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	doneCh := make(chan struct{})
-	errCh := make(chan error, 1)
-	defer close(errCh)
+	err = conditionFunc(ctx, log, kubeClient, daemonSet, containerName)
+	if err == nil {
+		return nil
+	}
 
-	go func() {
-		defer close(doneCh)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = errors.Wrapf(err, "timed out after %v waiting for DaemonSet %s", timeout, daemonSet.Name)
+	} else {
+		err = errors.Wrap(err, "failed DaemonSet condition check")
+	}
 
-		err = conditionFunc(ctx, log, kubeClient, daemonSet, containerName, maxConditionToleration)
-		if err != nil {
-			errCh <- errors.Wrap(err, "failed DaemonSet condition check")
-		}
-	}()
-
-	// Check if any errors occurred in goroutines
-	select {
-	case err := <-errCh:
-		log.Debug("Getting DaemonSet pods container logs")
-		podsLog, _err := workload.GetPodsLogByContainer(ctx, log, containerName, true, true, nil)
-		if _err != nil {
-			return errors.Wrap(_err, "failed to get DaemonSet pods container logs")
-		}
+	// The monitor context may be expired, the logs are retrieved with a new one.
+	logCtx, logCancel := context.WithTimeout(context.Background(), consts.ContainerConditionTimeoutShort)
+	defer logCancel()
 
-		if podsLog == nil {
-			return nil
-		}
+	log.Debug("Getting DaemonSet pods container logs")
+	podsLog, _err := workload.GetPodsLogByContainer(logCtx, log, containerName, true, true, nil)
+	if _err != nil {
+		return errors.Wrap(_err, "failed to get DaemonSet pods container logs")
+	}
 
-		for podName, collection := range podsLog.Pods {
-			log := log.WithFields(logrus.Fields{
-				"pod":  podName,
-				"node": collection.Node,
-			})
-			log.Trace("Beginning of pod logs >>>>>")
-			log.Debug(collection.Log)
-			log.Trace("<<<<< End of pod logs")
-		}
+	if podsLog == nil {
 		return err
-	case <-doneCh:
-		return nil
-	case <-ctx.Done():
-		return errors.Wrapf(ctx.Err(), "Timed out waiting for container %s to be running", containerName)
 	}
+
+	for podName, collection := range podsLog.Pods {
+		log := log.WithFields(logrus.Fields{
+			"pod":  podName,
+			"node": collection.Node,
+		})
+		log.Trace("Beginning of pod logs >>>>>")
+		log.Debug(collection.Log)
+		log.Trace("<<<<< End of pod logs")
+	}
+	return err
 }
 
 // GetDaemonSetPodCollections retrieves the logs of the specified container within the given DaemonSet.
@@ -116,6 +117,27 @@ func GetDaemonSetPodCollections(kubeClient *kubeclient.Clientset, daemonSet *app
 	return collections, nil
 }
 
+// FollowDaemonSetContainer streams the logs of the specified container within the given DaemonSet to out,
+// prefixed with the node name, along with a summary of the container progress on each node.
+// The returned function stops the follow.
+func FollowDaemonSetContainer(kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string, out io.Writer) (stop func(), err error) {
+	selector := fmt.Sprintf("app=%s", daemonSet.Labels["app"])
+	workload, err := NewWorkload(kubeClient, daemonSet, "DaemonSet", selector)
+	if err != nil {
+		return nil, err
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"kind":      "DaemonSet",
+		"namespace": daemonSet.Namespace,
+		"name":      daemonSet.Name,
+		"container": containerName,
+	})
+
+	log.Debug("Following DaemonSet pods container logs")
+	return workload.FollowPodsLogByContainer(log, containerName, out), nil
+}
+
 // PrepareDaemonSet takes DaemonSet object and populates common fields such as NodeSelector, ImagePullSecrets and Tolerations
 func PrepareDaemonSet(daemonSet *appsv1.DaemonSet, kubeClient *kubeclient.Clientset, nodeSelectorRaw, imagePullSecretRaw, tolerationsRaw string) (*appsv1.DaemonSet, error) {
 	nodeSelector, err := parseNodeSelector(nodeSelectorRaw)
@@ -178,3 +200,134 @@ func parseImagePullSecret(kubeClient *kubeclient.Clientset, namespace, imagePull
 	}
 	return []corev1.LocalObjectReference{{Name: imagePullSecret}}, nil
 }
+
+// daemonSetDefaultTolerations are the tolerations added to the DaemonSet pods by the DaemonSet controller.
+var daemonSetDefaultTolerations = []corev1.Toleration{
+	{Key: corev1.TaintNodeNotReady, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeUnreachable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeDiskPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeMemoryPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodePIDPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeUnschedulable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+}
+
+// ListDaemonSetNodeNames returns the sorted names of the nodes the DaemonSet pods can run on, the nodes
+// matching the node selector string (e.g., "key1=value1,key2=value2") whose taints are tolerated by the
+// tolerations string (e.g., "key=value:NoSchedule;:NoExecute") or by the DaemonSet controller. All
+// untainted nodes are returned if the node selector is empty.
+func ListDaemonSetNodeNames(kubeClient *kubeclient.Clientset, nodeSelectorRaw, tolerationsRaw string) ([]string, error) {
+	nodeSelector, err := parseNodeSelector(nodeSelectorRaw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q argument", consts.CmdOptNodeSelector)
+	}
+
+	tolerations, err := lhtypes.UnmarshalTolerations(tolerationsRaw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q argument", consts.CmdOptTolerations)
+	}
+	tolerations = append(tolerations, daemonSetDefaultTolerations...)
+
+	nodes, err := kubeClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(nodeSelector).String(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+
+	nodeNames := make([]string, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		if !areTaintsTolerated(node.Spec.Taints, tolerations) {
+			logrus.Debugf("Skipped node %v with taints not tolerated by the DaemonSet", node.Name)
+			continue
+		}
+		nodeNames = append(nodeNames, node.Name)
+	}
+	sort.Strings(nodeNames)
+	return nodeNames, nil
+}
+
+// areTaintsTolerated checks if the NoSchedule and NoExecute taints are all tolerated by the tolerations.
+func areTaintsTolerated(taints []corev1.Taint, tolerations []corev1.Toleration) bool {
+	for i := range taints {
+		taint := &taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+
+		tolerated := false
+		for j := range tolerations {
+			if toleratesTaint(&tolerations[j], taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// toleratesTaint checks if the toleration tolerates the taint, as the Kubernetes scheduler does.
+func toleratesTaint(toleration *corev1.Toleration, taint *corev1.Taint) bool {
+	if toleration.Effect != "" && toleration.Effect != taint.Effect {
+		return false
+	}
+	if toleration.Key != "" && toleration.Key != taint.Key {
+		return false
+	}
+
+	switch toleration.Operator {
+	case "", corev1.TolerationOpEqual:
+		return toleration.Value == taint.Value
+	case corev1.TolerationOpExists:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetDaemonSetNodeNames restricts the DaemonSet pods to the given nodes with a required node affinity.
+func SetDaemonSetNodeNames(daemonSet *appsv1.DaemonSet, nodeNames []string) {
+	daemonSet.Spec.Template.Spec.Affinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchFields: []corev1.NodeSelectorRequirement{
+							{
+								Key:      metav1.ObjectNameField,
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   nodeNames,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// DeleteDaemonSetAndWait deletes the DaemonSet and waits for its pods to be deleted.
+func DeleteDaemonSetAndWait(kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, timeout time.Duration) error {
+	if err := commonkube.DeleteDaemonSet(kubeClient, daemonSet.Namespace, daemonSet.Name); err != nil {
+		return errors.Wrap(err, "failed to delete DaemonSet")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := wait.PollUntilContextCancel(ctx, time.Second, true, func(ctx context.Context) (bool, error) {
+		pods, err := kubeClient.CoreV1().Pods(daemonSet.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(daemonSet.Spec.Selector.MatchLabels).String(),
+		})
+		if err != nil {
+			return false, err
+		}
+		return len(pods.Items) == 0, nil
+	})
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return errors.Wrapf(ctx.Err(), "timed out waiting for the DaemonSet %s pods to be deleted", daemonSet.Name)
+}
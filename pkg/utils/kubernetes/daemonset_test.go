@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestParseNodeSelector(t *testing.T) {
@@ -77,3 +79,69 @@ func TestParseNodeSelector(t *testing.T) {
 
 	}
 }
+
+func TestAreTaintsTolerated(t *testing.T) {
+	controlPlane := corev1.Taint{Key: "node-role.kubernetes.io/control-plane", Effect: corev1.TaintEffectNoSchedule}
+	dedicated := corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoExecute}
+	preferred := corev1.Taint{Key: "preferred", Effect: corev1.TaintEffectPreferNoSchedule}
+	unschedulable := corev1.Taint{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule}
+
+	for _, test := range []struct {
+		name        string
+		taints      []corev1.Taint
+		tolerations []corev1.Toleration
+		want        bool
+	}{
+		{
+			name: "no taints",
+			want: true,
+		},
+		{
+			name:   "untolerated taint",
+			taints: []corev1.Taint{controlPlane},
+			want:   false,
+		},
+		{
+			name:   "PreferNoSchedule taint",
+			taints: []corev1.Taint{preferred},
+			want:   true,
+		},
+		{
+			name:        "default DaemonSet toleration",
+			taints:      []corev1.Taint{unschedulable},
+			tolerations: daemonSetDefaultTolerations,
+			want:        true,
+		},
+		{
+			name:        "toleration by key",
+			taints:      []corev1.Taint{controlPlane},
+			tolerations: []corev1.Toleration{{Key: controlPlane.Key, Operator: corev1.TolerationOpExists}},
+			want:        true,
+		},
+		{
+			name:        "toleration of all taints by effect",
+			taints:      []corev1.Taint{dedicated},
+			tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute}},
+			want:        true,
+		},
+		{
+			name:        "toleration with different value",
+			taints:      []corev1.Taint{dedicated},
+			tolerations: []corev1.Toleration{{Key: dedicated.Key, Operator: corev1.TolerationOpEqual, Value: "cpu", Effect: corev1.TaintEffectNoExecute}},
+			want:        false,
+		},
+		{
+			name:        "toleration with different effect",
+			taints:      []corev1.Taint{controlPlane, dedicated},
+			tolerations: []corev1.Toleration{{Key: dedicated.Key, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute}},
+			want:        false,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := areTaintsTolerated(test.taints, test.tolerations)
+			if got != test.want {
+				t.Errorf("areTaintsTolerated() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package kubernetes
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+)
+
+// AcquireLease waits until the Lease is acquired by the holder, or the timeout is reached.
+// A Lease held by another holder is taken over once it is not renewed within its duration.
+func AcquireLease(kubeClient *kubeclient.Clientset, namespace, name, holder string, duration, timeout time.Duration) error {
+	log := logrus.WithFields(logrus.Fields{
+		"kind":      "Lease",
+		"namespace": namespace,
+		"name":      name,
+		"holder":    holder,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	currentHolder := ""
+	err := wait.PollUntilContextCancel(ctx, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		now := metav1.NewMicroTime(time.Now())
+		spec := coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To(holder),
+			LeaseDurationSeconds: ptr.To(int32(duration.Seconds())),
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		}
+
+		lease, err := kubeClient.CoordinationV1().Leases(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			log.Debug("Creating Lease")
+			_, err = kubeClient.CoordinationV1().Leases(namespace).Create(ctx, &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Spec: spec,
+			}, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				return false, nil
+			}
+			return err == nil, err
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != holder && !isLeaseExpired(lease) {
+			currentHolder = *lease.Spec.HolderIdentity
+			log.Infof("Waiting for Lease held by %v", currentHolder)
+			return false, nil
+		}
+
+		log.Debug("Taking over Lease")
+		lease.Spec = spec
+		_, err = kubeClient.CoordinationV1().Leases(namespace).Update(ctx, lease, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	if currentHolder != "" {
+		return errors.Wrapf(ctx.Err(), "timed out acquiring Lease %v held by %v", name, currentHolder)
+	}
+	return errors.Wrapf(ctx.Err(), "timed out acquiring Lease %v", name)
+}
+
+// ReleaseLease deletes the Lease if it is held by the holder.
+func ReleaseLease(kubeClient *kubeclient.Clientset, namespace, name, holder string) error {
+	lease, err := kubeClient.CoordinationV1().Leases(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get Lease %v", name)
+	}
+
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holder {
+		return nil
+	}
+
+	err = kubeClient.CoordinationV1().Leases(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return errors.Wrapf(err, "failed to delete Lease %v", name)
+}
+
+func isLeaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expireTime := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return time.Now().After(expireTime)
+}
@@ -2,23 +2,205 @@ package kubernetes
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
 
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	kubeclient "k8s.io/client-go/kubernetes"
 
 	"github.com/longhorn/cli/pkg/consts"
 )
 
+// GetCurrentNode returns the node CR of the node the pod is running on
+func GetCurrentNode(kubeClient *kubeclient.Clientset) (*corev1.Node, error) {
+	currentNodeID := os.Getenv(consts.EnvCurrentNodeID)
+	return kubeClient.CoreV1().Nodes().Get(context.TODO(), currentNodeID, metav1.GetOptions{})
+}
+
 // GetHugePagesCapacity returns hugepages-2Mi capacity of current node
 func GetHugePagesCapacity(kubeClient *kubeclient.Clientset) (*resource.Quantity, error) {
-	currentNodeID := os.Getenv(consts.EnvCurrentNodeID)
-	node, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), currentNodeID, metav1.GetOptions{})
+	node, err := GetCurrentNode(kubeClient)
 	if err != nil {
 		return nil, err
 	}
 
 	return node.Status.Capacity.Name("hugepages-2Mi", resource.BinarySI), nil
 }
+
+// ListNodeActivePods returns the pods scheduled on the given node that are not yet terminated
+func ListNodeActivePods(kubeClient *kubeclient.Clientset, nodeName string) (*corev1.PodList, error) {
+	fieldSelector := fields.AndSelectors(
+		fields.OneTermEqualSelector("spec.nodeName", nodeName),
+		fields.OneTermNotEqualSelector("status.phase", string(corev1.PodSucceeded)),
+		fields.OneTermNotEqualSelector("status.phase", string(corev1.PodFailed)),
+	)
+
+	return kubeClient.CoreV1().Pods(corev1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		FieldSelector: fieldSelector.String(),
+	})
+}
+
+// IsNodeUnschedulable returns true if the node is marked as unschedulable, such as cordoned by the admin
+func IsNodeUnschedulable(kubeClient *kubeclient.Clientset, nodeName string) (bool, error) {
+	node, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get node %v", nodeName)
+	}
+	return node.Spec.Unschedulable, nil
+}
+
+// CordonNode marks the node as unschedulable, or schedulable when unschedulable is false
+func CordonNode(kubeClient *kubeclient.Clientset, nodeName string, unschedulable bool) error {
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+	_, err := kubeClient.CoreV1().Nodes().Patch(context.TODO(), nodeName, k8stypes.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return errors.Wrapf(err, "failed to set node %v unschedulable to %t", nodeName, unschedulable)
+}
+
+// DrainNode evicts the pods running on the node, except the DaemonSet and mirror pods, and waits for them
+// to be deleted. Evictions rejected by a PodDisruptionBudget are retried until the timeout is reached.
+func DrainNode(kubeClient *kubeclient.Clientset, nodeName string, timeout time.Duration) error {
+	log := logrus.WithField("node", nodeName)
+
+	pods, err := ListNodeActivePods(kubeClient, nodeName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list pods on node %v", nodeName)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var evictedPods []corev1.Pod
+	for _, pod := range pods.Items {
+		if isDaemonSetPod(&pod) || isMirrorPod(&pod) {
+			continue
+		}
+
+		log.WithField("pod", pod.Namespace+"/"+pod.Name).Debug("Evicting pod")
+		if err := evictPod(ctx, kubeClient, &pod); err != nil {
+			if ctx.Err() != nil {
+				return errors.Wrapf(ctx.Err(), "timed out evicting pod %v/%v from node %v", pod.Namespace, pod.Name, nodeName)
+			}
+			return err
+		}
+		evictedPods = append(evictedPods, pod)
+	}
+
+	err = wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+		for _, pod := range evictedPods {
+			current, err := kubeClient.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			if current.UID == pod.UID {
+				log.WithField("pod", pod.Namespace+"/"+pod.Name).Trace("Waiting for pod to be deleted")
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return errors.Wrapf(ctx.Err(), "timed out waiting for the evicted pods to be deleted from node %v", nodeName)
+}
+
+// evictPod evicts the pod with the eviction API, retrying while the eviction is rejected by a PodDisruptionBudget
+func evictPod(ctx context.Context, kubeClient *kubeclient.Clientset, pod *corev1.Pod) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+	}
+
+	return wait.PollUntilContextCancel(ctx, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		err := kubeClient.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		switch {
+		case err == nil, apierrors.IsNotFound(err):
+			return true, nil
+		case apierrors.IsTooManyRequests(err):
+			logrus.WithField("pod", pod.Namespace+"/"+pod.Name).Debug("Pod eviction is rejected by PodDisruptionBudget, retrying")
+			return false, nil
+		default:
+			return false, errors.Wrapf(err, "failed to evict pod %v/%v", pod.Namespace, pod.Name)
+		}
+	})
+}
+
+func isDaemonSetPod(pod *corev1.Pod) bool {
+	for _, ownerRef := range pod.OwnerReferences {
+		if ownerRef.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
+
+func isMirrorPod(pod *corev1.Pod) bool {
+	_, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]
+	return ok
+}
+
+// WaitForNodeReboot waits for the node to report a boot ID different from the given one and to be Ready.
+func WaitForNodeReboot(kubeClient *kubeclient.Clientset, nodeName, bootID string, timeout time.Duration) error {
+	log := logrus.WithField("node", nodeName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rebooted := false
+	err := wait.PollUntilContextCancel(ctx, 5*time.Second, false, func(ctx context.Context) (bool, error) {
+		node, err := kubeClient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			// The API server may be unreachable while a control plane node reboots.
+			log.WithError(err).Debug("Failed to get node, retrying")
+			return false, nil
+		}
+
+		if node.Status.NodeInfo.BootID == bootID {
+			log.Trace("Waiting for node to reboot")
+			return false, nil
+		}
+		rebooted = true
+
+		if !IsNodeReady(node) {
+			log.Trace("Waiting for node to be ready")
+			return false, nil
+		}
+
+		return true, nil
+	})
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	if !rebooted {
+		return errors.Wrapf(ctx.Err(), "timed out waiting for node %v to reboot", nodeName)
+	}
+	return errors.Wrapf(ctx.Err(), "timed out waiting for node %v to be ready after reboot", nodeName)
+}
+
+// IsNodeReady returns true if the node Ready condition is true
+func IsNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
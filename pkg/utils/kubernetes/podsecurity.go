@@ -0,0 +1,118 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	kubeclient "k8s.io/client-go/kubernetes"
+
+	"github.com/longhorn/cli/pkg/consts"
+)
+
+// policyEngineWebhookPatterns maps the admission webhook name patterns to the policy engines
+// that may reject the privileged DaemonSet pods independently of Pod Security admission.
+var policyEngineWebhookPatterns = map[string]string{
+	"kyverno":    "Kyverno",
+	"gatekeeper": "OPA Gatekeeper",
+	"kubewarden": "Kubewarden",
+}
+
+// CheckNamespacePodSecurity checks if the namespace allows the privileged DaemonSet pods
+// with HostPID and hostPath volumes created by longhornctl.
+//
+// It fails when the namespace enforces the baseline or restricted Pod Security Standard,
+// unless labelPrivileged is set, in which case the namespace is labeled to enforce the
+// privileged Pod Security Standard. A namespace without the enforce label is subject to the
+// cluster-wide default, which cannot be inspected, so it is warned about, or labeled when
+// labelPrivileged is set. It also warns about the detected policy engines. It is called once per
+// command, before creating the first DaemonSet, so the warnings are not repeated for each batch.
+func CheckNamespacePodSecurity(kubeClient *kubeclient.Clientset, namespace string, labelPrivileged bool) error {
+	ns, err := kubeClient.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "namespace %q does not exist", namespace)
+		}
+		if apierrors.IsForbidden(err) {
+			logrus.WithError(err).Debugf("Skipped checking Pod Security admission labels of namespace %q", namespace)
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get namespace %q", namespace)
+	}
+
+	for _, label := range []string{consts.PodSecurityLabelAudit, consts.PodSecurityLabelWarn} {
+		if level := ns.Labels[label]; isPodSecurityLevelRestrictive(level) {
+			logrus.Warnf("Namespace %q is labeled with %s=%s, the privileged pods created by longhornctl will be reported", namespace, label, level)
+		}
+	}
+
+	level, labeled := ns.Labels[consts.PodSecurityLabelEnforce]
+	if !labeled && !labelPrivileged {
+		// The cluster-wide defaults of the PodSecurity admission configuration are not visible through the API.
+		logrus.Warnf("Namespace %q is not labeled with %s, the default Pod Security Standard of the cluster applies (e.g. restricted on RKE2 with the CIS profile). "+
+			"If the DaemonSet pods are rejected, label the namespace with %s=%s, or rerun with --%s to label it automatically",
+			namespace, consts.PodSecurityLabelEnforce, consts.PodSecurityLabelEnforce, consts.PodSecurityLevelPrivileged, consts.CmdOptLabelNamespacePrivileged)
+	}
+
+	if isPodSecurityLevelRestrictive(level) || (!labeled && labelPrivileged) {
+		if !labelPrivileged {
+			return errors.Errorf("namespace %q enforces the %q Pod Security Standard, which rejects the privileged pods with HostPID and hostPath volumes created by longhornctl. "+
+				"Label the namespace with %s=%s, or rerun with --%s to label it automatically",
+				namespace, level, consts.PodSecurityLabelEnforce, consts.PodSecurityLevelPrivileged, consts.CmdOptLabelNamespacePrivileged)
+		}
+
+		if err := labelNamespacePrivileged(kubeClient, namespace); err != nil {
+			return err
+		}
+		logrus.Infof("Labeled namespace %q with %s=%s", namespace, consts.PodSecurityLabelEnforce, consts.PodSecurityLevelPrivileged)
+	}
+
+	warnPolicyEngines(kubeClient, namespace)
+	return nil
+}
+
+func isPodSecurityLevelRestrictive(level string) bool {
+	return level == consts.PodSecurityLevelBaseline || level == consts.PodSecurityLevelRestricted
+}
+
+func labelNamespacePrivileged(kubeClient *kubeclient.Clientset, namespace string) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels": map[string]string{
+				consts.PodSecurityLabelEnforce: consts.PodSecurityLevelPrivileged,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = kubeClient.CoreV1().Namespaces().Patch(context.TODO(), namespace, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+	return errors.Wrapf(err, "failed to label namespace %q", namespace)
+}
+
+// warnPolicyEngines warns about the policy engines detected by their validating admission webhooks.
+func warnPolicyEngines(kubeClient *kubeclient.Clientset, namespace string) {
+	webhooks, err := kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logrus.WithError(err).Debug("Skipped detecting policy engines")
+		return
+	}
+
+	detected := map[string]bool{}
+	for _, webhook := range webhooks.Items {
+		for pattern, engine := range policyEngineWebhookPatterns {
+			if strings.Contains(webhook.Name, pattern) && !detected[engine] {
+				detected[engine] = true
+				logrus.Warnf("Detected policy engine %v (ValidatingWebhookConfiguration %q). Please ensure its policies allow privileged pods with HostPID and hostPath volumes in namespace %q",
+					engine, webhook.Name, namespace)
+			}
+		}
+	}
+}
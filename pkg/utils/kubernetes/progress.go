@@ -0,0 +1,255 @@
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// podContainerPhase is the progress of a pod container, as reported in the progress summary.
+type podContainerPhase string
+
+const (
+	podContainerPhasePending = podContainerPhase("pending")
+	podContainerPhaseRunning = podContainerPhase("running")
+	podContainerPhaseDone    = podContainerPhase("done")
+	podContainerPhaseFailed  = podContainerPhase("failed")
+)
+
+const (
+	// progressSummaryMaxNodes is the maximum number of node names listed per phase in the progress summary.
+	progressSummaryMaxNodes = 5
+
+	// followLogsDrainTimeout is the time given to the log streams to reach the end of the logs of the
+	// exited containers, once the follow is stopped.
+	followLogsDrainTimeout = 10 * time.Second
+)
+
+// podsLogFollower streams the logs of a container of the workload pods, and the progress summary.
+type podsLogFollower struct {
+	workload      *Workload
+	logger        *logrus.Entry
+	containerName string
+
+	lock    sync.Mutex // Serializes the writes to out.
+	out     io.Writer
+	summary string
+
+	streams   map[string]int32 // Restart count of the container streamed, by pod name.
+	streamsWg sync.WaitGroup
+}
+
+// FollowPodsLogByContainer streams the log lines of the specified container within the given pods to out,
+// prefixed with the node name, as soon as the container is started. A summary of the container progress on
+// each node (pending/running/done/failed) is written whenever it changes.
+// The returned function stops the follow, and waits for the logs of the exited containers to be written.
+func (obj *Workload) FollowPodsLogByContainer(logger *logrus.Entry, containerName string, out io.Writer) (stop func()) {
+	follower := &podsLogFollower{
+		workload:      obj,
+		logger:        logger,
+		containerName: containerName,
+		out:           out,
+		streams:       map[string]int32{},
+	}
+
+	pollCtx, pollCancel := context.WithCancel(context.Background())
+	streamCtx, streamCancel := context.WithCancel(context.Background())
+
+	pollDone := make(chan struct{})
+	go func() {
+		defer close(pollDone)
+
+		_ = wait.PollUntilContextCancel(pollCtx, time.Second, true, func(ctx context.Context) (bool, error) {
+			follower.refresh(ctx, streamCtx)
+			return false, nil
+		})
+	}()
+
+	return func() {
+		pollCancel()
+		<-pollDone
+
+		// Catch up with the containers that exited since the last refresh.
+		follower.refresh(streamCtx, streamCtx)
+
+		streamsDone := make(chan struct{})
+		go func() {
+			defer close(streamsDone)
+			follower.streamsWg.Wait()
+		}()
+
+		select {
+		case <-streamsDone:
+		case <-time.After(followLogsDrainTimeout):
+			logger.Debug("Timed out waiting for pod container log streams to complete")
+		}
+		streamCancel()
+		<-streamsDone
+	}
+}
+
+// refresh starts streaming the logs of the newly started containers, and writes the progress summary if it
+// has changed since the last refresh.
+func (f *podsLogFollower) refresh(ctx, streamCtx context.Context) {
+	pods, err := f.workload.KubeClient.CoreV1().Pods(f.workload.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: f.workload.LabelSelectors,
+	})
+	if err != nil {
+		f.logger.WithError(err).Debug("Failed to list pods to follow")
+		return
+	}
+
+	nodesByPhase := map[podContainerPhase][]string{}
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		nodeName := pod.Spec.NodeName
+		if nodeName == "" {
+			nodeName = pod.Name
+		}
+
+		phase := getPodContainerPhase(&pod, f.containerName)
+		nodesByPhase[phase] = append(nodesByPhase[phase], nodeName)
+
+		status := getPodContainerStatus(&pod, f.containerName)
+		if status == nil || (status.State.Running == nil && status.State.Terminated == nil) {
+			continue
+		}
+
+		// Stream the logs again when the container is restarted.
+		if restartCount, ok := f.streams[pod.Name]; ok && restartCount == status.RestartCount {
+			continue
+		}
+		f.streams[pod.Name] = status.RestartCount
+
+		f.streamsWg.Add(1)
+		go f.stream(streamCtx, pod.DeepCopy(), nodeName)
+	}
+
+	summary := formatProgressSummary(f.containerName, nodesByPhase)
+	if summary == f.summary {
+		return
+	}
+	f.summary = summary
+	f.writeLine(summary)
+}
+
+// stream writes the log lines of the pod container prefixed with the node name, until the container exits.
+func (f *podsLogFollower) stream(ctx context.Context, pod *corev1.Pod, nodeName string) {
+	defer f.streamsWg.Done()
+
+	log := f.logger.WithFields(logrus.Fields{
+		"pod":       pod.Name,
+		"container": f.containerName,
+	})
+
+	podLogOpts := &corev1.PodLogOptions{
+		Container: f.containerName,
+		Follow:    true,
+	}
+	podLogs, err := f.workload.KubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, podLogOpts).Stream(ctx)
+	if err != nil {
+		log.WithError(err).Debug("Failed to follow pod container log")
+		return
+	}
+	defer func() {
+		_ = podLogs.Close()
+	}()
+
+	prefix := fmt.Sprintf("[%s] ", nodeName)
+	scanner := bufio.NewScanner(podLogs)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1024*1024)
+	for scanner.Scan() {
+		f.writeLine(prefix + scanner.Text())
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		log.WithError(err).Debug("Failed to read pod container log")
+	}
+}
+
+func (f *podsLogFollower) writeLine(line string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	_, _ = fmt.Fprintln(f.out, line)
+}
+
+// getPodContainerStatus returns the status of the init container or container of the pod, or nil if the
+// container has no status yet.
+func getPodContainerStatus(pod *corev1.Pod, containerName string) *corev1.ContainerStatus {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for i := range statuses {
+			if statuses[i].Name == containerName {
+				return &statuses[i]
+			}
+		}
+	}
+	return nil
+}
+
+// getPodContainerPhase returns the progress of the pod container. A container waiting to be restarted after
+// a failure is failed, while a container waiting for the first start is pending.
+func getPodContainerPhase(pod *corev1.Pod, containerName string) podContainerPhase {
+	status := getPodContainerStatus(pod, containerName)
+	if status == nil {
+		return podContainerPhasePending
+	}
+
+	switch {
+	case status.State.Running != nil:
+		return podContainerPhaseRunning
+	case status.State.Terminated != nil:
+		if status.State.Terminated.ExitCode == 0 {
+			return podContainerPhaseDone
+		}
+		return podContainerPhaseFailed
+	case status.LastTerminationState.Terminated != nil && status.LastTerminationState.Terminated.ExitCode != 0:
+		return podContainerPhaseFailed
+	default:
+		return podContainerPhasePending
+	}
+}
+
+// formatProgressSummary formats the number of nodes in each phase, followed by the first node names of the
+// phases other than done. For example:
+// [init-container] 1/4 done, 1 running (node-2), 1 pending (node-3), 1 failed (node-4)
+func formatProgressSummary(containerName string, nodesByPhase map[podContainerPhase][]string) string {
+	total := 0
+	for _, nodes := range nodesByPhase {
+		total += len(nodes)
+	}
+
+	parts := []string{fmt.Sprintf("%d/%d done", len(nodesByPhase[podContainerPhaseDone]), total)}
+	for _, phase := range []podContainerPhase{podContainerPhaseRunning, podContainerPhasePending, podContainerPhaseFailed} {
+		nodes := slices.Sorted(slices.Values(nodesByPhase[phase]))
+		if len(nodes) == 0 {
+			parts = append(parts, fmt.Sprintf("0 %s", phase))
+			continue
+		}
+
+		listed := nodes
+		if len(listed) > progressSummaryMaxNodes {
+			listed = listed[:progressSummaryMaxNodes]
+		}
+		names := strings.Join(listed, ", ")
+		if len(nodes) > len(listed) {
+			names += fmt.Sprintf(", +%d more", len(nodes)-len(listed))
+		}
+		parts = append(parts, fmt.Sprintf("%d %s (%s)", len(nodes), phase, names))
+	}
+
+	return fmt.Sprintf("[%s] %s", containerName, strings.Join(parts, ", "))
+}
@@ -0,0 +1,108 @@
+package kubernetes
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetPodContainerPhase(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		status *corev1.ContainerStatus
+		want   podContainerPhase
+	}{
+		{
+			name:   "no status",
+			status: nil,
+			want:   podContainerPhasePending,
+		},
+		{
+			name: "waiting for the first start",
+			status: &corev1.ContainerStatus{
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}},
+			},
+			want: podContainerPhasePending,
+		},
+		{
+			name: "running",
+			status: &corev1.ContainerStatus{
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			},
+			want: podContainerPhaseRunning,
+		},
+		{
+			name: "completed",
+			status: &corev1.ContainerStatus{
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}},
+			},
+			want: podContainerPhaseDone,
+		},
+		{
+			name: "exited with error",
+			status: &corev1.ContainerStatus{
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}},
+			},
+			want: podContainerPhaseFailed,
+		},
+		{
+			name: "waiting to be restarted after error",
+			status: &corev1.ContainerStatus{
+				State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}},
+				RestartCount:         1,
+			},
+			want: podContainerPhaseFailed,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &corev1.Pod{}
+			if test.status != nil {
+				status := *test.status
+				status.Name = "init"
+				pod.Status.InitContainerStatuses = []corev1.ContainerStatus{status}
+			}
+
+			if got := getPodContainerPhase(pod, "init"); got != test.want {
+				t.Errorf("getPodContainerPhase() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatProgressSummary(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		nodesByPhase map[podContainerPhase][]string
+		want         string
+	}{
+		{
+			name:         "no pod",
+			nodesByPhase: map[podContainerPhase][]string{},
+			want:         "[init] 0/0 done, 0 running, 0 pending, 0 failed",
+		},
+		{
+			name: "all phases",
+			nodesByPhase: map[podContainerPhase][]string{
+				podContainerPhaseDone:    {"node-1"},
+				podContainerPhaseRunning: {"node-2"},
+				podContainerPhasePending: {"node-3"},
+				podContainerPhaseFailed:  {"node-4"},
+			},
+			want: "[init] 1/4 done, 1 running (node-2), 1 pending (node-3), 1 failed (node-4)",
+		},
+		{
+			name: "node names sorted and truncated",
+			nodesByPhase: map[podContainerPhase][]string{
+				podContainerPhaseRunning: {"node-7", "node-6", "node-5", "node-4", "node-3", "node-2", "node-1"},
+			},
+			want: "[init] 0/7 done, 7 running (node-1, node-2, node-3, node-4, node-5, +2 more), 0 pending, 0 failed",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := formatProgressSummary("init", test.nodesByPhase); got != test.want {
+				t.Errorf("formatProgressSummary() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
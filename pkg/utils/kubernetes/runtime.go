@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"time"
 
@@ -31,17 +32,17 @@ import (
 )
 
 // WaitForDaemonSetContainersReady waits for the containers in the given DaemonSet to be ready.
-func WaitForDaemonSetContainersReady(ctx context.Context, logger *logrus.Entry, kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string, maxConditionToleration *int) error {
+func WaitForDaemonSetContainersReady(ctx context.Context, logger *logrus.Entry, kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string) error {
 	logger.Debug("Waiting for DaemonSet container to be ready")
 
 	conditionFunc := func(pod *corev1.Pod) bool {
 		return commonkube.IsPodContainerInState(pod, containerName, commonkube.IsContainerReady)
 	}
-	return waitForDaemonSetContainers(ctx, logger, kubeClient, daemonSet, containerName, conditionFunc, maxConditionToleration)
+	return waitForDaemonSetContainers(ctx, logger, kubeClient, daemonSet, containerName, conditionFunc)
 }
 
 // WaitForDaemonSetContainersExit waits for the containers in the given DaemonSet to exit.
-func WaitForDaemonSetContainersExit(ctx context.Context, logger *logrus.Entry, kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string, maxConditionToleration *int) error {
+func WaitForDaemonSetContainersExit(ctx context.Context, logger *logrus.Entry, kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string) error {
 	logger.Debug("Waiting for DaemonSet container to exit")
 
 	conditionFunc := func(pod *corev1.Pod) bool {
@@ -54,17 +55,18 @@ func WaitForDaemonSetContainersExit(ctx context.Context, logger *logrus.Entry, k
 		isCompleted := commonkube.IsPodContainerInState(pod, containerName, commonkube.IsContainerCompleted)
 		return !isWaitingCrashloopBackoff && isCompleted
 	}
-	return waitForDaemonSetContainers(ctx, logger, kubeClient, daemonSet, containerName, conditionFunc, maxConditionToleration)
+	return waitForDaemonSetContainers(ctx, logger, kubeClient, daemonSet, containerName, conditionFunc)
 }
 
-func waitForDaemonSetContainers(ctx context.Context, logger *logrus.Entry, kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string, conditionFunc func(pod *corev1.Pod) bool, maxConditionToleration *int) error {
+// waitForDaemonSetContainers polls the DaemonSet pods until the condition is met for the container of all
+// pods, or the context is done. When the context is done, the error lists the nodes on which the condition
+// is not met yet.
+func waitForDaemonSetContainers(ctx context.Context, logger *logrus.Entry, kubeClient *kubeclient.Clientset, daemonSet *appsv1.DaemonSet, containerName string, conditionFunc func(pod *corev1.Pod) bool) error {
 	isPodScheduled := false
+	var scheduledPods, desiredPods int
+	var pendingNodes []string
 
-	return wait.PollUntilContextCancel(ctx, time.Second, false, func(ctx context.Context) (bool, error) {
-		if maxConditionToleration != nil && *maxConditionToleration < 0 {
-			return true, errors.Errorf("exceeded maximum tolerated condition for DaemonSet container %s", containerName)
-		}
-
+	err := wait.PollUntilContextCancel(ctx, time.Second, false, func(ctx context.Context) (bool, error) {
 		pods, err := kubeClient.CoreV1().Pods(daemonSet.Namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: fields.SelectorFromSet(daemonSet.Spec.Selector.MatchLabels).String(),
 		})
@@ -89,37 +91,44 @@ func waitForDaemonSetContainers(ctx context.Context, logger *logrus.Entry, kubeC
 			}
 
 			// Check if pod count is equal to DaemonSet pod count
-			if len(pods.Items) != int(daemonSet.Status.DesiredNumberScheduled) {
+			scheduledPods, desiredPods = len(pods.Items), int(desiredNumberScheduled)
+			if scheduledPods != desiredPods {
 				return false, nil
 			}
 
 			isPodScheduled = true
 		}
 
+		pendingNodes = pendingNodes[:0]
 		for _, pod := range pods.Items {
 			logger.WithField("pod", pod.Name).Trace("Checking pod container condition")
 
 			if commonkube.IsPodContainerInState(&pod, containerName, commonkube.IsContainerWaitingCrashLoopBackOff) {
 				logger.Debug("Pod container is in crashloopbackoff")
 
-				*maxConditionToleration = -1
-
 				return false, errors.Errorf("pod container is in crash loop. View the logs using \"kubectl -n %s logs %s -c %s\"", pod.Namespace, pod.Name, containerName)
 			}
 
 			if !conditionFunc(&pod) {
-				logger.Tracef("Waiting for pod container condition to be met, tolerating %v", *maxConditionToleration)
-
-				if maxConditionToleration != nil {
-					*maxConditionToleration--
-				}
-
-				return false, nil
+				pendingNodes = append(pendingNodes, pod.Spec.NodeName)
 			}
 		}
 
+		if len(pendingNodes) > 0 {
+			logger.Tracef("Waiting for pod container condition to be met on nodes %v", pendingNodes)
+			return false, nil
+		}
 		return true, nil
 	})
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	if !isPodScheduled {
+		return errors.Wrapf(ctx.Err(), "DaemonSet scheduled %d of %d pods", scheduledPods, desiredPods)
+	}
+	slices.Sort(pendingNodes)
+	return errors.Wrapf(ctx.Err(), "container %s is still pending on nodes %s", containerName, strings.Join(pendingNodes, ", "))
 }
 
 // Workload provide functions for workloads.
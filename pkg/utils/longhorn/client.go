@@ -4,6 +4,7 @@ import (
 	"context"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
 
 	kubeutils "github.com/longhorn/cli/pkg/utils/kubernetes"
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
@@ -31,7 +32,12 @@ func NewLonghornClient(kubeconfigPath, namespace string) (*LonghornClient, error
 		return nil, err
 	}
 
-	lhClient, err := lhclientset.NewForConfig(kubeconfig)
+	return NewLonghornClientForConfig(kubeconfig, namespace)
+}
+
+// NewLonghornClientForConfig creates a LonghornClient from an existing rest config, for example the in-cluster config.
+func NewLonghornClientForConfig(config *rest.Config, namespace string) (*LonghornClient, error) {
+	lhClient, err := lhclientset.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
@@ -66,3 +72,7 @@ func (s *LonghornClient) ListVolumeSnapshots(volumeName string) (*longhorn.Snaps
 		LabelSelector: selector.String(),
 	})
 }
+
+func (s *LonghornClient) GetSetting(name string) (*longhorn.Setting, error) {
+	return s.clientset.LonghornV1beta2().Settings(s.namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
@@ -27,6 +27,14 @@ func GetPackageManagerType(osRelease string) (pkgmgr.PackageManagerType, error)
 		return pkgmgr.PackageManagerYum, nil
 	case "arch":
 		return pkgmgr.PackageManagerPacman, nil
+	case "alpine":
+		return pkgmgr.PackageManagerApk, nil
+	case "coreos":
+		return pkgmgr.PackageManagerRpmOstree, nil
+	case "gentoo":
+		return pkgmgr.PackageManagerQlist, nil
+	case "mariner", "azurelinux", "photon":
+		return pkgmgr.PackageManagerTdnf, nil
 	default:
 		return detectPackageManagerUnknown(osRelease)
 	}
@@ -40,15 +48,19 @@ func detectPackageManagerUnknown(osRelease string) (pkgmgr.PackageManagerType, e
 	}{
 		{"transactional-update", pkgmgr.PackageManagerTransactionalUpdate, "SUSE micro"},
 		{"zypper", pkgmgr.PackageManagerZypper, "SUSE-based"},
+		{"rpm-ostree", pkgmgr.PackageManagerRpmOstree, "RPM-based (rpm-ostree)"},
 		{"apt", pkgmgr.PackageManagerApt, "Debian-based"},
+		{"tdnf", pkgmgr.PackageManagerTdnf, "RPM-based (tdnf)"},
 		{"microdnf", pkgmgr.PackageManagerYum, "RPM-based (microdnf)"},
 		{"yum", pkgmgr.PackageManagerYum, "RPM-based (yum)"},
 		{"dnf", pkgmgr.PackageManagerYum, "RPM-based (dnf)"},
 		{"pacman", pkgmgr.PackageManagerPacman, "Arch Linux"},
+		{"apk", pkgmgr.PackageManagerApk, "Alpine Linux"},
+		{"qlist", pkgmgr.PackageManagerQlist, "Gentoo"},
 	}
 
 	for _, pm := range packageManagers {
-		if isCommandAvailableOnHost(pm.command) {
+		if IsCommandAvailableOnHost(pm.command) {
 			fmt.Fprintf(os.Stderr, "WARNING: Operating system '%s' is not officially supported by the Longhorn command-line tool. Please check the official documentation to install the prerequisites manually. "+
 				"Detected package manager '%s' (%s). "+
 				"Proceeding with compatibility mode, but there may be compatibility issues.\n",
@@ -59,9 +71,9 @@ func detectPackageManagerUnknown(osRelease string) (pkgmgr.PackageManagerType, e
 	return pkgmgr.PackageManagerUnknown, fmt.Errorf("operating system (%s) is not supported by the Longhorn command-line tool and no known package manager could be detected. Please check the official documentation to install the prerequisites manually", osRelease)
 }
 
-// isCommandAvailableOnHost checks if a command is available on the host system
+// IsCommandAvailableOnHost checks if a command is available on the host system
 // by checking common binary locations in the host filesystem
-func isCommandAvailableOnHost(command string) bool {
+func IsCommandAvailableOnHost(command string) bool {
 	// Common paths where package managers are typically installed
 	commonPaths := []string{
 		"/usr/bin",
@@ -166,6 +178,27 @@ func parseOSreleaseFile(lines []string) (string, error) {
 		return "suse", nil
 	}
 
+	// For rpm-ostree based systems, the root is immutable and the packages are layered with rpm-ostree.
+	// Example (Fedora CoreOS):
+	//     ID=fedora
+	//     VARIANT_ID=coreos
+	// Example (RHEL CoreOS):
+	//     ID="rhcos"
+	//     ID_LIKE="rhel fedora"
+	//     VARIANT_ID=coreos
+	if strings.ToLower(variantID) == "coreos" {
+		return "coreos", nil
+	}
+
+	// For immutable systems without package manager, use ID since ID_LIKE refers to another distro.
+	// Example (Flatcar):
+	//     ID=flatcar
+	//     ID_LIKE=coreos
+	switch strings.ToLower(id) {
+	case string(consts.OperatingSystemTalos), string(consts.OperatingSystemFlatcar):
+		return strings.ToLower(id), nil
+	}
+
 	// For non-SUSE systems, prefer ID_LIKE over ID (use first word from ID_LIKE)
 	if idLike != "" {
 		fields := strings.Fields(idLike)
@@ -32,6 +32,36 @@ func TestParseOSreleaseFile(t *testing.T) {
 			input:  []string{"ID=\"my-os\""},
 			output: "my-os",
 		},
+		{
+			name:   "Alpine Linux",
+			input:  []string{"ID=alpine", "VERSION_ID=3.20.3"},
+			output: "alpine",
+		},
+		{
+			name:   "Fedora CoreOS",
+			input:  []string{"ID=fedora", "VARIANT=\"CoreOS\"", "VARIANT_ID=coreos"},
+			output: "coreos",
+		},
+		{
+			name:   "RHEL CoreOS",
+			input:  []string{"ID=\"rhcos\"", "ID_LIKE=\"rhel fedora\"", "VARIANT_ID=\"coreos\""},
+			output: "coreos",
+		},
+		{
+			name:   "Flatcar",
+			input:  []string{"ID=flatcar", "ID_LIKE=coreos", "VERSION_ID=4081.2.0"},
+			output: "flatcar",
+		},
+		{
+			name:   "Talos",
+			input:  []string{"NAME=\"Talos\"", "ID=talos", "VERSION_ID=v1.8.3"},
+			output: "talos",
+		},
+		{
+			name:   "Fedora Server",
+			input:  []string{"ID=fedora", "VARIANT_ID=server"},
+			output: "fedora",
+		},
 		{
 			name:   "SLE Micro 6.1 with sl-micro ID",
 			input:  []string{"ID=\"sl-micro\"", "ID_LIKE=\"suse sle-micro opensuse-microos microos\"", "VARIANT_ID=\"SLE-Micro-Rancher\""},
@@ -155,6 +185,42 @@ func TestGetPackageManagerType(t *testing.T) {
 			wantType:   pkgmgr.PackageManagerPacman,
 			shouldFail: false,
 		},
+		{
+			name:       "Alpine Linux",
+			osRelease:  "alpine",
+			wantType:   pkgmgr.PackageManagerApk,
+			shouldFail: false,
+		},
+		{
+			name:       "CBL-Mariner",
+			osRelease:  "mariner",
+			wantType:   pkgmgr.PackageManagerTdnf,
+			shouldFail: false,
+		},
+		{
+			name:       "Azure Linux",
+			osRelease:  "azurelinux",
+			wantType:   pkgmgr.PackageManagerTdnf,
+			shouldFail: false,
+		},
+		{
+			name:       "Photon OS",
+			osRelease:  "photon",
+			wantType:   pkgmgr.PackageManagerTdnf,
+			shouldFail: false,
+		},
+		{
+			name:       "Gentoo",
+			osRelease:  "gentoo",
+			wantType:   pkgmgr.PackageManagerQlist,
+			shouldFail: false,
+		},
+		{
+			name:       "CoreOS",
+			osRelease:  "coreos",
+			wantType:   pkgmgr.PackageManagerRpmOstree,
+			shouldFail: false,
+		},
 		{
 			name:       "Oracle Linux",
 			osRelease:  "ol",
@@ -199,7 +265,7 @@ func TestIsCommandAvailableOnHost(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_ = isCommandAvailableOnHost(tt.command)
+			_ = IsCommandAvailableOnHost(tt.command)
 		})
 	}
 }